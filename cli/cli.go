@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/hunter-io/faktory/client"
@@ -73,8 +74,15 @@ func help() {
 	log.Println("-w [binding]\tWeb UI binding (use :7420 to listen on all interfaces), default: localhost:7420")
 	log.Println("-e [env]\tSet environment (development, production), default: development")
 	log.Println("-l [level]\tSet logging level (warn, info, debug, verbose), default: info")
+	log.Println("-d memory\tKeep all data in memory and lose it on exit, for tests and throwaway servers")
 	log.Println("-v\t\tShow version and license information")
 	log.Println("-h\t\tThis help screen")
+	log.Println("")
+	log.Println("backup\t\tAsk the running server to take a backup")
+	log.Println("promote [addr]\tPromote the replica at addr, default the -b binding, to primary")
+	log.Println("restore [id]\tRestore a backup, default the newest, into the empty storage; Faktory must be stopped")
+	log.Println("export <file>\tExport all data as JSON Lines; Faktory must be stopped")
+	log.Println("import [-dry-run] <file>\tImport an export into the empty storage; Faktory must be stopped")
 }
 
 var (
@@ -132,10 +140,60 @@ func BuildServer(opts CliOptions) (*server.Server, func(), error) {
 		return nil, nil, err
 	}
 
-	sock := fmt.Sprintf("%s/redis.sock", opts.StorageDirectory)
-	stopper, err := storage.BootRedis(opts.StorageDirectory, sock)
+	// [faktory]
+	//   dbtype = "embedded"
+	dbtype := stringConfig(globalConfig, "faktory", "dbtype", "redis")
+	// -d memory is shorthand for dbtype memory
+	if opts.StorageDirectory == "memory" {
+		dbtype = "memory"
+	}
+	external, err := externalRedis(globalConfig)
 	if err != nil {
-		return nil, stopper, err
+		return nil, nil, err
+	}
+	if external != nil && dbtype != "redis" {
+		return nil, nil, fmt.Errorf("An external redis requires dbtype redis, not %s", dbtype)
+	}
+	repl, replica, err := replication(globalConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if repl != nil && (external != nil || dbtype != "redis") {
+		return nil, nil, fmt.Errorf("Replication requires the redis booted by %s", client.Name)
+	}
+	if replica != nil && repl.Promoted(opts.StorageDirectory) {
+		util.Warnf("Promoted earlier, serving as the primary rather than a replica of %s", replica.Primary)
+		replica = nil
+	}
+	rconf, err := redisConfig(globalConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rconf != nil && (external != nil || dbtype != "redis") {
+		return nil, nil, fmt.Errorf("redis.save, appendonly and appendfsync require the redis booted by %s", client.Name)
+	}
+
+	tenants, err := tenantPasswords(globalConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// only boot our own redis-server if we weren't given one
+	var stopper func()
+	sock := fmt.Sprintf("%s/redis.sock", opts.StorageDirectory)
+	if dbtype == "redis" && external == nil {
+		if rconf == nil {
+			rconf = &storage.RedisConfig{}
+		}
+		rconf.Replication = repl
+		rconf.MaxMemory, err = server.MaxMemory(globalConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		stopper, err = storage.BootConfiguredRedis(opts.StorageDirectory, sock, rconf)
+		if err != nil {
+			return nil, stopper, err
+		}
 	}
 
 	// allow binding config element if no CLI arg spec'd:
@@ -150,9 +208,13 @@ func BuildServer(opts CliOptions) (*server.Server, func(), error) {
 		StorageDirectory: opts.StorageDirectory,
 		ConfigDirectory:  opts.ConfigDirectory,
 		Environment:      opts.Environment,
+		DBType:           dbtype,
 		RedisSock:        sock,
+		Redis:            external,
+		Replica:          replica,
 		GlobalConfig:     globalConfig,
 		Password:         pwd,
+		Tenants:          tenants,
 	}
 
 	// don't log config hash until fetchPassword has had a chance to scrub the password value
@@ -167,6 +229,198 @@ func BuildServer(opts CliOptions) (*server.Server, func(), error) {
 	return s, stopper, nil
 }
 
+// Use an external Redis rather than booting one, either via ENV or
+// a TOML file like:
+//
+// [redis]
+// url = "rediss://redis.example.com:6380/0"
+// password = "foobar"
+// db = 2
+// pool_size = 500
+func externalRedis(cfg map[string]any) (*storage.ExternalRedis, error) {
+	er := &storage.ExternalRedis{
+		URL: stringConfig(cfg, "redis", "url", ""),
+	}
+	if val, ok := os.LookupEnv("REDIS_URL"); ok && val != "" {
+		er.URL = val
+	}
+	if er.URL == "" {
+		return nil, nil
+	}
+
+	var err error
+	er.DB, err = intConfig(cfg, "redis", "db", 0)
+	if err != nil {
+		return nil, err
+	}
+	er.PoolSize, err = intConfig(cfg, "redis", "pool_size", 0)
+	if err != nil {
+		return nil, err
+	}
+	er.Password = stringConfig(cfg, "redis", "password", "")
+
+	// catch a bad URL now rather than when booting
+	_, err = er.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	// clear passwords so we can log the config safely
+	if x, ok := cfg["redis"].(map[string]any); ok {
+		if _, ok := x["password"]; ok {
+			x["password"] = "********"
+		}
+		if _, ok := x["url"]; ok {
+			x["url"] = er.String()
+		}
+	}
+	return er, nil
+}
+
+// redisConfig reads the settings for the redis booted by Faktory, nil
+// if there are none:
+//
+//	[redis]
+//	save = ["900 1", "300 10"]
+//	appendonly = true
+//	appendfsync = "always"
+func redisConfig(cfg map[string]any) (*storage.RedisConfig, error) {
+	section, _ := cfg["redis"].(map[string]any)
+	_, hasSave := section["save"]
+	_, hasAOF := section["appendonly"]
+	_, hasFsync := section["appendfsync"]
+	if !hasSave && !hasAOF && !hasFsync {
+		return nil, nil
+	}
+
+	rc := &storage.RedisConfig{
+		AppendFsync: stringConfig(cfg, "redis", "appendfsync", ""),
+	}
+	if hasSave {
+		rules, ok := section["save"].([]any)
+		if !ok {
+			return nil, fmt.Errorf("Invalid configuration, redis.save must be an array of strings")
+		}
+		rc.Save = []string{}
+		for _, rule := range rules {
+			str, ok := rule.(string)
+			if !ok {
+				return nil, fmt.Errorf("Invalid configuration, redis.save must be an array of strings")
+			}
+			rc.Save = append(rc.Save, str)
+		}
+	}
+	if hasAOF {
+		aof, ok := section["appendonly"].(bool)
+		if !ok {
+			return nil, fmt.Errorf("Invalid configuration, redis.appendonly must be true or false")
+		}
+		rc.AppendOnly = aof
+	}
+
+	err := rc.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// replication reads the [replication] section, both results are nil
+// unless it's configured and the server options only for a replica:
+//
+//	[replication]
+//	listen = "10.0.0.2:7421"
+//	password = "..."
+//	# on the replica
+//	primary = "10.0.0.1:7419"
+//	primary_redis = "10.0.0.1:7421"
+//	failover_after = 30
+func replication(cfg map[string]any) (*storage.RedisReplication, *server.ReplicaOptions, error) {
+	listen := stringConfig(cfg, "replication", "listen", "")
+	if listen == "" {
+		return nil, nil, nil
+	}
+	repl := &storage.RedisReplication{
+		Listen:   listen,
+		Primary:  stringConfig(cfg, "replication", "primary_redis", ""),
+		Password: stringConfig(cfg, "replication", "password", ""),
+	}
+	err := repl.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	primary := stringConfig(cfg, "replication", "primary", "")
+	if (primary == "") != (repl.Primary == "") {
+		return nil, nil, fmt.Errorf("replication: a replica needs both primary and primary_redis")
+	}
+	var replica *server.ReplicaOptions
+	if primary != "" {
+		secs, err := intConfig(cfg, "replication", "failover_after", 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		replica = &server.ReplicaOptions{Primary: primary, FailoverAfter: time.Duration(secs) * time.Second}
+	}
+
+	// clear the password so we can log the config safely
+	if x, ok := cfg["replication"].(map[string]any); ok {
+		x["password"] = "********"
+	}
+	return repl, replica, nil
+}
+
+// tenantPasswords reads the passwords of the tenants, one section
+// per tenant:
+//
+//	[tenants.acme]
+//	password = "..."
+func tenantPasswords(cfg map[string]any) (map[string]string, error) {
+	val, ok := cfg["tenants"]
+	if !ok {
+		return nil, nil
+	}
+	sections, ok := val.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("Invalid configuration, expected a tenants subsystem")
+	}
+
+	tenants := map[string]string{}
+	for name, val := range sections {
+		section, ok := val.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("Invalid configuration, expected a tenants.%s subsystem", name)
+		}
+		pwd, ok := section["password"].(string)
+		if !ok || pwd == "" {
+			return nil, fmt.Errorf("Config error: tenants.%s/password must be a non-empty String", name)
+		}
+		tenants[name] = pwd
+
+		// clear the password so we can log the config safely
+		section["password"] = "********"
+	}
+	return tenants, nil
+}
+
+func intConfig(cfg map[string]any, subsys string, elm string, defval int) (int, error) {
+	if mapp, ok := cfg[subsys]; ok {
+		if mappp, ok := mapp.(map[string]any); ok {
+			if val, ok := mappp[elm]; ok {
+				switch num := val.(type) {
+				case int64:
+					return int(num), nil
+				case int:
+					return num, nil
+				default:
+					return 0, fmt.Errorf("Invalid configuration, %s.%s must be an integer", subsys, elm)
+				}
+			}
+		}
+	}
+	return defval, nil
+}
+
 func stringConfig(cfg map[string]any, subsys string, elm string, defval string) string {
 	if mapp, ok := cfg[subsys]; ok {
 		if mappp, ok := mapp.(map[string]any); ok {
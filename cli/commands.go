@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * Commands run instead of the server when given after the options:
+ *
+ *   faktory backup
+ *   faktory promote 10.0.0.2:7419
+ *   faktory -e production restore 1530000000000
+ *   faktory export jobs.jsonl
+ *   faktory import -dry-run jobs.jsonl
+ */
+var Commands = map[string]func(opts CliOptions, args []string) error{
+	"backup":  backup,
+	"promote": promote,
+	"restore": restore,
+	"export":  export,
+	"import":  importData,
+}
+
+func RunCommand(opts CliOptions, args []string) error {
+	fn, ok := Commands[args[0]]
+	if !ok {
+		return fmt.Errorf("Unknown command: %s", args[0])
+	}
+	return fn(opts, args[1:])
+}
+
+// backup asks the running server, found via FAKTORY_URL, to back up
+// rather than opening the storage alongside it.
+func backup(opts CliOptions, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("Usage: faktory backup")
+	}
+	cl, err := client.Open()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	info, err := cl.Backup()
+	if err != nil {
+		return err
+	}
+	util.Infof("Backup %.0f complete, %.0f keys", info["id"], info["file_count"])
+	return nil
+}
+
+// promote makes the replica at the given address the primary,
+// FAKTORY_URL supplies the password.
+func promote(opts CliOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Usage: faktory promote [addr]")
+	}
+	srv := client.DefaultServer()
+	err := srv.ReadFromEnv()
+	if err != nil {
+		return err
+	}
+	// exactly this server, not the primary of a list
+	srv.Addresses = nil
+	srv.Address = opts.CmdBinding
+	if len(args) == 1 {
+		srv.Address = args[0]
+	}
+
+	cl, err := srv.Open()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	err = cl.Promote()
+	if err != nil {
+		return err
+	}
+	util.Infof("%s is now the primary", srv.Address)
+	return nil
+}
+
+func restore(opts CliOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Usage: faktory restore [id]")
+	}
+	val := ""
+	if len(args) == 1 {
+		val = args[0]
+	}
+	id, err := storage.ParseBackupId(val)
+	if err != nil {
+		return err
+	}
+
+	s, stopper, err := BuildServer(opts)
+	if stopper != nil {
+		defer stopper()
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := s.Restore(id)
+	if err != nil {
+		return err
+	}
+	util.Infof("Restored backup %d from %s", info.Id, info.Time().Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func export(opts CliOptions, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: faktory export <file>")
+	}
+
+	s, stopper, err := BuildServer(opts)
+	if stopper != nil {
+		defer stopper()
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	count, err := s.Export(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	util.Infof("Exported %d records to %s", count, args[0])
+	return nil
+}
+
+func importData(opts CliOptions, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Report what would be imported without writing")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: faktory import [-dry-run] <file>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	s, stopper, err := BuildServer(opts)
+	if stopper != nil {
+		defer stopper()
+	}
+	if err != nil {
+		return err
+	}
+
+	summary, err := s.Import(file, *dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		util.Infof("Dry run, would import from %s:\n%s", flags.Arg(0), summary)
+	} else {
+		util.Infof("Imported from %s:\n%s", flags.Arg(0), summary)
+	}
+	return nil
+}
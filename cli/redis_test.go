@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalRedis(t *testing.T) {
+	t.Run("Local", func(t *testing.T) {
+		er, err := externalRedis(map[string]any{})
+		assert.NoError(t, err)
+		assert.Nil(t, er)
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		cfg := map[string]any{
+			"redis": map[string]any{
+				"url":       "rediss://:secret@redis.example.com:6380/1",
+				"password":  "sekrit",
+				"db":        int64(3),
+				"pool_size": int64(50),
+			},
+		}
+		er, err := externalRedis(cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, er)
+		assert.Equal(t, "sekrit", er.Password)
+		assert.Equal(t, 3, er.DB)
+		assert.Equal(t, 50, er.PoolSize)
+
+		// passwords are scrubbed before the config is logged
+		section := cfg["redis"].(map[string]any)
+		assert.Equal(t, "********", section["password"])
+		assert.Equal(t, "rediss://redis.example.com:6380/3", section["url"])
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		os.Setenv("REDIS_URL", "redis://10.0.0.5")
+		defer os.Unsetenv("REDIS_URL")
+
+		er, err := externalRedis(map[string]any{})
+		assert.NoError(t, err)
+		assert.Equal(t, "redis://10.0.0.5:6379/0", er.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := externalRedis(map[string]any{"redis": map[string]any{"url": "http://localhost"}})
+		assert.Error(t, err)
+		_, err = externalRedis(map[string]any{"redis": map[string]any{"url": "redis://localhost", "db": "x"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestRedisConfig(t *testing.T) {
+	rc, err := redisConfig(map[string]any{"redis": map[string]any{"url": "redis://localhost"}})
+	assert.NoError(t, err)
+	assert.Nil(t, rc)
+
+	rc, err = redisConfig(map[string]any{
+		"redis": map[string]any{
+			"save":        []any{"900 1", "300 10"},
+			"appendonly":  true,
+			"appendfsync": "always",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"900 1", "300 10"}, rc.Save)
+	assert.True(t, rc.AppendOnly)
+	assert.Equal(t, "always", rc.AppendFsync)
+
+	// an empty list turns snapshots off
+	rc, err = redisConfig(map[string]any{"redis": map[string]any{"save": []any{}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, rc.Save)
+
+	_, err = redisConfig(map[string]any{"redis": map[string]any{"save": "900 1"}})
+	assert.Error(t, err)
+	_, err = redisConfig(map[string]any{"redis": map[string]any{"save": []any{"900"}}})
+	assert.Error(t, err)
+	_, err = redisConfig(map[string]any{"redis": map[string]any{"appendonly": "yes"}})
+	assert.Error(t, err)
+	_, err = redisConfig(map[string]any{"redis": map[string]any{"appendfsync": "sometimes"}})
+	assert.Error(t, err)
+}
+
+func TestReplication(t *testing.T) {
+	repl, replica, err := replication(map[string]any{})
+	assert.NoError(t, err)
+	assert.Nil(t, repl)
+	assert.Nil(t, replica)
+
+	cfg := map[string]any{
+		"replication": map[string]any{
+			"listen":         "10.0.0.2:7421",
+			"password":       "sekrit",
+			"primary":        "10.0.0.1:7419",
+			"primary_redis":  "10.0.0.1:7421",
+			"failover_after": int64(30),
+		},
+	}
+	repl, replica, err = replication(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:7421", repl.Primary)
+	assert.Equal(t, "sekrit", repl.Password)
+	assert.Equal(t, "10.0.0.1:7419", replica.Primary)
+	assert.Equal(t, 30*time.Second, replica.FailoverAfter)
+	assert.Equal(t, "********", cfg["replication"].(map[string]any)["password"])
+
+	// the primary
+	repl, replica, err = replication(map[string]any{
+		"replication": map[string]any{"listen": "10.0.0.1:7421", "password": "sekrit"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "", repl.Primary)
+	assert.Nil(t, replica)
+
+	_, _, err = replication(map[string]any{
+		"replication": map[string]any{"listen": "10.0.0.2:7421", "password": "sekrit", "primary": "10.0.0.1:7419"},
+	})
+	assert.Error(t, err)
+	_, _, err = replication(map[string]any{
+		"replication": map[string]any{"listen": ":7421", "password": "sekrit"},
+	})
+	assert.Error(t, err)
+	_, _, err = replication(map[string]any{
+		"replication": map[string]any{"listen": "10.0.0.1:7421"},
+	})
+	assert.Error(t, err)
+}
@@ -78,3 +78,25 @@ func TestPasswords(t *testing.T) {
 
 	os.Unsetenv("FAKTORY_SKIP_PASSWORD")
 }
+
+func TestTenantPasswords(t *testing.T) {
+	tenants, err := tenantPasswords(map[string]any{})
+	assert.NoError(t, err)
+	assert.Nil(t, tenants)
+
+	cfg := map[string]any{
+		"tenants": map[string]any{
+			"acme":   map[string]any{"password": "acme-pwd"},
+			"globex": map[string]any{"password": "globex-pwd"},
+		},
+	}
+	tenants, err = tenantPasswords(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"acme": "acme-pwd", "globex": "globex-pwd"}, tenants)
+	assert.Equal(t, "********", cfg["tenants"].(map[string]any)["acme"].(map[string]any)["password"])
+
+	_, err = tenantPasswords(map[string]any{"tenants": map[string]any{"acme": map[string]any{}}})
+	assert.Error(t, err)
+	_, err = tenantPasswords(map[string]any{"tenants": map[string]any{"acme": "acme-pwd"}})
+	assert.Error(t, err)
+}
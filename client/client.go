@@ -19,6 +19,9 @@ const (
 	// This is the protocol version supported by this client.
 	// The server might be running an older or newer version.
 	ExpectedProtocolVersion = 2
+
+	// The longest Result can wait for a job, the server refuses more.
+	MaxResultWait = 60 * time.Second
 )
 
 var (
@@ -61,6 +64,9 @@ type ClientData struct {
 	// The server can reject this connection if the version will not work
 	// The server advertises its protocol version in the HI.
 	Version int `json:"v"`
+	// How the server orders queues for each FETCH: "strict" (the
+	// default), "weighted" or "roundrobin".
+	FetchMode string `json:"fetch_mode,omitempty"`
 }
 
 type Server struct {
@@ -69,6 +75,11 @@ type Server struct {
 	Password string
 	Timeout  time.Duration
 	TLS      *tls.Config
+	// Every server of a primary/replica pair, tried in order in
+	// place of Address.  Dial connects to the first reachable server
+	// which isn't a replica, so clients follow a failover when they
+	// reconnect.
+	Addresses []string
 }
 
 func (s *Server) Open() (*Client, error) {
@@ -87,39 +98,49 @@ FOO_URL=tcp://:mypassword@faktory.example.com:7419`)
 
 		uval, ok := os.LookupEnv(val)
 		if ok {
-			uri, err := url.Parse(uval)
-			if err != nil {
-				return err
-			}
-			s.Network = uri.Scheme
-			s.Address = fmt.Sprintf("%s:%s", uri.Hostname(), uri.Port())
-			if uri.User != nil {
-				s.Password, _ = uri.User.Password()
-			}
-			return nil
+			return s.parseURL(uval)
 		}
 		return fmt.Errorf("FAKTORY_PROVIDER set to invalid value: %s", val)
 	}
 
 	uval, ok := os.LookupEnv("FAKTORY_URL")
 	if ok {
-		uri, err := url.Parse(uval)
+		return s.parseURL(uval)
+	}
+
+	return nil
+}
+
+// parseURL reads a URL, or a comma separated list of them for a
+// primary/replica pair:
+//
+//	tcp://:mypassword@faktory-1:7419,tcp://:mypassword@faktory-2:7419
+func (s *Server) parseURL(uval string) error {
+	uvals := strings.Split(uval, ",")
+	s.Addresses = nil
+	for idx, val := range uvals {
+		uri, err := url.Parse(strings.TrimSpace(val))
 		if err != nil {
 			return err
 		}
+		addr := fmt.Sprintf("%s:%s", uri.Hostname(), uri.Port())
+		if len(uvals) > 1 {
+			s.Addresses = append(s.Addresses, addr)
+		}
+		if idx > 0 {
+			continue
+		}
 		s.Network = uri.Scheme
-		s.Address = fmt.Sprintf("%s:%s", uri.Hostname(), uri.Port())
+		s.Address = addr
 		if uri.User != nil {
 			s.Password, _ = uri.User.Password()
 		}
-		return nil
 	}
-
 	return nil
 }
 
 func DefaultServer() *Server {
-	return &Server{"tcp", "localhost:7419", "", 1 * time.Second, &tls.Config{}}
+	return &Server{"tcp", "localhost:7419", "", 1 * time.Second, &tls.Config{}, nil}
 }
 
 // Open connects to a Faktory server based on
@@ -148,18 +169,35 @@ func Open() (*Client, error) {
 //
 //	client.Dial(client.Localhost, "topsecret")
 func Dial(srv *Server, password string) (*Client, error) {
+	if len(srv.Addresses) == 0 {
+		// a single server, even a replica so it can be promoted
+		return dial(srv, srv.Address, password, true)
+	}
+
+	var err error
+	for _, addr := range srv.Addresses {
+		var cl *Client
+		cl, err = dial(srv, addr, password, false)
+		if err == nil {
+			return cl, nil
+		}
+	}
+	return nil, fmt.Errorf("No primary in %s: %w", strings.Join(srv.Addresses, ", "), err)
+}
+
+func dial(srv *Server, addr string, password string, allowReplica bool) (*Client, error) {
 	client := emptyClientData()
 
 	var err error
 	var conn net.Conn
 	dial := &net.Dialer{Timeout: srv.Timeout}
 	if srv.Network == "tcp+tls" {
-		conn, err = tls.DialWithDialer(dial, "tcp", srv.Address, srv.TLS)
+		conn, err = tls.DialWithDialer(dial, "tcp", addr, srv.TLS)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		conn, err = dial.Dial(srv.Network, srv.Address)
+		conn, err = dial.Dial(srv.Network, addr)
 		if err != nil {
 			return nil, err
 		}
@@ -186,6 +224,10 @@ func Dial(srv *Server, password string) (*Client, error) {
 			conn.Close()
 			return nil, err
 		}
+		if replica, _ := hi["replica"].(bool); replica && !allowReplica {
+			conn.Close()
+			return nil, fmt.Errorf("%s is a replica", addr)
+		}
 		v, ok := hi["v"].(float64)
 		if ok {
 			if ExpectedProtocolVersion != int(v) {
@@ -225,7 +267,7 @@ func Dial(srv *Server, password string) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{Options: client, Location: srv.Address, conn: conn, rdr: r, wtr: w}, nil
+	return &Client{Options: client, Location: addr, conn: conn, rdr: r, wtr: w}, nil
 }
 
 func (c *Client) Close() error {
@@ -242,6 +284,47 @@ func (c *Client) Ack(jid string) error {
 	return ok(c.rdr)
 }
 
+// AckResult acknowledges the job and stores the given result,
+// which must marshal to JSON, for the producer to retrieve.
+func (c *Client) AckResult(jid string, result interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"jid":    jid,
+		"result": result,
+	})
+	if err != nil {
+		return err
+	}
+	err = writeLine(c.wtr, "ACK", data)
+	if err != nil {
+		return err
+	}
+
+	return ok(c.rdr)
+}
+
+// Result returns the result of the given job, waiting up to timeout,
+// at most MaxResultWait, for the job to complete.  The timeout is
+// rounded up to whole seconds.  Returns nil if the job has no result.
+func (c *Client) Result(jid string, timeout time.Duration) (json.RawMessage, error) {
+	if timeout < 0 || timeout > MaxResultWait {
+		return nil, fmt.Errorf("Invalid result timeout %s, must be 0-%s", timeout, MaxResultWait)
+	}
+	secs := int((timeout + time.Second - 1) / time.Second)
+	err := writeLine(c.wtr, "RESULT", []byte(fmt.Sprintf("%s %d", jid, secs)))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readResponse(c.rdr)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
 func (c *Client) Push(job *Job) error {
 	jobytes, err := json.Marshal(job)
 	if err != nil {
@@ -254,6 +337,58 @@ func (c *Client) Push(job *Job) error {
 	return ok(c.rdr)
 }
 
+// PushBulk pushes many jobs in a single round trip.  It returns
+// the error message for each rejected job, keyed by its index
+// within jobs.
+func (c *Client) PushBulk(jobs []*Job) (map[int]string, error) {
+	jobytes, err := json.Marshal(jobs)
+	if err != nil {
+		return nil, err
+	}
+	err = writeLine(c.wtr, "PUSHB", jobytes)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readResponse(c.rdr)
+	if err != nil {
+		return nil, err
+	}
+	rejected := map[int]string{}
+	err = json.Unmarshal(data, &rejected)
+	if err != nil {
+		return nil, err
+	}
+	return rejected, nil
+}
+
+// FetchN reserves up to count jobs from the given queues, draining
+// each queue in order.  Returns an empty slice if no jobs are available.
+func (c *Client) FetchN(count int, q ...string) ([]*Job, error) {
+	if len(q) == 0 {
+		return nil, fmt.Errorf("FetchN must be called with one or more queue names")
+	}
+
+	err := writeLine(c.wtr, "FETCH", []byte(fmt.Sprintf("count=%d %s", count, strings.Join(q, " "))))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readResponse(c.rdr)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*Job{}
+	if len(data) == 0 {
+		return jobs, nil
+	}
+	err = json.Unmarshal(data, &jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
 func (c *Client) Fetch(q ...string) (*Job, error) {
 	if len(q) == 0 {
 		return nil, fmt.Errorf("Fetch must be called with one or more queue names")
@@ -345,6 +480,117 @@ func (c *Client) Info() (map[string]interface{}, error) {
 	return hash, nil
 }
 
+// Progress reports a job's progress to Faktory while it is executing.
+// If reserveUntil is non-zero, the job's reservation is extended until then.
+func (c *Client) Progress(jid string, percent int, desc string, reserveUntil time.Time) error {
+	progress := map[string]interface{}{
+		"jid":     jid,
+		"percent": percent,
+		"desc":    desc,
+	}
+	if !reserveUntil.IsZero() {
+		progress["reserve_until"] = reserveUntil.UTC().Format(time.RFC3339Nano)
+	}
+
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	err = writeLine(c.wtr, "PROGRESS", data)
+	if err != nil {
+		return err
+	}
+	return ok(c.rdr)
+}
+
+// Extend tells Faktory the job is still running and it should not
+// be reaped for another given number of seconds.
+func (c *Client) Extend(jid string, seconds int) error {
+	err := writeLine(c.wtr, "EXTEND", []byte(fmt.Sprintf("%s %d", jid, seconds)))
+	if err != nil {
+		return err
+	}
+	return ok(c.rdr)
+}
+
+// Cancel removes a pending job.  If a worker has already reserved
+// the job, its next BEAT lists the JID and its ACK is rejected.
+func (c *Client) Cancel(jid string) error {
+	err := writeLine(c.wtr, "CANCEL", []byte(jid))
+	if err != nil {
+		return err
+	}
+	return ok(c.rdr)
+}
+
+// Backup asks the server to archive its data, returning a
+// description of the new backup.
+func (c *Client) Backup() (map[string]interface{}, error) {
+	err := writeLine(c.wtr, "BACKUP", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readResponse(c.rdr)
+	if err != nil {
+		return nil, err
+	}
+
+	var hash map[string]interface{}
+	err = json.Unmarshal(data, &hash)
+	if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// Promote makes a replica server the primary.
+func (c *Client) Promote() error {
+	err := writeLine(c.wtr, "PROMOTE", nil)
+	if err != nil {
+		return err
+	}
+	return ok(c.rdr)
+}
+
+// Reschedule changes when a scheduled job will run.  A zero
+// time runs the job now.
+func (c *Client) Reschedule(jid string, at time.Time) error {
+	when := "now"
+	if !at.IsZero() {
+		when = at.UTC().Format(time.RFC3339Nano)
+	}
+	err := writeLine(c.wtr, "RESCHEDULE", []byte(jid+" "+when))
+	if err != nil {
+		return err
+	}
+	return ok(c.rdr)
+}
+
+// Track returns the current status of the given job,
+// nil if Faktory does not know about the JID.
+func (c *Client) Track(jid string) (*JobStatus, error) {
+	err := writeLine(c.wtr, "TRACK", []byte(jid))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readResponse(c.rdr)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var status JobStatus
+	err = json.Unmarshal(data, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
 func (c *Client) Generic(cmdline string) (string, error) {
 	err := writeLine(c.wtr, cmdline, nil)
 	if err != nil {
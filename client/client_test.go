@@ -82,6 +82,78 @@ func TestClientOperations(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Contains(t, <-req, "FAIL")
 
+		resp <- "+OK\r\n"
+		err = cl.Progress("123456", 50, "halfway", time.Time{})
+		assert.NoError(t, err)
+		line := <-req
+		assert.Contains(t, line, "PROGRESS")
+		assert.Contains(t, line, "halfway")
+		assert.NotContains(t, line, "reserve_until")
+
+		resp <- "+OK\r\n"
+		err = cl.Progress("123456", 75, "", time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Contains(t, <-req, "reserve_until")
+
+		resp <- "$32\r\n{\"1\":\"jobs must have a jobtype\"}\r\n"
+		rejected, err := cl.PushBulk([]*Job{NewJob("Bulk", 1), {Jid: "bad"}})
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]string{1: "jobs must have a jobtype"}, rejected)
+		assert.Contains(t, <-req, "PUSHB [")
+
+		resp <- "$2\r\n[]\r\n"
+		jobs, err := cl.FetchN(10, "q1", "q2")
+		assert.NoError(t, err)
+		assert.Empty(t, jobs)
+		assert.Contains(t, <-req, "FETCH count=10 q1 q2")
+
+		resp <- "+OK\r\n"
+		err = cl.Extend("123456", 600)
+		assert.NoError(t, err)
+		assert.Contains(t, <-req, "EXTEND 123456 600")
+
+		resp <- "+OK\r\n"
+		err = cl.Reschedule("123456", time.Time{})
+		assert.NoError(t, err)
+		assert.Contains(t, <-req, "RESCHEDULE 123456 now")
+
+		resp <- "+OK\r\n"
+		err = cl.Cancel("123456")
+		assert.NoError(t, err)
+		assert.Contains(t, <-req, "CANCEL 123456")
+
+		resp <- "+OK\r\n"
+		err = cl.AckResult("123456", map[string]int{"sum": 6})
+		assert.NoError(t, err)
+		assert.Contains(t, <-req, `"result":{"sum":6}`)
+
+		resp <- "$9\r\n{\"sum\":6}\r\n"
+		result, err := cl.Result("123456", 5*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"sum":6}`, string(result))
+		assert.Contains(t, <-req, "RESULT 123456 5")
+
+		resp <- "$-1\r\n"
+		result, err = cl.Result("123456", 500*time.Millisecond)
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+		assert.Contains(t, <-req, "RESULT 123456 1")
+
+		_, err = cl.Result("123456", 2*time.Minute)
+		assert.Error(t, err)
+
+		resp <- "$-1\r\n"
+		status, err := cl.Track("123456")
+		assert.NoError(t, err)
+		assert.Nil(t, status)
+		assert.Contains(t, <-req, "TRACK 123456")
+
+		resp <- "$36\r\n{\"jid\":\"123456\",\"state\":\"completed\"}\r\n"
+		status, err = cl.Track("123456")
+		assert.NoError(t, err)
+		assert.Equal(t, "completed", status.State)
+		assert.Contains(t, <-req, "TRACK")
+
 		resp <- "$2\r\n{}\r\n"
 		hash, err := cl.Info()
 		assert.NoError(t, err)
@@ -141,6 +213,40 @@ func stacks() {
 	os.Exit(-10)
 }
 
+func TestServerAddresses(t *testing.T) {
+	os.Unsetenv("FAKTORY_PROVIDER")
+	os.Setenv("FAKTORY_URL", "tcp://:foobar@faktory-1:7419,tcp://:foobar@faktory-2:7419")
+	defer os.Unsetenv("FAKTORY_URL")
+
+	srv := DefaultServer()
+	err := srv.ReadFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "faktory-1:7419", srv.Address)
+	assert.Equal(t, []string{"faktory-1:7419", "faktory-2:7419"}, srv.Addresses)
+	assert.Equal(t, "foobar", srv.Password)
+
+	// the replica is skipped for the primary behind it
+	withFakeServer(t, func(req, resp chan string, addr string) {
+		replica, err := net.Listen("tcp", "localhost:44435")
+		assert.NoError(t, err)
+		defer replica.Close()
+		go func() {
+			conn, err := replica.Accept()
+			if err == nil {
+				conn.Write([]byte("+HI {\"v\":2,\"replica\":true}\r\n"))
+				conn.Close()
+			}
+		}()
+
+		srv.Addresses = []string{"localhost:44433", "localhost:44435", addr}
+		resp <- "+OK\r\n"
+		cl, err := Dial(srv, "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, addr, cl.Location)
+		<-req
+	})
+}
+
 func TestPasswordHashing(t *testing.T) {
 	iterations := 1545
 	pwd := "foobar"
@@ -3,6 +3,7 @@ package client
 import (
 	cryptorand "crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	mathrand "math/rand"
 	"time"
 )
@@ -33,6 +34,43 @@ type Job struct {
 	Backtrace  int                    `json:"backtrace,omitempty"`
 	Failure    *Failure               `json:"failure,omitempty"`
 	Custom     map[string]interface{} `json:"custom,omitempty"`
+	// JIDs of jobs which must succeed before this job is enqueued
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Jobs to run one after another once this job succeeds
+	Chain []*Job `json:"chain,omitempty"`
+	// Append the previous chain step's result to this job's args
+	PassResult bool `json:"pass_result,omitempty"`
+	// read-only, set by the server for each step of a chain
+	ChainID string `json:"chain_id,omitempty"`
+
+	// decoded with an explicit "retry":0, which the queue's default
+	// mustn't replace
+	noRetry bool
+}
+
+// Progress is reported by a worker while it executes a long-running job.
+type Progress struct {
+	Percent   int    `json:"percent"`
+	Desc      string `json:"desc,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// JobStatus describes where a job currently is within Faktory.
+// State is one of "enqueued", "scheduled", "waiting", "working",
+// "retry", "dead", "completed" or "canceled".  Completed and canceled
+// jobs are only remembered for a short time and carry no payload.
+type JobStatus struct {
+	Jid      string `json:"jid"`
+	State    string `json:"state"`
+	Location string `json:"location,omitempty"`
+	// Timestamp associated with the state: when a scheduled job or retry will run,
+	// when a reservation or dead job expires, when a job completed.
+	At       string    `json:"at,omitempty"`
+	Wid      string    `json:"wid,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+	Job      *Job      `json:"job,omitempty"`
+	// The result given by the worker when it acknowledged the job, if any.
+	Result json.RawMessage `json:"result,omitempty"`
 }
 
 func NewJob(jobtype string, args ...interface{}) *Job {
@@ -73,3 +111,38 @@ func (j *Job) SetCustom(name string, value interface{}) {
 
 	j.Custom[name] = value
 }
+
+// RetryGiven is false if the job leaves Retry to the queue's default:
+// it's 0 and wasn't decoded from an explicit "retry":0.
+func (j *Job) RetryGiven() bool {
+	return j.Retry != 0 || j.noRetry
+}
+
+type plainJob Job
+
+func (j *Job) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*plainJob
+		Retry *int `json:"retry"`
+	}{plainJob: (*plainJob)(j)}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+	if aux.Retry != nil {
+		j.Retry = *aux.Retry
+	}
+	j.noRetry = aux.Retry != nil && *aux.Retry == 0
+	return nil
+}
+
+// MarshalJSON keeps an explicit retry of 0, which omitempty drops.
+func (j Job) MarshalJSON() ([]byte, error) {
+	if !j.noRetry || j.Retry != 0 {
+		return json.Marshal((*plainJob)(&j))
+	}
+	return json.Marshal(struct {
+		*plainJob
+		Retry int `json:"retry"`
+	}{plainJob: (*plainJob)(&j)})
+}
@@ -14,3 +14,32 @@ func TestJobPriority(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, string(data), "priority")
 }
+
+func TestJobRetry(t *testing.T) {
+	var job Job
+	assert.NoError(t, json.Unmarshal([]byte(`{"jid":"abc","jobtype":"yo","args":[]}`), &job))
+	assert.Equal(t, 0, job.Retry)
+	assert.False(t, job.RetryGiven())
+
+	// an explicit 0 survives a round trip
+	job = Job{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"jid":"abc","jobtype":"yo","args":[],"retry":0}`), &job))
+	assert.Equal(t, 0, job.Retry)
+	assert.True(t, job.RetryGiven())
+	data, err := json.Marshal(&job)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"retry":0`)
+	var again Job
+	assert.NoError(t, json.Unmarshal(data, &again))
+	assert.True(t, again.RetryGiven())
+
+	job.Retry = 3
+	data, err = json.Marshal(job)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"retry":3`)
+
+	assert.True(t, NewJob("yo").RetryGiven())
+	data, err = json.Marshal(&Job{Jid: "abc", Type: "yo"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "retry")
+}
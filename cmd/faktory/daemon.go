@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/hunter-io/faktory/cli"
@@ -25,6 +27,15 @@ func main() {
 	util.InitLogger(opts.LogLevel)
 	util.Debugf("Options: %v", opts)
 
+	if flag.NArg() > 0 {
+		err := cli.RunCommand(opts, flag.Args())
+		if err != nil {
+			util.Error("Command failed", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	s, stopper, err := cli.BuildServer(opts)
 	if stopper != nil {
 		defer stopper()
@@ -0,0 +1,178 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * The Web UI's actions on jobs, done here rather than directly on the
+ * store so the JID index follows the jobs they move or delete.  Each
+ * acts on the given entries, or on every entry if there are none.
+ */
+
+func (m *manager) RemoveJobs(set storage.SortedSet, keys [][]byte) error {
+	if len(keys) == 0 {
+		jids := []string{}
+		err := set.Each(func(_ int, entry storage.SortedEntry) error {
+			job, err := entry.Job()
+			if err == nil {
+				jids = append(jids, job.Jid)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = set.Clear()
+		if err != nil {
+			return err
+		}
+		forget(m.store, jids...)
+		return nil
+	}
+
+	for _, key := range keys {
+		entry, err := set.Get(key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+		job, err := entry.Job()
+		if err != nil {
+			return err
+		}
+		ok, err := set.Remove(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			forget(m.store, job.Jid)
+		}
+	}
+	return nil
+}
+
+func (m *manager) EnqueueJobs(set storage.SortedSet, keys [][]byte) error {
+	if len(keys) == 0 {
+		return set.Each(func(_ int, entry storage.SortedEntry) error {
+			return m.enqueueEntry(set, entry)
+		})
+	}
+
+	for _, key := range keys {
+		entry, err := set.Get(key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			// race condition, element was removed already
+			continue
+		}
+		err = m.enqueueEntry(set, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *manager) enqueueEntry(set storage.SortedSet, entry storage.SortedEntry) error {
+	job, err := entry.Job()
+	if err != nil {
+		return err
+	}
+	key, err := entry.Key()
+	if err != nil {
+		return err
+	}
+	ok, err := set.Remove(key)
+	if err != nil || !ok {
+		return err
+	}
+	return m.enqueue(job)
+}
+
+func (m *manager) KillJobs(set storage.SortedSet, keys [][]byte) error {
+	expiry := time.Now().Add(DeadTTL)
+	kill := func(entry storage.SortedEntry) error {
+		job, err := entry.Job()
+		if err != nil {
+			return err
+		}
+		err = set.MoveTo(m.store.Dead(), entry, expiry)
+		if err != nil {
+			return err
+		}
+		trackDead(m.store, job.Jid, expiry)
+		return nil
+	}
+
+	if len(keys) == 0 {
+		return set.Each(func(_ int, entry storage.SortedEntry) error {
+			return kill(entry)
+		})
+	}
+	for _, key := range keys {
+		entry, err := set.Get(key)
+		if err != nil {
+			return err
+		}
+		if entry != nil {
+			err = kill(entry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *manager) RemoveQueued(q storage.Queue, payloads [][]byte) error {
+	if len(payloads) == 0 {
+		jids := []string{}
+		err := q.Each(func(_ int, data []byte) error {
+			if jid := payloadJid(data); jid != "" {
+				jids = append(jids, jid)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		_, err = q.Clear()
+		if err != nil {
+			return err
+		}
+		forget(m.store, jids...)
+		return nil
+	}
+
+	for _, data := range payloads {
+		ok, err := q.Remove(data)
+		if err != nil {
+			return fmt.Errorf("remove from %s: %w", q.Name(), err)
+		}
+		if jid := payloadJid(data); ok && jid != "" {
+			forget(m.store, jid)
+		}
+	}
+	return nil
+}
+
+func payloadJid(data []byte) string {
+	var job client.Job
+	err := json.Unmarshal(data, &job)
+	if err != nil {
+		util.Warnf("Unable to parse job: %v", err)
+		return ""
+	}
+	return job.Jid
+}
@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+)
+
+// Limit the number of jobs in a single bulk push.
+const MaxBulkSize = 1000
+
+/*
+ * PushBulk validates each job just like Push but batches the Redis writes
+ * for jobs which can be enqueued immediately, one write per queue.
+ * Scheduled, dependent and chained jobs take the normal Push path.
+ *
+ * Rejections are keyed by the job's index within the given slice, JIDs
+ * may be missing or repeated.
+ */
+func (m *manager) PushBulk(jobs []*client.Job) map[int]error {
+	errs := map[int]error{}
+	batches := map[string][][]byte{}
+	indexes := map[string][]int{}
+
+	for idx, job := range jobs {
+		if job == nil {
+			errs[idx] = fmt.Errorf("push: missing job")
+			continue
+		}
+
+		err := m.prepare(job)
+		if err == nil {
+			err = m.checkMemory()
+		}
+		if err == nil {
+			err = m.checkFull(job, len(batches[job.Queue]))
+		}
+		if err != nil {
+			errs[idx] = err
+			continue
+		}
+
+		if !immediate(job) {
+			err = m.route(job)
+			if err != nil {
+				errs[idx] = err
+			}
+			continue
+		}
+
+		err = callMiddleware(m.pushChain, Ctx{context.Background(), job, m}, func() error {
+			job.EnqueuedAt = util.Nows()
+			data, err := json.Marshal(job)
+			if err != nil {
+				return err
+			}
+			batches[job.Queue] = append(batches[job.Queue], data)
+			indexes[job.Queue] = append(indexes[job.Queue], idx)
+			return nil
+		})
+		if err != nil {
+			errs[idx] = err
+		}
+	}
+
+	for name, batch := range batches {
+		q, err := m.store.GetQueue(name)
+		if err == nil {
+			err = q.PushAll(batch)
+		}
+		if err != nil {
+			for _, idx := range indexes[name] {
+				errs[idx] = fmt.Errorf("enqueue: push to queue %q: %w", name, err)
+			}
+			continue
+		}
+
+		locations := map[string][]byte{}
+		for _, idx := range indexes[name] {
+			locations[indexKey(jobs[idx].Jid)] = []byte(queuedLocation(q.Name()))
+		}
+		err = m.store.Raw().SetAll(locations, QueuedTTL)
+		if err != nil {
+			util.Warnf("Unable to index %d jobs: %v", len(locations), err)
+		}
+	}
+
+	return errs
+}
+
+// Can the prepared job go straight onto its queue?
+func immediate(job *client.Job) bool {
+	if len(job.Chain) > 0 || len(job.DependsOn) > 0 {
+		return false
+	}
+	if job.At != "" {
+		t, err := util.ParseTime(job.At)
+		if err != nil || t.After(time.Now()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushBulk(t *testing.T) {
+	withRedis(t, "bulk", func(t *testing.T, store storage.Store) {
+		store.Flush()
+		m := NewManager(store)
+
+		pushed := 0
+		m.AddMiddleware("push", func(next func() error, ctx Context) error {
+			if ctx.Job().Type == "Halted" {
+				return Halt("not today")
+			}
+			pushed++
+			return next()
+		})
+
+		jobs := []*client.Job{}
+		for i := 0; i < 10; i++ {
+			job := client.NewJob("BulkJob", i)
+			if i%2 == 0 {
+				job.Queue = "even"
+			}
+			jobs = append(jobs, job)
+		}
+		scheduled := client.NewJob("BulkJob", 10)
+		scheduled.At = util.Thens(time.Now().Add(time.Hour))
+		invalid := client.NewJob("", 11)
+		halted := client.NewJob("Halted", 12)
+		// a repeated JID is reported for each job
+		repeated := client.NewJob("", 13)
+		repeated.Jid = invalid.Jid
+		jobs = append(jobs, scheduled, invalid, halted, repeated, &client.Job{Type: "NoJid", Args: []interface{}{}}, nil)
+
+		errs := m.PushBulk(jobs)
+		assert.Len(t, errs, 5)
+		assert.Error(t, errs[11])
+		assert.Error(t, errs[12])
+		assert.Error(t, errs[13])
+		assert.Error(t, errs[14])
+		assert.Error(t, errs[15])
+		assert.Equal(t, 10, pushed)
+
+		even, err := store.GetQueue("even")
+		assert.NoError(t, err)
+		assert.EqualValues(t, 5, even.Size())
+		def, err := store.GetQueue("default")
+		assert.NoError(t, err)
+		assert.EqualValues(t, 5, def.Size())
+		assert.EqualValues(t, 1, store.Scheduled().Size())
+
+		val, err := store.Raw().Get(indexKey(jobs[0].Jid))
+		assert.NoError(t, err)
+		assert.Equal(t, "queue even", string(val))
+
+		data, err := def.Pop()
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), jobs[1].Jid)
+		assert.Contains(t, string(data), "enqueued_at")
+	})
+}
@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * CANCEL removes a pending job from whichever queue or set holds it.
+ * A job already reserved by a worker can't be taken back, so its
+ * reservation is marked canceled instead: the worker's BEAT lists it,
+ * its ACK is rejected with ErrCanceled and it isn't retried on FAIL or
+ * when the reservation expires.
+ *
+ * Canceled JIDs are remembered like completed ones and anything
+ * waiting on a canceled job is sent to the morgue.
+ */
+
+var ErrCanceled = errors.New("job canceled")
+
+func (m *manager) Cancel(jid string) error {
+	status, err := m.Lookup(jid)
+	if err != nil {
+		return fmt.Errorf("cancel: %w", err)
+	}
+	if status == nil {
+		return fmt.Errorf("cancel: unknown job %s", jid)
+	}
+
+	switch status.State {
+	case "working":
+		return m.cancelReservation(jid)
+	case "enqueued":
+		name, payload, err := m.queued(jid)
+		if err != nil {
+			return fmt.Errorf("cancel: %w", err)
+		}
+		removed := false
+		if name != "" {
+			q, err := m.store.GetQueue(name)
+			if err != nil {
+				return fmt.Errorf("cancel: %w", err)
+			}
+			removed, err = q.Remove(payload)
+			if err != nil {
+				return fmt.Errorf("cancel: %w", err)
+			}
+		}
+		if !removed {
+			return fmt.Errorf("cancel: job %s has moved, try again", jid)
+		}
+	case "scheduled", "retry", "waiting":
+		ok, err := m.sortedSet(status.Location).Remove([]byte(status.At + "|" + jid))
+		if err != nil {
+			return fmt.Errorf("cancel: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("cancel: job %s has moved, try again", jid)
+		}
+	default:
+		return fmt.Errorf("cancel: job %s is %s", jid, status.State)
+	}
+
+	m.finishCancel(jid)
+	return nil
+}
+
+func (m *manager) cancelReservation(jid string) error {
+	m.workingMutex.RLock()
+	res, ok := m.workingMap[jid]
+	m.workingMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("cancel: job %s has moved, try again", jid)
+	}
+	if res.Canceled {
+		return nil
+	}
+	return m.updateReservation(res, func(r *Reservation) {
+		r.Canceled = true
+	})
+}
+
+// finishCancel records the job as canceled once it is gone.
+func (m *manager) finishCancel(jid string) {
+	err := m.store.Canceled()
+	if err != nil {
+		util.Warnf("Unable to count %s as canceled: %v", jid, err)
+	}
+	canceled(m.store, jid)
+	m.notifyWaiters(jid)
+	m.buryDependents(jid, fmt.Sprintf("Parent job %s was canceled", jid))
+}
+
+func (m *manager) CanceledJobs(wid string) []string {
+	m.workingMutex.RLock()
+	defer m.workingMutex.RUnlock()
+
+	jids := []string{}
+	for jid, res := range m.workingMap {
+		if res.Wid == wid && res.Canceled {
+			jids = append(jids, jid)
+		}
+	}
+	sort.Strings(jids)
+	return jids
+}
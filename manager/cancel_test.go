@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancel(t *testing.T) {
+	withRedis(t, "cancel", func(t *testing.T, store storage.Store) {
+
+		t.Run("Pending", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			enqueued := client.NewJob("Invoice", 1)
+			scheduled := client.NewJob("Invoice", 2)
+			scheduled.At = util.Thens(time.Now().Add(time.Hour))
+			waiting := client.NewJob("Receipt", 3)
+			waiting.DependsOn = []string{enqueued.Jid}
+			for _, job := range []*client.Job{enqueued, scheduled, waiting} {
+				assert.NoError(t, m.Push(job))
+			}
+
+			assert.NoError(t, m.Cancel(scheduled.Jid))
+			assert.EqualValues(t, 0, store.Scheduled().Size())
+
+			// canceling a parent buries its dependents
+			assert.NoError(t, m.Cancel(enqueued.Jid))
+			q, err := store.GetQueue("default")
+			assert.NoError(t, err)
+			assert.EqualValues(t, 0, q.Size())
+			assert.EqualValues(t, 0, store.Waiting().Size())
+			assert.EqualValues(t, 1, store.Dead().Size())
+			assert.EqualValues(t, 2, store.TotalCanceled())
+
+			status, err := m.Lookup(enqueued.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "canceled", status.State)
+
+			result, err := m.Result(context.Background(), enqueued.Jid)
+			assert.NoError(t, err)
+			assert.Nil(t, result)
+
+			assert.Error(t, m.Cancel(enqueued.Jid))
+			assert.Error(t, m.Cancel(waiting.Jid))
+			assert.Error(t, m.Cancel("nope"))
+		})
+
+		t.Run("Reserved", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("Invoice", 1)
+			assert.NoError(t, m.Push(job))
+			_, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.Empty(t, m.CanceledJobs("workerId"))
+
+			assert.NoError(t, m.Cancel(job.Jid))
+			assert.NoError(t, m.Cancel(job.Jid))
+			assert.Equal(t, []string{job.Jid}, m.CanceledJobs("workerId"))
+			assert.Empty(t, m.CanceledJobs("otherWorker"))
+			assert.EqualValues(t, 0, store.TotalCanceled())
+
+			_, err = m.Acknowledge(job.Jid)
+			assert.True(t, errors.Is(err, ErrCanceled), "got %v", err)
+			assert.EqualValues(t, 0, store.TotalProcessed())
+			assert.EqualValues(t, 1, store.TotalCanceled())
+			assert.Empty(t, m.CanceledJobs("workerId"))
+
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "canceled", status.State)
+		})
+
+		t.Run("NotRetried", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("Invoice", 1)
+			assert.NoError(t, m.Push(job))
+			_, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.NoError(t, m.Cancel(job.Jid))
+
+			err = m.Fail(&FailPayload{Jid: job.Jid, ErrorType: "Interrupt"})
+			assert.NoError(t, err)
+			assert.EqualValues(t, 0, store.Retries().Size())
+			assert.EqualValues(t, 0, store.TotalFailures())
+			assert.EqualValues(t, 1, store.TotalCanceled())
+		})
+	})
+}
@@ -0,0 +1,169 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * A chain is a sequence of jobs pushed together: the first job carries
+ * the rest in its `chain` and each ACK pushes the next step, passing
+ * along the remainder.  A step with `pass_result` gets the previous
+ * step's result appended to its args.
+ *
+ * Each chain is identified by the JID of its first step and recorded
+ * in the KV as "chain:<id>" so the Web UI can show every step, even
+ * those which haven't been pushed yet.  If a step dies, the chain stops.
+ */
+
+// Limit chains so a single PUSH can't create unbounded work.
+const MaxChainLength = 100
+
+type ChainStep struct {
+	Jid  string `json:"jid"`
+	Type string `json:"jobtype"`
+	// nil until read by Chain()
+	Status *client.JobStatus `json:"status,omitempty"`
+}
+
+func chainKey(id string) string {
+	return "chain:" + id
+}
+
+func (m *manager) startChain(job *client.Job) error {
+	if len(job.Chain) > MaxChainLength {
+		return fmt.Errorf("push: chain too long, %d steps maximum", MaxChainLength)
+	}
+
+	job.ChainID = job.Jid
+	steps := []ChainStep{{Jid: job.Jid, Type: job.Type}}
+	for idx, step := range job.Chain {
+		if step == nil {
+			return invalidStep(idx, errors.New("missing"))
+		}
+		if len(step.Chain) > 0 {
+			return invalidStep(idx, errors.New("cannot have its own chain"))
+		}
+		if step.Args == nil {
+			step.Args = []interface{}{}
+		}
+		if step.Jid == "" {
+			step.Jid = util.RandomJid()
+		}
+		step.ChainID = job.ChainID
+		// catch a bad step now rather than when it's pushed on ACK
+		err := m.prepare(step)
+		if err != nil {
+			return invalidStep(idx, err)
+		}
+		steps = append(steps, ChainStep{Jid: step.Jid, Type: step.Type})
+	}
+
+	data, err := json.Marshal(steps)
+	if err != nil {
+		return fmt.Errorf("push: marshal chain: %w", err)
+	}
+	err = m.store.Raw().SetEx(chainKey(job.ChainID), data, DeadTTL)
+	if err != nil {
+		return fmt.Errorf("push: save chain: %w", err)
+	}
+	return nil
+}
+
+// invalidStep rejects the chain for the given step, keeping the
+// name of any limit it broke.
+func invalidStep(idx int, err error) error {
+	var invalid *InvalidError
+	if errors.As(err, &invalid) {
+		return &InvalidError{invalid.Rule, fmt.Errorf("push: chain step %d: %w", idx+1, invalid.Err)}
+	}
+	return &InvalidError{"chain", fmt.Errorf("push: chain step %d: %w", idx+1, err)}
+}
+
+// advanceChain is ack middleware which pushes the next step of a chain.
+func (m *manager) advanceChain(next func() error, ctx Context) error {
+	err := next()
+	if err != nil {
+		return err
+	}
+
+	job := ctx.Job()
+	if job.ChainID == "" {
+		return nil
+	}
+	if len(job.Chain) == 0 {
+		// all done, remember the chain as long as its completed steps
+		data, err := m.store.Raw().Get(chainKey(job.ChainID))
+		if err == nil && data != nil {
+			err = m.store.Raw().SetEx(chainKey(job.ChainID), data, CompletedTTL)
+		}
+		if err != nil {
+			util.Warnf("Unable to expire chain %s: %v", job.ChainID, err)
+		}
+		return nil
+	}
+
+	step := job.Chain[0]
+	step.Chain = job.Chain[1:]
+	step.ChainID = job.ChainID
+	if step.PassResult {
+		var result interface{}
+		data, err := m.store.Raw().Get(resultKey(job.Jid))
+		if err == nil && data != nil {
+			err = json.Unmarshal(data, &result)
+		}
+		if err != nil {
+			util.Warnf("Unable to read result of %s: %v", job.Jid, err)
+		}
+		step.Args = append(step.Args, result)
+	}
+
+	// the chain was accepted as a whole, don't apply backpressure to its steps
+	err = m.prepare(step)
+	if err == nil {
+		err = m.route(step)
+	}
+	if err != nil {
+		// the job itself succeeded, don't fail the ACK
+		util.Warnf("Unable to push chain %s step %s: %v", job.ChainID, step.Jid, err)
+	}
+	return nil
+}
+
+func (m *manager) Chain(id string) ([]*ChainStep, error) {
+	data, err := m.store.Raw().Get(chainKey(id))
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var steps []*ChainStep
+	err = json.Unmarshal(data, &steps)
+	if err != nil {
+		return nil, err
+	}
+
+	// work backwards: once a later step exists, every earlier
+	// step must have completed even if we've forgotten about it.
+	started := false
+	for idx := len(steps) - 1; idx >= 0; idx-- {
+		step := steps[idx]
+		status, err := m.Lookup(step.Jid)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case status != nil:
+			started = true
+		case started:
+			status = &client.JobStatus{Jid: step.Jid, State: "completed"}
+		default:
+			status = &client.JobStatus{Jid: step.Jid, State: "pending"}
+		}
+		step.Status = status
+	}
+	return steps, nil
+}
@@ -0,0 +1,133 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	withRedis(t, "chain", func(t *testing.T, store storage.Store) {
+
+		t.Run("Sequence", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("Fetch", "http://example.com")
+			transform := client.NewJob("Transform")
+			transform.PassResult = true
+			upload := &client.Job{Type: "Upload", Queue: "uploads"}
+			job.Chain = []*client.Job{transform, upload}
+			assert.NoError(t, m.Push(job))
+
+			steps, err := m.Chain(job.Jid)
+			assert.NoError(t, err)
+			assert.Len(t, steps, 3)
+			assert.Equal(t, "enqueued", steps[0].Status.State)
+			assert.Equal(t, "pending", steps[1].Status.State)
+			assert.Equal(t, "Upload", steps[2].Type)
+			assert.NotEmpty(t, steps[2].Jid)
+
+			fetched, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.Equal(t, job.Jid, fetched.Jid)
+			_, err = m.AcknowledgeResult(fetched.Jid, []byte(`{"size":123}`), time.Minute)
+			assert.NoError(t, err)
+
+			fetched, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.Equal(t, transform.Jid, fetched.Jid)
+			assert.Equal(t, job.Jid, fetched.ChainID)
+			assert.Len(t, fetched.Args, 1)
+			assert.Equal(t, map[string]interface{}{"size": float64(123)}, fetched.Args[0])
+			assert.Len(t, fetched.Chain, 1)
+
+			steps, err = m.Chain(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "completed", steps[0].Status.State)
+			assert.Equal(t, "working", steps[1].Status.State)
+			assert.Equal(t, "pending", steps[2].Status.State)
+
+			_, err = m.Acknowledge(fetched.Jid)
+			assert.NoError(t, err)
+
+			fetched, err = m.Fetch(context.Background(), "workerId", "uploads")
+			assert.NoError(t, err)
+			assert.Equal(t, steps[2].Jid, fetched.Jid)
+			assert.Empty(t, fetched.Args)
+			_, err = m.Acknowledge(fetched.Jid)
+			assert.NoError(t, err)
+
+			steps, err = m.Chain(job.Jid)
+			assert.NoError(t, err)
+			for _, step := range steps {
+				assert.Equal(t, "completed", step.Status.State)
+			}
+		})
+
+		t.Run("Invalid", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("Fetch", 1)
+			job.Chain = []*client.Job{{Args: []interface{}{}}}
+			assert.Error(t, m.Push(job))
+
+			job = client.NewJob("Fetch", 1)
+			nested := client.NewJob("Nested", 2)
+			nested.Chain = []*client.Job{client.NewJob("Again", 3)}
+			job.Chain = []*client.Job{nested}
+			assert.Error(t, m.Push(job))
+
+			// every step is checked as it would be when pushed on ACK
+			var invalid *InvalidError
+			job = client.NewJob("Fetch", 1)
+			late := client.NewJob("Late", 2)
+			late.At = "tomorrow"
+			job.Chain = []*client.Job{client.NewJob("Transform", 2), late}
+			err := m.Push(job)
+			assert.ErrorAs(t, err, &invalid)
+			assert.Contains(t, err.Error(), "chain step 2")
+
+			m.SetLimits(&Limits{Jobtypes: map[string]Limit{"Upload": {MaxArgs: 1}}})
+			job = client.NewJob("Fetch", 1)
+			job.Chain = []*client.Job{client.NewJob("Upload", 1, 2)}
+			err = m.Push(job)
+			assert.ErrorAs(t, err, &invalid)
+			assert.Equal(t, "limits.jobtypes.Upload.max_args", invalid.Rule)
+			m.SetLimits(nil)
+
+			q, err := store.GetQueue("default")
+			assert.NoError(t, err)
+			assert.EqualValues(t, 0, q.Size())
+
+			steps, err := m.Chain("nosuchchain")
+			assert.NoError(t, err)
+			assert.Nil(t, steps)
+		})
+
+		t.Run("StepDied", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("Fetch", 1)
+			job.Retry = -1
+			job.Chain = []*client.Job{client.NewJob("Transform", 2)}
+			assert.NoError(t, m.Push(job))
+
+			_, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			err = m.Fail(&FailPayload{Jid: job.Jid, ErrorType: "Oops", ErrorMessage: "oops"})
+			assert.NoError(t, err)
+
+			steps, err := m.Chain(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "dead", steps[0].Status.State)
+			assert.Equal(t, "pending", steps[1].Status.State)
+		})
+	})
+}
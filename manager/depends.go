@@ -0,0 +1,228 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * A job may list the JIDs it depends on in `depends_on`.  Until every
+ * parent has been acknowledged, the job is parked in the "waiting" set
+ * and its key there is added to a set in the KV under each parent,
+ * "deps:<parent>".  Each ACK reads the acknowledged job's list and
+ * enqueues those which are now ready.  If a parent dies, its dependents
+ * are sent to the morgue too.
+ *
+ * Every parent must be known when the job is pushed, so a mistyped JID
+ * is rejected rather than taken as done.  Once parked, a parent which
+ * Faktory has since forgotten is assumed to have completed: completed
+ * JIDs are only remembered for CompletedTTL.  A canceled parent is
+ * treated like a dead one.
+ */
+
+func dependsKey(parent string) string {
+	return "deps:" + parent
+}
+
+type dependent struct {
+	key []byte
+	job *client.Job
+}
+
+func (m *manager) pushDependent(job *client.Job) error {
+	pending, dead, err := m.checkParents(job, true)
+	if err != nil {
+		return fmt.Errorf("push: check dependencies: %w", err)
+	}
+	if dead != "" {
+		return m.bury(job, fmt.Sprintf("Parent job %s died", dead))
+	}
+	if !pending {
+		return m.dispatch(job)
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("push: marshal job: %w", err)
+	}
+	now := util.Nows()
+	if err := m.store.Waiting().AddElement(now, job.Jid, data); err != nil {
+		return fmt.Errorf("push: park job: %w", err)
+	}
+	track(m.store, job.Jid, "waiting "+now)
+	key := []byte(now + "|" + job.Jid)
+	if err := m.addDependent(key, job); err != nil {
+		return fmt.Errorf("push: park job: %w", err)
+	}
+
+	// a parent may have completed while we were parking the job
+	return m.release(dependent{key: key, job: job})
+}
+
+// addDependent lists the waiting job under each of its parents.
+func (m *manager) addDependent(key []byte, job *client.Job) error {
+	kv := m.store.Raw()
+	for _, parent := range job.DependsOn {
+		// the parent can't outlive a stay in the morgue
+		err := kv.SAdd(dependsKey(parent), key, DeadTTL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParents returns whether any of the job's parents have yet
+// to complete or the JID of a parent which has died.  A parent
+// Faktory knows nothing about is an error when pushing.
+func (m *manager) checkParents(job *client.Job, pushing bool) (bool, string, error) {
+	pending := false
+	for _, parent := range job.DependsOn {
+		status, err := m.Lookup(parent)
+		if err != nil {
+			return false, "", err
+		}
+		if status == nil && pushing {
+			return false, "", fmt.Errorf("unknown parent job %s", parent)
+		}
+		if status == nil || status.State == "completed" {
+			continue
+		}
+		if status.State == "dead" || status.State == "canceled" {
+			return false, parent, nil
+		}
+		pending = true
+	}
+	return pending, "", nil
+}
+
+// release enqueues the waiting job if all of its parents have completed.
+func (m *manager) release(dep dependent) error {
+	pending, dead, err := m.checkParents(dep.job, false)
+	if err != nil || pending {
+		return err
+	}
+
+	ok, err := m.store.Waiting().Remove(dep.key)
+	if err != nil || !ok {
+		// someone else released it
+		return err
+	}
+	if dead != "" {
+		return m.bury(dep.job, fmt.Sprintf("Parent job %s died", dead))
+	}
+	return m.dispatch(dep.job)
+}
+
+// releaseDependents is ack middleware which enqueues any waiting
+// jobs which depend on the acknowledged job.
+func (m *manager) releaseDependents(next func() error, ctx Context) error {
+	err := next()
+	if err != nil {
+		return err
+	}
+
+	jid := ctx.Job().Jid
+	deps, err := m.dependents(jid)
+	if err != nil {
+		// the job itself succeeded, don't fail the ACK
+		util.Warnf("Unable to find dependents of %s: %v", jid, err)
+		return nil
+	}
+	for _, dep := range deps {
+		err := m.release(dep)
+		if err != nil {
+			util.Warnf("Unable to release %s: %v", dep.job.Jid, err)
+		}
+	}
+	if deps != nil {
+		m.forgetDependents(jid)
+	}
+	return nil
+}
+
+// buryDependents sends any jobs waiting on the given parent to the morgue.
+func (m *manager) buryDependents(parent string, reason string) {
+	deps, err := m.dependents(parent)
+	if err != nil {
+		util.Warnf("Unable to find dependents of %s: %v", parent, err)
+		return
+	}
+	for _, dep := range deps {
+		ok, err := m.store.Waiting().Remove(dep.key)
+		if err != nil {
+			util.Warnf("Unable to bury %s: %v", dep.job.Jid, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		err = m.bury(dep.job, reason)
+		if err != nil {
+			util.Warnf("Unable to bury %s: %v", dep.job.Jid, err)
+		}
+	}
+	if deps != nil {
+		m.forgetDependents(parent)
+	}
+}
+
+// bury sends a job whose parent failed straight to the morgue,
+// along with anything waiting on it.
+func (m *manager) bury(job *client.Job, reason string) error {
+	job.Failure = &client.Failure{
+		FailedAt:     util.Nows(),
+		ErrorType:    "DependencyFailed",
+		ErrorMessage: reason,
+		Backtrace:    []string{},
+	}
+
+	err := callMiddleware(m.failChain, Ctx{context.Background(), job, m}, func() error {
+		return sendToMorgue(m.store, job)
+	})
+	if err != nil {
+		return err
+	}
+	m.buryDependents(job.Jid, fmt.Sprintf("Parent job %s died", job.Jid))
+	return nil
+}
+
+// dependents finds the waiting jobs which depend on the given JID,
+// nil if none were ever parked on it.
+func (m *manager) dependents(parent string) ([]dependent, error) {
+	keys, err := m.store.Raw().SMembers(dependsKey(parent))
+	if err != nil || keys == nil {
+		return nil, err
+	}
+
+	deps := []dependent{}
+	for _, key := range keys {
+		entry, err := m.store.Waiting().Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			// released by another parent, or canceled
+			continue
+		}
+		job, err := entry.Job()
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, dependent{key: key, job: job})
+	}
+	return deps, nil
+}
+
+// forgetDependents drops the parent's set once it has completed or
+// died, jobs pushed since check the parent themselves.
+func (m *manager) forgetDependents(parent string) {
+	err := m.store.Raw().Delete(dependsKey(parent))
+	if err != nil {
+		util.Warnf("Unable to forget dependents of %s: %v", parent, err)
+	}
+}
@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependencies(t *testing.T) {
+	withRedis(t, "depends", func(t *testing.T, store storage.Store) {
+
+		t.Run("Release", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			a1 := client.NewJob("Parent", 1)
+			a2 := client.NewJob("Parent", 2)
+			assert.NoError(t, m.Push(a1))
+			assert.NoError(t, m.Push(a2))
+
+			b := client.NewJob("Child", 3)
+			b.DependsOn = []string{a1.Jid, a2.Jid}
+			assert.NoError(t, m.Push(b))
+			assert.EqualValues(t, 1, store.Waiting().Size())
+
+			status, err := m.Lookup(b.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "waiting", status.State)
+
+			// a second child joins the first in each parent's set
+			c := client.NewJob("Child", 4)
+			c.DependsOn = []string{a1.Jid, a2.Jid}
+			assert.NoError(t, m.Push(c))
+			for _, parent := range []string{a1.Jid, a2.Jid} {
+				keys, err := store.Raw().SMembers(dependsKey(parent))
+				assert.NoError(t, err)
+				assert.Len(t, keys, 2)
+			}
+			assert.NoError(t, m.Cancel(c.Jid))
+
+			q, err := store.GetQueue("default")
+			assert.NoError(t, err)
+			assert.EqualValues(t, 2, q.Size())
+
+			job, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			_, err = m.Acknowledge(job.Jid)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, store.Waiting().Size())
+
+			job, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			_, err = m.Acknowledge(job.Jid)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 0, store.Waiting().Size())
+
+			job, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.Equal(t, b.Jid, job.Jid)
+
+			// the parents' sets of dependents are gone with them
+			for _, parent := range []string{a1.Jid, a2.Jid} {
+				keys, err := store.Raw().SMembers(dependsKey(parent))
+				assert.NoError(t, err)
+				assert.Nil(t, keys)
+			}
+		})
+
+		t.Run("Completed", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			a := client.NewJob("Parent", 1)
+			assert.NoError(t, m.Push(a))
+			_, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			_, err = m.Acknowledge(a.Jid)
+			assert.NoError(t, err)
+
+			// completed parents don't hold up the job
+			b := client.NewJob("Child", 2)
+			b.DependsOn = []string{a.Jid}
+			assert.NoError(t, m.Push(b))
+			assert.EqualValues(t, 0, store.Waiting().Size())
+
+			status, err := m.Lookup(b.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "enqueued", status.State)
+
+			// but unknown ones, a typo or long forgotten, are rejected
+			u := client.NewJob("Child", 4)
+			u.DependsOn = []string{a.Jid, "unknownjid"}
+			err = m.Push(u)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "unknownjid")
+
+			c := client.NewJob("Child", 3)
+			c.DependsOn = []string{c.Jid}
+			assert.Error(t, m.Push(c))
+		})
+
+		t.Run("ParentDied", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			a := client.NewJob("Parent", 1)
+			a.Retry = 1
+			assert.NoError(t, m.Push(a))
+
+			b := client.NewJob("Child", 2)
+			b.DependsOn = []string{a.Jid}
+			assert.NoError(t, m.Push(b))
+			c := client.NewJob("Grandchild", 3)
+			c.DependsOn = []string{b.Jid}
+			assert.NoError(t, m.Push(c))
+			assert.EqualValues(t, 2, store.Waiting().Size())
+
+			_, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			err = m.Fail(&FailPayload{Jid: a.Jid, ErrorType: "Oops", ErrorMessage: "oops"})
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, store.Retries().Size())
+			assert.EqualValues(t, 2, store.Waiting().Size())
+
+			assert.NoError(t, store.EnqueueAll(store.Retries()))
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			err = m.Fail(&FailPayload{Jid: a.Jid, ErrorType: "Oops", ErrorMessage: "oops"})
+			assert.NoError(t, err)
+
+			assert.EqualValues(t, 0, store.Waiting().Size())
+			assert.EqualValues(t, 3, store.Dead().Size())
+
+			status, err := m.Lookup(b.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "dead", status.State)
+			assert.Equal(t, "DependencyFailed", status.Job.Failure.ErrorType)
+			assert.Contains(t, status.Job.Failure.ErrorMessage, a.Jid)
+
+			// pushing a job which depends on a dead job
+			d := client.NewJob("Child", 4)
+			d.DependsOn = []string{a.Jid}
+			assert.NoError(t, m.Push(d))
+			assert.EqualValues(t, 4, store.Dead().Size())
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Fetch always checks queues in the order given.  Workers choose how
+ * that order is built for each FETCH:
+ *
+ *   strict      as listed, a busy first queue starves the rest
+ *   weighted    random, each queue is picked first in proportion to
+ *               its weight, e.g. "critical:5 default:2 low:1"
+ *   roundrobin  rotate which queue is checked first on each fetch
+ */
+const (
+	StrictMode     = "strict"
+	WeightedMode   = "weighted"
+	RoundRobinMode = "roundrobin"
+)
+
+func ValidFetchMode(mode string) bool {
+	switch mode {
+	case StrictMode, WeightedMode, RoundRobinMode:
+		return true
+	}
+	return false
+}
+
+type WeightedQueue struct {
+	Name   string
+	Weight int
+}
+
+// ParseQueues parses queue names with an optional weight, "name:weight".
+// Queues without a weight have a weight of 1.
+func ParseQueues(specs []string) ([]WeightedQueue, error) {
+	queues := make([]WeightedQueue, 0, len(specs))
+	for _, spec := range specs {
+		if spec == "" {
+			continue
+		}
+		name, weight, found := strings.Cut(spec, ":")
+		wq := WeightedQueue{Name: name, Weight: 1}
+		if found {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("Invalid weight for queue %s, must be a positive integer", spec)
+			}
+			wq.Weight = w
+		}
+		queues = append(queues, wq)
+	}
+	return queues, nil
+}
+
+// OrderQueues returns the queue names in the order they should be
+// checked.  turn counts the worker's fetches so round-robin can rotate.
+func OrderQueues(mode string, queues []WeightedQueue, turn uint64) []string {
+	names := make([]string, len(queues))
+	switch mode {
+	case WeightedMode:
+		remaining := append([]WeightedQueue{}, queues...)
+		for idx := range names {
+			pick := pickWeighted(remaining)
+			names[idx] = remaining[pick].Name
+			remaining = append(remaining[:pick], remaining[pick+1:]...)
+		}
+	case RoundRobinMode:
+		for idx := range names {
+			names[idx] = queues[(uint64(idx)+turn)%uint64(len(queues))].Name
+		}
+	default:
+		for idx, q := range queues {
+			names[idx] = q.Name
+		}
+	}
+	return names
+}
+
+func pickWeighted(queues []WeightedQueue) int {
+	total := 0
+	for _, q := range queues {
+		total += q.Weight
+	}
+	n := rand.Intn(total)
+	for idx, q := range queues {
+		n -= q.Weight
+		if n < 0 {
+			return idx
+		}
+	}
+	return len(queues) - 1
+}
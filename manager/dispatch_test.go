@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQueues(t *testing.T) {
+	queues, err := ParseQueues([]string{"critical:5", "default", "", "low:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []WeightedQueue{{"critical", 5}, {"default", 1}, {"low", 1}}, queues)
+
+	for _, spec := range []string{"default:0", "default:-1", "default:x", "default:"} {
+		_, err = ParseQueues([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestOrderQueues(t *testing.T) {
+	queues := []WeightedQueue{{"critical", 6}, {"default", 3}, {"low", 1}}
+
+	t.Run("Strict", func(t *testing.T) {
+		for turn := uint64(0); turn < 3; turn++ {
+			assert.Equal(t, []string{"critical", "default", "low"}, OrderQueues(StrictMode, queues, turn))
+		}
+	})
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		assert.Equal(t, []string{"critical", "default", "low"}, OrderQueues(RoundRobinMode, queues, 0))
+		assert.Equal(t, []string{"default", "low", "critical"}, OrderQueues(RoundRobinMode, queues, 1))
+		assert.Equal(t, []string{"low", "critical", "default"}, OrderQueues(RoundRobinMode, queues, 2))
+		assert.Equal(t, []string{"critical", "default", "low"}, OrderQueues(RoundRobinMode, queues, 3))
+	})
+
+	t.Run("Weighted", func(t *testing.T) {
+		first := map[string]int{}
+		for i := 0; i < 10000; i++ {
+			names := OrderQueues(WeightedMode, queues, uint64(i))
+			assert.ElementsMatch(t, []string{"critical", "default", "low"}, names)
+			first[names[0]]++
+		}
+		// expect roughly 6000/3000/1000
+		assert.InDelta(t, 6000, first["critical"], 500)
+		assert.InDelta(t, 3000, first["default"], 500)
+		assert.InDelta(t, 1000, first["low"], 500)
+	})
+
+	assert.Empty(t, OrderQueues(RoundRobinMode, nil, 1))
+	assert.True(t, ValidFetchMode(WeightedMode))
+	assert.False(t, ValidFetchMode("random"))
+}
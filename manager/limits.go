@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hunter-io/faktory/client"
+)
+
+/*
+ * Limits protect Redis from runaway producers: Push rejects any job
+ * whose payload is too large, which has too many args or whose args
+ * don't match the schema registered for its jobtype.  Limits may be
+ * set for every job, per queue and per jobtype; a job must satisfy
+ * each limit which applies to it.  Zero means unlimited.
+ */
+type Limit struct {
+	// Maximum size of the job's JSON in bytes
+	MaxPayload int
+	// Maximum number of args
+	MaxArgs int
+	// Validates the job's args, only used for jobtypes
+	Schema *Schema
+}
+
+type Limits struct {
+	Default  Limit
+	Queues   map[string]Limit
+	Jobtypes map[string]Limit
+}
+
+// InvalidError explains which limit a job broke.
+type InvalidError struct {
+	Rule string
+	Err  error
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("%v (%s)", e.Err, e.Rule)
+}
+
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+type limitRule struct {
+	name  string
+	limit Limit
+}
+
+// SetLimits replaces the limits enforced by Push, nil removes them.
+func (m *manager) SetLimits(limits *Limits) {
+	m.limitMutex.Lock()
+	m.limits = limits
+	m.limitMutex.Unlock()
+}
+
+func (m *manager) checkLimits(job *client.Job) error {
+	m.limitMutex.RLock()
+	limits := m.limits
+	m.limitMutex.RUnlock()
+	if limits == nil {
+		return nil
+	}
+
+	rules := []limitRule{{"limits", limits.Default}}
+	if limit, ok := limits.Queues[job.Queue]; ok {
+		rules = append(rules, limitRule{"limits.queues." + job.Queue, limit})
+	}
+	if limit, ok := limits.Jobtypes[job.Type]; ok {
+		rules = append(rules, limitRule{"limits.jobtypes." + job.Type, limit})
+	}
+
+	size := -1
+	for _, rule := range rules {
+		limit := rule.limit
+		if limit.MaxArgs > 0 && len(job.Args) > limit.MaxArgs {
+			return &InvalidError{rule.name + ".max_args", fmt.Errorf("Job has %d args, %d maximum", len(job.Args), limit.MaxArgs)}
+		}
+		if limit.MaxPayload > 0 {
+			if size < 0 {
+				data, err := json.Marshal(job)
+				if err != nil {
+					return fmt.Errorf("push: marshal job: %w", err)
+				}
+				size = len(data)
+			}
+			if size > limit.MaxPayload {
+				return &InvalidError{rule.name + ".max_payload", fmt.Errorf("Job is %d bytes, %d maximum", size, limit.MaxPayload)}
+			}
+		}
+		if limit.Schema != nil {
+			// validate the args as a worker would see them
+			var args interface{}
+			data, err := json.Marshal(job.Args)
+			if err == nil {
+				err = json.Unmarshal(data, &args)
+			}
+			if err != nil {
+				return fmt.Errorf("push: marshal args: %w", err)
+			}
+			err = limit.Schema.Validate("args", args)
+			if err != nil {
+				return &InvalidError{rule.name + ".schema", err}
+			}
+		}
+	}
+	return nil
+}
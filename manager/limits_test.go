@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	withRedis(t, "limits", func(t *testing.T, store storage.Store) {
+		store.Flush()
+		m := NewManager(store)
+
+		schema, err := ParseSchema([]byte(`{"items": [{"type": "integer"}]}`))
+		assert.NoError(t, err)
+		m.SetLimits(&Limits{
+			Default:  Limit{MaxPayload: 1024, MaxArgs: 3},
+			Queues:   map[string]Limit{"bulk": {MaxArgs: 1}},
+			Jobtypes: map[string]Limit{"Charge": {Schema: schema}},
+		})
+
+		assert.NoError(t, m.Push(client.NewJob("Report", 1, 2, 3)))
+
+		rejected := map[*client.Job]string{
+			client.NewJob("Report", 1, 2, 3, 4):                "limits.max_args",
+			client.NewJob("Report", strings.Repeat("x", 1024)): "limits.max_payload",
+			client.NewJob("Charge", "12.50"):                   "limits.jobtypes.Charge.schema",
+		}
+		bulk := client.NewJob("Report", 1, 2)
+		bulk.Queue = "bulk"
+		rejected[bulk] = "limits.queues.bulk.max_args"
+
+		for job, rule := range rejected {
+			err := m.Push(job)
+			var invalid *InvalidError
+			if assert.True(t, errors.As(err, &invalid), "%v", err) {
+				assert.Equal(t, rule, invalid.Rule)
+				assert.Contains(t, err.Error(), rule)
+			}
+		}
+		assert.NoError(t, m.Push(client.NewJob("Charge", 1250)))
+
+		// PUSHB applies the same limits
+		errs := m.PushBulk([]*client.Job{client.NewJob("Report", 1), client.NewJob("Report", 1, 2, 3, 4)})
+		assert.Len(t, errs, 1)
+
+		m.SetLimits(nil)
+		assert.NoError(t, m.Push(client.NewJob("Report", 1, 2, 3, 4)))
+	})
+}
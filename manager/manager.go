@@ -19,8 +19,15 @@ const (
 	// in the job payload.
 	DefaultTimeout = 30 * 60
 
+	// A reservation can't be extended more than one day into the future,
+	// same as the maximum reserve_for.
+	MaxReservation = 24 * time.Hour
+
 	// Save dead jobs for 180 days, after that they will be purged
 	DeadTTL = 180 * 24 * time.Hour
+
+	// The most jobs a worker can fetch in one call.
+	MaxFetchCount = 100
 )
 
 type Manager interface {
@@ -51,12 +58,46 @@ type Manager interface {
 	// If all nil, the connection registers itself, blocking for a job.
 	Fetch(ctx context.Context, wid string, queues ...string) (*client.Job, error)
 
+	// FetchN reserves up to count jobs in one call
+	FetchN(ctx context.Context, wid string, count int, queues ...string) ([]*client.Job, error)
+
+	// PushBulk pushes many jobs at once, returning the error for each
+	// rejected job keyed by its index within jobs.
+	PushBulk(jobs []*client.Job) map[int]error
+
 	Acknowledge(jid string) (*client.Job, error)
 
+	// AcknowledgeResult acknowledges the job and stores its result
+	// for the given TTL so the producer can retrieve it.
+	AcknowledgeResult(jid string, result json.RawMessage, ttl time.Duration) (*client.Job, error)
+
+	// Result returns the result of the given job.  If the job has not
+	// completed, it waits until it does or the context is done.
+	Result(ctx context.Context, jid string) (json.RawMessage, error)
+
+	// Lookup finds the current state of the given job, nil if
+	// Faktory has no knowledge of the JID.
+	Lookup(jid string) (*client.JobStatus, error)
+
 	Fail(fail *FailPayload) error
 
+	// Progress records a worker's progress on a reserved job
+	Progress(wid string, progress *ProgressPayload) error
+
+	// Chain returns each step of the given chain, nil if the
+	// chain is unknown.
+	Chain(id string) ([]*ChainStep, error)
+
+	// Extend pushes out the expiry of a worker's reservation so
+	// long-running jobs aren't reaped.
+	Extend(wid string, jid string, seconds int) error
+
 	WorkingCount() int
 
+	// LoadWorkingSet replaces the reservations held in memory with
+	// those in the store's working set.
+	LoadWorkingSet() error
+
 	ReapExpiredJobs(timestamp string) (int, error)
 
 	// Purge deletes all dead jobs
@@ -65,6 +106,28 @@ type Manager interface {
 	// EnqueueScheduledJobs enqueues scheduled jobs
 	EnqueueScheduledJobs() (int64, error)
 
+	// Reschedule changes when a scheduled job will run.
+	Reschedule(jid string, at time.Time) error
+
+	// Cancel removes a pending job.  A reserved job is marked
+	// canceled so its worker can stop work on it.
+	Cancel(jid string) error
+
+	// CanceledJobs returns the JIDs reserved by the given
+	// worker which have since been canceled.
+	CanceledJobs(wid string) []string
+
+	// RemoveJobs deletes entries of a sorted set, EnqueueJobs moves
+	// them to their queues and KillJobs to the morgue.  Each acts on
+	// every entry when given no keys.
+	RemoveJobs(set storage.SortedSet, keys [][]byte) error
+	EnqueueJobs(set storage.SortedSet, keys [][]byte) error
+	KillJobs(set storage.SortedSet, keys [][]byte) error
+
+	// RemoveQueued deletes jobs from a queue, all of them when given
+	// no payloads.
+	RemoveQueued(q storage.Queue, payloads [][]byte) error
+
 	// RetryJobs enqueues failed jobs
 	RetryJobs() (int64, error)
 
@@ -72,7 +135,20 @@ type Manager interface {
 
 	AddMiddleware(fntype string, fn MiddlewareFunc)
 
+	// SetLimits replaces the payload limits enforced by Push.
+	SetLimits(limits *Limits)
+
+	// SetQueueOptions replaces the job defaults and maximum
+	// length of each queue.
+	SetQueueOptions(defaults QueueOptions, queues map[string]QueueOptions)
+
+	// SetOutOfMemory rejects pushes with ErrOOM while the reason
+	// isn't empty.  ACK, FAIL and FETCH keep working so the backlog
+	// can drain.
+	SetOutOfMemory(reason string)
+
 	KV() storage.KV
+	// Redis is nil unless the store is backed by Redis.
 	Redis() *redis.Client
 }
 
@@ -80,12 +156,16 @@ func NewManager(s storage.Store) Manager {
 	m := &manager{
 		store:      s,
 		workingMap: map[string]*Reservation{},
+		updating:   map[string]chan struct{}{},
 		pushChain:  make(MiddlewareChain, 0),
 		failChain:  make(MiddlewareChain, 0),
 		ackChain:   make(MiddlewareChain, 0),
 		fetchChain: make(MiddlewareChain, 0),
+		waiters:    map[string][]chan struct{}{},
 	}
-	m.loadWorkingSet()
+	m.AddMiddleware("ack", m.releaseDependents)
+	m.AddMiddleware("ack", m.advanceChain)
+	m.LoadWorkingSet()
 	return m
 }
 
@@ -94,7 +174,10 @@ func (m *manager) KV() storage.KV {
 }
 
 func (m *manager) Redis() *redis.Client {
-	return m.store.Redis()
+	if rs, ok := m.store.(storage.Redis); ok {
+		return rs.Redis()
+	}
+	return nil
 }
 
 func (m *manager) AddMiddleware(fntype string, fn MiddlewareFunc) {
@@ -121,13 +204,44 @@ type manager struct {
 	// and remove stored entry quickly.
 	workingMap   map[string]*Reservation
 	workingMutex sync.RWMutex
-	pushChain    MiddlewareChain
-	fetchChain   MiddlewareChain
-	failChain    MiddlewareChain
-	ackChain     MiddlewareChain
+	// reservations being moved within the working set, closed when done
+	updating   map[string]chan struct{}
+	pushChain  MiddlewareChain
+	fetchChain MiddlewareChain
+	failChain  MiddlewareChain
+	ackChain   MiddlewareChain
+
+	// producers blocked in RESULT, by JID
+	waiters     map[string][]chan struct{}
+	waiterMutex sync.Mutex
+
+	// enforced by Push, may be reloaded
+	limits        *Limits
+	queueDefaults QueueOptions
+	queueOptions  map[string]QueueOptions
+	// rejects pushes while not empty
+	oomReason  string
+	limitMutex sync.RWMutex
 }
 
 func (m *manager) Push(job *client.Job) error {
+	err := m.prepare(job)
+	if err != nil {
+		return err
+	}
+	err = m.checkMemory()
+	if err != nil {
+		return err
+	}
+	err = m.checkFull(job, 0)
+	if err != nil {
+		return err
+	}
+	return m.route(job)
+}
+
+// Validate the job and fill in any defaults.
+func (m *manager) prepare(job *client.Job) error {
 	if job.Jid == "" || len(job.Jid) < 8 {
 		return fmt.Errorf("All jobs must have a reasonable jid parameter")
 	}
@@ -146,11 +260,56 @@ func (m *manager) Push(job *client.Job) error {
 		job.Queue = "default"
 	}
 
+	opts := m.optionsFor(job.Queue)
+	if !job.RetryGiven() {
+		job.Retry = opts.Retry
+	}
+	if job.ReserveFor == 0 {
+		job.ReserveFor = opts.ReserveFor
+	}
+
 	// Priority can never be negative because of signedness
+	if job.Priority > 9 || job.Priority == 0 {
+		job.Priority = opts.Priority
+	}
 	if job.Priority > 9 || job.Priority == 0 {
 		job.Priority = 5
 	}
 
+	if job.At != "" {
+		_, err := util.ParseTime(job.At)
+		if err != nil {
+			return fmt.Errorf("push: invalid timestamp for 'at': '%s'", job.At)
+		}
+	}
+
+	for _, parent := range job.DependsOn {
+		if parent == "" || parent == job.Jid {
+			return fmt.Errorf("push: invalid depends_on '%s'", parent)
+		}
+	}
+	return m.checkLimits(job)
+}
+
+// Send a prepared job on its way: start its chain, park it until its
+// dependencies complete, schedule it or enqueue it.
+func (m *manager) route(job *client.Job) error {
+	if len(job.Chain) > 0 && job.ChainID == "" {
+		err := m.startChain(job)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(job.DependsOn) > 0 {
+		return m.pushDependent(job)
+	}
+
+	return m.dispatch(job)
+}
+
+// Schedule the job if it has a future timestamp, otherwise enqueue it.
+func (m *manager) dispatch(job *client.Job) error {
 	if job.At != "" {
 		t, err := util.ParseTime(job.At)
 		if err != nil {
@@ -167,6 +326,7 @@ func (m *manager) Push(job *client.Job) error {
 			if err := m.store.Scheduled().AddElement(job.At, job.Jid, data); err != nil {
 				return fmt.Errorf("push: schedule job: %w", err)
 			}
+			track(m.store, job.Jid, "scheduled "+job.At)
 			return nil
 		}
 	}
@@ -188,15 +348,13 @@ func (m *manager) enqueue(job *client.Job) error {
 			return err
 		}
 		//util.Debugf("pushed: %+v", job)
-		return q.Push(job.Priority, data)
+		return q.PushAndSet(job.Priority, data, indexKey(job.Jid), []byte(queuedLocation(q.Name())), QueuedTTL)
 	})
 }
 
 func (m *manager) Fetch(ctx context.Context, wid string, queues ...string) (*client.Job, error) {
 restart:
-	var first storage.Queue
-
-	for idx, qname := range queues {
+	for _, qname := range queues {
 		q, err := m.store.GetQueue(qname)
 		if err != nil {
 			return nil, fmt.Errorf("fetch: get queue %q: %w", qname, err)
@@ -207,60 +365,121 @@ restart:
 			return nil, fmt.Errorf("fetch: pop queue %q: %w", qname, err)
 		}
 		if data != nil {
-			var job client.Job
-			err = json.Unmarshal(data, &job)
+			job, err := m.checkout(ctx, wid, data)
 			if err != nil {
-				return nil, fmt.Errorf("fetch: unmarshal job from %q: %w", qname, err)
+				return nil, err
 			}
-			err = callMiddleware(m.fetchChain, Ctx{ctx, &job, m}, func() error {
-				return m.reserve(wid, &job)
-			})
-			if h, ok := err.(halt); ok {
-				// middleware halted the fetch, for whatever reason
-				util.Infof("JID %s: %s", job.Jid, h.Error())
+			if job == nil {
 				goto restart
 			}
-			if err != nil {
-				return nil, err
-			}
-			return &job, nil
-		}
-		if idx == 0 {
-			first = q
+			return job, nil
 		}
 	}
 
-	if first == nil {
+	if len(queues) == 0 {
 		return nil, fmt.Errorf("Fetch must be called with one or more queue names")
 	}
 
 	// scanned through our queues, no jobs were available
-	// we should block for a moment, awaiting a job to be
-	// pushed.  this allows us to pick up new jobs in µs
-	// rather than seconds.
-	data, err := first.BPop(ctx)
+	// we should block on all of them until the deadline,
+	// awaiting a job to be pushed.  this allows us to pick
+	// up new jobs in µs rather than seconds.
+	data, err := m.store.BPop(ctx, queues...)
 	if err != nil {
 		return nil, fmt.Errorf("fetch: blocking pop: %w", err)
 	}
 	if data != nil {
-		var job client.Job
-		err = json.Unmarshal(data, &job)
+		job, err := m.checkout(ctx, wid, data)
 		if err != nil {
-			return nil, fmt.Errorf("fetch: unmarshal job: %w", err)
+			return nil, err
 		}
-		err = callMiddleware(m.fetchChain, Ctx{ctx, &job, m}, func() error {
-			return m.reserve(wid, &job)
-		})
-		if h, ok := err.(halt); ok {
-			// middleware halted the fetch, for whatever reason
-			util.Debugf("JID %s: %s", job.Jid, h.Error())
+		if job == nil {
 			goto restart
 		}
+		return job, nil
+	}
+
+	return nil, nil
+}
+
+// FetchN reserves up to count jobs, draining each queue in order
+// before moving to the next.  If no jobs are available, it blocks
+// like Fetch and returns at most one job.
+func (m *manager) FetchN(ctx context.Context, wid string, count int, queues ...string) ([]*client.Job, error) {
+	if count < 1 || count > MaxFetchCount {
+		return nil, fmt.Errorf("fetch: invalid count %d, must be 1-%d", count, MaxFetchCount)
+	}
+
+	jobs := []*client.Job{}
+	for _, qname := range queues {
+		q, err := m.store.GetQueue(qname)
 		if err != nil {
-			return nil, err
+			return m.partial(jobs, fmt.Errorf("fetch: get queue %q: %w", qname, err))
+		}
+
+		for len(jobs) < count {
+			data, err := q.Pop()
+			if err != nil {
+				return m.partial(jobs, fmt.Errorf("fetch: pop queue %q: %w", qname, err))
+			}
+			if data == nil {
+				break
+			}
+			job, err := m.checkout(ctx, wid, data)
+			if err != nil {
+				return m.partial(jobs, err)
+			}
+			if job != nil {
+				jobs = append(jobs, job)
+			}
+		}
+		if len(jobs) == count {
+			return jobs, nil
 		}
-		return &job, nil
 	}
 
-	return nil, nil
+	if len(jobs) > 0 {
+		return jobs, nil
+	}
+
+	job, err := m.Fetch(ctx, wid, queues...)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil {
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Once jobs are reserved we must hand them to the worker, otherwise
+// they sit in the working set until their reservations expire.
+func (m *manager) partial(jobs []*client.Job, err error) ([]*client.Job, error) {
+	if len(jobs) == 0 {
+		return nil, err
+	}
+	util.Warnf("Returning %d jobs after error: %v", len(jobs), err)
+	return jobs, nil
+}
+
+// Run the popped job through the fetch middleware and reserve it.
+// Returns nil if the middleware halted the fetch.
+func (m *manager) checkout(ctx context.Context, wid string, data []byte) (*client.Job, error) {
+	var job client.Job
+	err := json.Unmarshal(data, &job)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: unmarshal job: %w", err)
+	}
+	err = callMiddleware(m.fetchChain, Ctx{ctx, &job, m}, func() error {
+		return m.reserve(wid, &job)
+	})
+	if h, ok := err.(halt); ok {
+		// middleware halted the fetch, for whatever reason
+		util.Debugf("JID %s: %s", job.Jid, h.Error())
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
 }
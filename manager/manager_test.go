@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -261,6 +262,113 @@ func TestManager(t *testing.T) {
 			assert.NoError(t, err)
 			assert.NotEmpty(t, fetchedJob)
 		})
+
+		t.Run("FetchBlocksOnAllQueues", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("SendEmail", 1)
+			job.Queue = "email"
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				m.Push(job)
+			}()
+
+			start := time.Now()
+			fetched, err := m.Fetch(context.Background(), "workerId", "default", "email")
+			assert.NoError(t, err)
+			assert.NotNil(t, fetched)
+			assert.Equal(t, job.Jid, fetched.Jid)
+			assert.True(t, time.Since(start) < time.Second, "took %v", time.Since(start))
+		})
+
+		t.Run("FetchN", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			halted := client.NewJob("Halted", 0)
+			halted.Queue = "email"
+			m.AddMiddleware("fetch", func(next func() error, ctx Context) error {
+				if ctx.Job().Jid == halted.Jid {
+					return Halt("skip")
+				}
+				return next()
+			})
+
+			jids := []string{}
+			for i := 0; i < 3; i++ {
+				job := client.NewJob("ManagerPush", i)
+				assert.NoError(t, m.Push(job))
+				jids = append(jids, job.Jid)
+			}
+			assert.NoError(t, m.Push(halted))
+			for i := 0; i < 3; i++ {
+				job := client.NewJob("SendEmail", i)
+				job.Queue = "email"
+				assert.NoError(t, m.Push(job))
+				jids = append(jids, job.Jid)
+			}
+
+			jobs, err := m.FetchN(context.Background(), "workerId", 5, "default", "email")
+			assert.NoError(t, err)
+			assert.Len(t, jobs, 5)
+			for idx, job := range jobs {
+				assert.Equal(t, jids[idx], job.Jid)
+			}
+			assert.EqualValues(t, 5, m.WorkingCount())
+
+			jobs, err = m.FetchN(context.Background(), "workerId", 5, "default", "email")
+			assert.NoError(t, err)
+			assert.Len(t, jobs, 1)
+			assert.Equal(t, jids[5], jobs[0].Jid)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			jobs, err = m.FetchN(ctx, "workerId", 5, "default", "email")
+			assert.NoError(t, err)
+			assert.Empty(t, jobs)
+
+			_, err = m.FetchN(context.Background(), "workerId", 0, "default")
+			assert.Error(t, err)
+			_, err = m.FetchN(context.Background(), "workerId", MaxFetchCount+1, "default")
+			assert.Error(t, err)
+		})
+
+		t.Run("FetchNConcurrently", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			for i := 0; i < 100; i++ {
+				assert.NoError(t, m.Push(client.NewJob("ManagerPush", i)))
+			}
+
+			var mu sync.Mutex
+			seen := map[string]bool{}
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+						jobs, err := m.FetchN(ctx, "workerId", 7, "default")
+						cancel()
+						assert.NoError(t, err)
+						if len(jobs) == 0 {
+							return
+						}
+						mu.Lock()
+						for _, job := range jobs {
+							assert.False(t, seen[job.Jid])
+							seen[job.Jid] = true
+						}
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Len(t, seen, 100)
+		})
 	})
 }
 
@@ -325,6 +433,18 @@ func TestErrorWrapping(t *testing.T) {
 func withRedis(t *testing.T, name string, fn func(*testing.T, storage.Store)) {
 	t.Parallel()
 
+	// FAKTORY_TEST_DB=memory runs the tests without redis-server
+	if os.Getenv("FAKTORY_TEST_DB") == "memory" {
+		store, err := storage.OpenMemory(name)
+		if err != nil {
+			panic(err)
+		}
+		defer store.Close()
+
+		fn(t, store)
+		return
+	}
+
 	dir := fmt.Sprintf("/tmp/faktory-test-%s", name)
 	defer os.RemoveAll(dir)
 
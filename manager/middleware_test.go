@@ -121,6 +121,9 @@ func TestLiveMiddleware(t *testing.T) {
 		t.Run("Ack", func(t *testing.T) {
 			store.Flush()
 			m := NewManager(store)
+			if m.Redis() == nil {
+				t.Skip("requires redis")
+			}
 			m.AddMiddleware("push", func(next func() error, ctx Context) error {
 				_, err := m.Redis().Set(ctx.Job().Jid, []byte("bar"), 1*time.Second).Result()
 				assert.NoError(t, err)
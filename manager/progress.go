@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+)
+
+type ProgressPayload struct {
+	Jid     string `json:"jid"`
+	Percent int    `json:"percent"`
+	Desc    string `json:"desc"`
+	// Optional, extend the job's reservation until this time.
+	ReserveUntil string `json:"reserve_until"`
+}
+
+func (m *manager) Progress(wid string, progress *ProgressPayload) error {
+	if progress == nil {
+		return fmt.Errorf("No progress")
+	}
+	if progress.Jid == "" {
+		return fmt.Errorf("Missing JID")
+	}
+	if progress.Percent < 0 || progress.Percent > 100 {
+		return fmt.Errorf("Invalid percent %d, must be 0-100", progress.Percent)
+	}
+	if len(progress.Desc) > 1000 {
+		progress.Desc = progress.Desc[0:1000]
+	}
+
+	var until time.Time
+	if progress.ReserveUntil != "" {
+		t, err := util.ParseTime(progress.ReserveUntil)
+		if err != nil {
+			return fmt.Errorf("Invalid timestamp for reserve_until: '%s'", progress.ReserveUntil)
+		}
+		if t.After(time.Now().Add(MaxReservation)) {
+			return fmt.Errorf("Invalid reserve_until, one day maximum")
+		}
+		until = t
+	}
+
+	res, err := m.reservation(wid, progress.Jid)
+	if err != nil {
+		return err
+	}
+
+	return m.updateReservation(res, func(r *Reservation) {
+		r.Progress = &client.Progress{
+			Percent:   progress.Percent,
+			Desc:      progress.Desc,
+			UpdatedAt: util.Nows(),
+		}
+		// reservations only move forward, never shorten them
+		if until.After(r.texpiry) {
+			r.Expiry = util.Thens(until)
+			r.texpiry = until
+		}
+	})
+}
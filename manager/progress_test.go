@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	withRedis(t, "progress", func(t *testing.T, store storage.Store) {
+
+		t.Run("Report", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("LongJob", 1, 2, 3)
+			err := m.Push(job)
+			assert.NoError(t, err)
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+
+			err = m.Progress("workerId", &ProgressPayload{Jid: job.Jid, Percent: 40, Desc: "Resizing"})
+			assert.NoError(t, err)
+
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "working", status.State)
+			assert.Equal(t, 40, status.Progress.Percent)
+			assert.Equal(t, "Resizing", status.Progress.Desc)
+			assert.NotEmpty(t, status.Progress.UpdatedAt)
+
+			// the Busy page reads reservations from storage
+			var found *Reservation
+			err = store.Working().Each(func(_ int, entry storage.SortedEntry) error {
+				var res Reservation
+				found = &res
+				return json.Unmarshal(entry.Value(), &res)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 40, found.Progress.Percent)
+		})
+
+		t.Run("Rejected", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("LongJob", 1, 2, 3)
+			err := m.Push(job)
+			assert.NoError(t, err)
+
+			err = m.Progress("workerId", &ProgressPayload{Jid: job.Jid, Percent: 10})
+			assert.Error(t, err)
+
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+
+			err = m.Progress("otherWorker", &ProgressPayload{Jid: job.Jid, Percent: 10})
+			assert.Error(t, err)
+			err = m.Progress("workerId", &ProgressPayload{Jid: job.Jid, Percent: 101})
+			assert.Error(t, err)
+			err = m.Progress("workerId", &ProgressPayload{Jid: job.Jid, ReserveUntil: "tomorrow"})
+			assert.Error(t, err)
+			err = m.Progress("workerId", &ProgressPayload{Jid: job.Jid, ReserveUntil: util.Thens(time.Now().Add(48 * time.Hour))})
+			assert.Error(t, err)
+		})
+
+		t.Run("ReserveUntil", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store).(*manager)
+
+			job := client.NewJob("LongJob", 1, 2, 3)
+			err := m.Push(job)
+			assert.NoError(t, err)
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+
+			before := m.workingMap[job.Jid].texpiry
+			until := time.Now().Add(2 * time.Hour)
+			err = m.Progress("workerId", &ProgressPayload{Jid: job.Jid, Percent: 50, ReserveUntil: util.Thens(until)})
+			assert.NoError(t, err)
+			res := m.workingMap[job.Jid]
+			assert.True(t, res.texpiry.After(before))
+			assert.EqualValues(t, 1, store.Working().Size())
+
+			// never shortened
+			err = m.Progress("workerId", &ProgressPayload{Jid: job.Jid, Percent: 60, ReserveUntil: util.Thens(time.Now().Add(time.Minute))})
+			assert.NoError(t, err)
+			assert.Equal(t, res.Expiry, m.workingMap[job.Jid].Expiry)
+			assert.Equal(t, 60, m.workingMap[job.Jid].Progress.Percent)
+			assert.EqualValues(t, 1, store.Working().Size())
+		})
+	})
+}
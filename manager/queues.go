@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+)
+
+/*
+ * Queues may be configured with defaults for the jobs pushed to them
+ * and a maximum length.  Once a queue holds that many jobs, Push
+ * rejects further jobs with ErrFull so producers get backpressure.
+ */
+type QueueOptions struct {
+	// Defaults for jobs which don't set their own
+	Retry      int
+	ReserveFor int
+	Priority   uint8
+	// How long to wait between retries, see ValidBackoff
+	Backoff string
+	// Reject pushes once the queue holds this many jobs, 0 is unlimited
+	Backpressure uint64
+}
+
+const (
+	ExponentialBackoff = "exponential"
+	LinearBackoff      = "linear"
+	ConstantBackoff    = "constant"
+)
+
+func ValidBackoff(backoff string) bool {
+	switch backoff {
+	case "", ExponentialBackoff, LinearBackoff, ConstantBackoff:
+		return true
+	}
+	return false
+}
+
+var ErrFull = errors.New("queue is full")
+
+// ErrOOM rejects pushes while the store is nearly out of memory, see
+// SetOutOfMemory.
+var ErrOOM = errors.New("out of memory")
+
+func (m *manager) SetOutOfMemory(reason string) {
+	m.limitMutex.Lock()
+	m.oomReason = reason
+	m.limitMutex.Unlock()
+}
+
+// checkMemory rejects every push while the store is out of memory.
+func (m *manager) checkMemory() error {
+	m.limitMutex.RLock()
+	defer m.limitMutex.RUnlock()
+	if m.oomReason != "" {
+		return fmt.Errorf("%w, %s", ErrOOM, m.oomReason)
+	}
+	return nil
+}
+
+// SetQueueOptions replaces the options for every queue, queues
+// without their own options use the defaults.
+func (m *manager) SetQueueOptions(defaults QueueOptions, queues map[string]QueueOptions) {
+	m.limitMutex.Lock()
+	m.queueDefaults = defaults
+	m.queueOptions = queues
+	m.limitMutex.Unlock()
+}
+
+func (m *manager) optionsFor(queue string) QueueOptions {
+	m.limitMutex.RLock()
+	defer m.limitMutex.RUnlock()
+	if opts, ok := m.queueOptions[queue]; ok {
+		return opts
+	}
+	return m.queueDefaults
+}
+
+// checkFull rejects the job if its queue has reached its maximum length,
+// counting pending jobs which are about to be pushed to the same queue.
+func (m *manager) checkFull(job *client.Job, pending int) error {
+	max := m.optionsFor(job.Queue).Backpressure
+	if max == 0 {
+		return nil
+	}
+	q, err := m.store.GetQueue(job.Queue)
+	if err != nil {
+		return fmt.Errorf("push: get queue %q: %w", job.Queue, err)
+	}
+	if size := q.Size() + uint64(pending); size >= max {
+		return fmt.Errorf("%w, %s has %d jobs, %d maximum", ErrFull, job.Queue, size, max)
+	}
+	return nil
+}
+
+func nextRetry(job *client.Job, backoff string) time.Time {
+	count := job.Failure.RetryCount
+	var secs int
+	switch backoff {
+	case LinearBackoff:
+		secs = 30*(count+1) + rand.Intn(30)
+	case ConstantBackoff:
+		secs = 30 + rand.Intn(30)
+	default:
+		secs = (count * count * count * count) + 15 + (rand.Intn(30) * (count + 1))
+	}
+	return time.Now().Add(time.Duration(secs) * time.Second)
+}
@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueOptions(t *testing.T) {
+	withRedis(t, "queues", func(t *testing.T, store storage.Store) {
+
+		t.Run("Defaults", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+			m.SetQueueOptions(QueueOptions{Retry: 5}, map[string]QueueOptions{
+				"critical": {Retry: 10, ReserveFor: 600, Priority: 9},
+			})
+
+			job := &client.Job{Jid: "defaultjob", Type: "Report", Args: []interface{}{}}
+			assert.NoError(t, m.Push(job))
+			assert.Equal(t, 5, job.Retry)
+			assert.Equal(t, 0, job.ReserveFor)
+			assert.EqualValues(t, 5, job.Priority)
+
+			job = &client.Job{Jid: "criticaljob", Type: "Report", Queue: "critical", Args: []interface{}{}}
+			assert.NoError(t, m.Push(job))
+			assert.Equal(t, 10, job.Retry)
+			assert.Equal(t, 600, job.ReserveFor)
+			assert.EqualValues(t, 9, job.Priority)
+
+			// the job's own settings win
+			job = &client.Job{Jid: "explicitjob", Type: "Report", Queue: "critical", Args: []interface{}{}, Retry: -1, ReserveFor: 120, Priority: 1}
+			assert.NoError(t, m.Push(job))
+			assert.Equal(t, -1, job.Retry)
+			assert.Equal(t, 120, job.ReserveFor)
+			assert.EqualValues(t, 1, job.Priority)
+
+			// -1 opts out of retries, straight to the morgue
+			fetched, err := m.Fetch(context.Background(), "workerId", "critical")
+			assert.NoError(t, err)
+			for fetched.Jid != job.Jid {
+				fetched, err = m.Fetch(context.Background(), "workerId", "critical")
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, m.Fail(&FailPayload{Jid: job.Jid, ErrorType: "Oops"}))
+			assert.EqualValues(t, 0, store.Retries().Size())
+			assert.EqualValues(t, 1, store.Dead().Size())
+		})
+
+		t.Run("ExplicitNoRetry", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+			m.SetQueueOptions(QueueOptions{Retry: 5}, nil)
+
+			// as a producer would send it, retry 0 isn't the same as omitted
+			var job client.Job
+			err := json.Unmarshal([]byte(`{"jid":"oneshotjob","jobtype":"Report","args":[],"retry":0}`), &job)
+			assert.NoError(t, err)
+			assert.NoError(t, m.Push(&job))
+			assert.Equal(t, 0, job.Retry)
+
+			fetched, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.Equal(t, 0, fetched.Retry)
+			assert.NoError(t, m.Fail(&FailPayload{Jid: job.Jid, ErrorType: "Oops"}))
+			assert.EqualValues(t, 0, store.Retries().Size())
+			assert.EqualValues(t, 0, store.Dead().Size())
+		})
+
+		t.Run("Backpressure", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+			m.SetQueueOptions(QueueOptions{}, map[string]QueueOptions{
+				"default": {Backpressure: 2},
+			})
+
+			assert.NoError(t, m.Push(client.NewJob("Report", 1)))
+			assert.NoError(t, m.Push(client.NewJob("Report", 2)))
+			err := m.Push(client.NewJob("Report", 3))
+			assert.True(t, errors.Is(err, ErrFull), "got %v", err)
+
+			// other queues are unaffected
+			other := client.NewJob("Report", 4)
+			other.Queue = "other"
+			assert.NoError(t, m.Push(other))
+
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			errs := m.PushBulk([]*client.Job{client.NewJob("Report", 5), client.NewJob("Report", 6)})
+			assert.Len(t, errs, 1)
+			for _, err := range errs {
+				assert.True(t, errors.Is(err, ErrFull), "got %v", err)
+			}
+		})
+
+		t.Run("OutOfMemory", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+			assert.NoError(t, m.Push(client.NewJob("Report", 1)))
+
+			m.SetOutOfMemory("redis memory is past the 95% hard watermark")
+			err := m.Push(client.NewJob("Report", 2))
+			assert.True(t, errors.Is(err, ErrOOM), "got %v", err)
+			assert.Contains(t, err.Error(), "95%")
+			errs := m.PushBulk([]*client.Job{client.NewJob("Report", 3)})
+			assert.Len(t, errs, 1)
+
+			// the backlog still drains
+			job, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			_, err = m.Acknowledge(job.Jid)
+			assert.NoError(t, err)
+
+			m.SetOutOfMemory("")
+			assert.NoError(t, m.Push(client.NewJob("Report", 4)))
+		})
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	job := client.NewJob("Report")
+	job.Failure = &client.Failure{RetryCount: 3}
+
+	assertWithin := func(backoff string, min, max int) {
+		at := nextRetry(job, backoff)
+		secs := time.Until(at).Seconds()
+		assert.True(t, secs >= float64(min-1) && secs <= float64(max), "%s: %v", backoff, secs)
+	}
+	assertWithin(ExponentialBackoff, 96, 96+29*4)
+	assertWithin("", 96, 96+29*4)
+	assertWithin(LinearBackoff, 120, 149)
+	assertWithin(ConstantBackoff, 30, 59)
+
+	assert.True(t, ValidBackoff(LinearBackoff))
+	assert.False(t, ValidBackoff("random"))
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hunter-io/faktory/util"
+)
+
+const (
+	// Keep job results for an hour unless configured otherwise.
+	DefaultResultTTL = time.Hour
+
+	// Results are meant to be small, large payloads belong elsewhere.
+	MaxResultSize = 1024 * 1024
+)
+
+func resultKey(jid string) string {
+	return "result:" + jid
+}
+
+func (m *manager) storeResult(jid string, result json.RawMessage, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultResultTTL
+	}
+	err := m.store.Raw().SetEx(resultKey(jid), result, ttl)
+	if err != nil {
+		// the job succeeded, don't fail the ACK because we couldn't save its result
+		util.Warnf("Unable to store result for %s: %v", jid, err)
+	}
+}
+
+func (m *manager) Result(ctx context.Context, jid string) (json.RawMessage, error) {
+	ch := m.await(jid)
+	defer func() { m.stopWaiting(jid, ch) }()
+
+	for {
+		data, err := m.store.Raw().Get(resultKey(jid))
+		if err != nil || data != nil {
+			return data, err
+		}
+
+		// completed without a result or canceled, there's nothing to wait for
+		loc, err := m.store.Raw().Get(indexKey(jid))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(string(loc), "completed") || strings.HasPrefix(string(loc), "canceled") {
+			return nil, nil
+		}
+
+		select {
+		case <-ch:
+			ch = m.await(jid)
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+// Register interest in the given job's completion, the returned
+// channel is closed when the job is acknowledged.
+func (m *manager) await(jid string) chan struct{} {
+	ch := make(chan struct{})
+	m.waiterMutex.Lock()
+	m.waiters[jid] = append(m.waiters[jid], ch)
+	m.waiterMutex.Unlock()
+	return ch
+}
+
+func (m *manager) stopWaiting(jid string, ch chan struct{}) {
+	m.waiterMutex.Lock()
+	defer m.waiterMutex.Unlock()
+
+	chans := m.waiters[jid]
+	for idx, c := range chans {
+		if c == ch {
+			chans = append(chans[:idx], chans[idx+1:]...)
+			break
+		}
+	}
+	if len(chans) == 0 {
+		delete(m.waiters, jid)
+	} else {
+		m.waiters[jid] = chans
+	}
+}
+
+func (m *manager) notifyWaiters(jid string) {
+	m.waiterMutex.Lock()
+	chans := m.waiters[jid]
+	delete(m.waiters, jid)
+	m.waiterMutex.Unlock()
+
+	for _, ch := range chans {
+		close(ch)
+	}
+}
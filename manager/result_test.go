@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResult(t *testing.T) {
+	withRedis(t, "result", func(t *testing.T, store storage.Store) {
+
+		t.Run("Stored", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("RpcJob", 1, 2, 3)
+			err := m.Push(job)
+			assert.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+			data, err := m.Result(ctx, job.Jid)
+			assert.NoError(t, err)
+			assert.Nil(t, data)
+
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			_, err = m.AcknowledgeResult(job.Jid, []byte(`{"sum":6}`), time.Minute)
+			assert.NoError(t, err)
+
+			data, err = m.Result(ctx, job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"sum":6}`, string(data))
+
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "completed", status.State)
+			assert.Equal(t, `{"sum":6}`, string(status.Result))
+
+			_, err = m.AcknowledgeResult(job.Jid, make([]byte, MaxResultSize+1), time.Minute)
+			assert.Error(t, err)
+		})
+
+		t.Run("Blocking", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("RpcJob", 1, 2, 3)
+			err := m.Push(job)
+			assert.NoError(t, err)
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				_, err := m.AcknowledgeResult(job.Jid, []byte(`"done"`), time.Minute)
+				assert.NoError(t, err)
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			start := time.Now()
+			data, err := m.Result(ctx, job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, `"done"`, string(data))
+			assert.True(t, time.Since(start) < time.Second)
+		})
+
+		t.Run("NoResult", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("RpcJob", 1, 2, 3)
+			err := m.Push(job)
+			assert.NoError(t, err)
+			_, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			_, err = m.Acknowledge(job.Jid)
+			assert.NoError(t, err)
+
+			// completed without a result, don't wait
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			start := time.Now()
+			data, err := m.Result(ctx, job.Jid)
+			assert.NoError(t, err)
+			assert.Nil(t, data)
+			assert.True(t, time.Since(start) < time.Second)
+		})
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"strings"
 	"time"
 
@@ -77,28 +76,42 @@ func (m *manager) clearReservation(jid string) *Reservation {
 }
 
 func (m *manager) processFailure(jid string, failure *FailPayload) error {
-	res := m.clearReservation(jid)
-	if res == nil {
-		return fmt.Errorf("Job not found %s", jid)
-	}
-
 	// when expiring overdue jobs in the working set, we remove in
 	// bulk so this job is no longer in the working set already.
-	if failure != JobReservationExpired {
-		ok, err := m.store.Working().RemoveElement(res.Expiry, jid)
+	var res *Reservation
+	if failure == JobReservationExpired {
+		res = m.clearReservation(jid)
+	} else {
+		var ok bool
+		var err error
+		res, ok, err = m.releaseReservation(jid)
 		if err != nil {
 			return err
 		}
-		if !ok {
+		if res != nil && !ok {
 			return nil
 		}
 	}
+	if res == nil {
+		return fmt.Errorf("Job not found %s", jid)
+	}
+
+	if res.Canceled {
+		// the worker gave up on a canceled job, don't retry it
+		m.finishCancel(jid)
+		return nil
+	}
 
-	m.store.Failure()
+	err := m.store.Failure()
+	if err != nil {
+		util.Warnf("Unable to count %s as failed: %v", jid, err)
+	}
 
 	job := res.Job
 	if job.Retry == 0 {
 		// no retry, no death, completely ephemeral, goodbye
+		forget(m.store, jid)
+		m.buryDependents(jid, fmt.Sprintf("Parent job %s failed", jid))
 		return nil
 	}
 
@@ -119,21 +132,31 @@ func (m *manager) processFailure(jid string, failure *FailPayload) error {
 
 	return callMiddleware(m.failChain, Ctx{context.Background(), job, m}, func() error {
 		if job.Failure.RetryCount < job.Retry {
-			return retryLater(m.store, job)
+			return retryLater(m.store, job, m.optionsFor(job.Queue).Backoff)
 		}
-		return sendToMorgue(m.store, job)
+		err := sendToMorgue(m.store, job)
+		if err != nil {
+			return err
+		}
+		m.buryDependents(jid, fmt.Sprintf("Parent job %s died", jid))
+		return nil
 	})
 }
 
-func retryLater(store storage.Store, job *client.Job) error {
-	when := util.Thens(nextRetry(job))
+func retryLater(store storage.Store, job *client.Job, backoff string) error {
+	when := util.Thens(nextRetry(job, backoff))
 	job.Failure.NextAt = when
 	bytes, err := json.Marshal(job)
 	if err != nil {
 		return err
 	}
 
-	return store.Retries().AddElement(when, job.Jid, bytes)
+	err = store.Retries().AddElement(when, job.Jid, bytes)
+	if err != nil {
+		return err
+	}
+	track(store, job.Jid, "retries "+when)
+	return nil
 }
 
 func sendToMorgue(store storage.Store, job *client.Job) error {
@@ -142,12 +165,11 @@ func sendToMorgue(store storage.Store, job *client.Job) error {
 		return err
 	}
 
-	expiry := util.Thens(time.Now().Add(DeadTTL))
-	return store.Dead().AddElement(expiry, job.Jid, bytes)
-}
-
-func nextRetry(job *client.Job) time.Time {
-	count := job.Failure.RetryCount
-	secs := (count * count * count * count) + 15 + (rand.Intn(30) * (count + 1))
-	return time.Now().Add(time.Duration(secs) * time.Second)
+	expiry := time.Now().Add(DeadTTL)
+	err = store.Dead().AddElement(util.Thens(expiry), job.Jid, bytes)
+	if err != nil {
+		return err
+	}
+	trackDead(store, job.Jid, expiry)
+	return nil
 }
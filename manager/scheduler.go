@@ -2,6 +2,8 @@ package manager
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/hunter-io/faktory/client"
 	"github.com/hunter-io/faktory/storage"
@@ -17,6 +19,12 @@ func (m *manager) Purge() (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	for _, elm := range dead {
+		var job client.Job
+		if err := json.Unmarshal(elm, &job); err == nil {
+			forget(m.store, job.Jid)
+		}
+	}
 	return int64(len(dead)), nil
 }
 
@@ -54,3 +62,48 @@ func (m *manager) schedule(set storage.SortedSet) (int64, error) {
 
 	return count, nil
 }
+
+// Reschedule changes when a scheduled job will run.  A time which
+// isn't in the future enqueues the job immediately.
+func (m *manager) Reschedule(jid string, at time.Time) error {
+	status, err := m.Lookup(jid)
+	if err != nil {
+		return fmt.Errorf("reschedule: %w", err)
+	}
+	if status == nil || status.State != "scheduled" {
+		return fmt.Errorf("reschedule: job %s is not scheduled", jid)
+	}
+
+	set := m.store.Scheduled()
+	entry, err := set.Get([]byte(status.At + "|" + jid))
+	if err != nil {
+		return fmt.Errorf("reschedule: %w", err)
+	}
+	if entry == nil {
+		// the scheduler got there first
+		return fmt.Errorf("reschedule: job %s is not scheduled", jid)
+	}
+
+	if !at.After(time.Now()) {
+		key, err := entry.Key()
+		if err != nil {
+			return fmt.Errorf("reschedule: %w", err)
+		}
+		ok, err := set.Remove(key)
+		if err != nil || !ok {
+			return err
+		}
+		job, err := entry.Job()
+		if err != nil {
+			return fmt.Errorf("reschedule: %w", err)
+		}
+		job.At = ""
+		return m.enqueue(job)
+	}
+
+	_, err = set.Reschedule(entry, at, indexKey(jid), []byte("scheduled "+util.Thens(at)))
+	if err != nil {
+		return fmt.Errorf("reschedule: %w", err)
+	}
+	return nil
+}
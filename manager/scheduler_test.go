@@ -102,6 +102,40 @@ func TestScheduler(t *testing.T) {
 			assert.EqualValues(t, 2, store.Scheduled().Size())
 		})
 
+		t.Run("Reschedule", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("ScheduledJob", 1, 2, 3)
+			job.At = util.Thens(time.Now().Add(time.Hour))
+			assert.NoError(t, m.Push(job))
+
+			later := time.Now().Add(2 * time.Hour)
+			err := m.Reschedule(job.Jid, later)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, store.Scheduled().Size())
+
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "scheduled", status.State)
+			assert.Equal(t, util.Thens(later), status.At)
+			assert.Equal(t, util.Thens(later), status.Job.At)
+
+			// run now
+			err = m.Reschedule(job.Jid, time.Now())
+			assert.NoError(t, err)
+			assert.EqualValues(t, 0, store.Scheduled().Size())
+			status, err = m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "enqueued", status.State)
+			assert.Equal(t, "", status.Job.At)
+
+			err = m.Reschedule(job.Jid, later)
+			assert.Error(t, err)
+			err = m.Reschedule("nope", later)
+			assert.Error(t, err)
+		})
+
 		t.Run("RetryJobs", func(t *testing.T) {
 			store.Flush()
 			m := NewManager(store)
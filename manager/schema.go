@@ -0,0 +1,269 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+ * Schema is a JSON Schema for a job's args.  It supports the keywords
+ * which describe job arguments and ignores the rest:
+ *
+ *   type, enum, const, pattern, minLength, maxLength, minimum, maximum,
+ *   items (a schema or, since args are positional, an array of schemas),
+ *   minItems, maxItems, properties, required, additionalProperties
+ */
+type Schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                *interface{}       `json:"const"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Items                json.RawMessage    `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+
+	pattern    *regexp.Regexp
+	items      *Schema
+	tuple      []*Schema
+	additional *Schema
+	closed     bool
+}
+
+// "type" may be a single type or a list of types.
+type schemaTypes []string
+
+func (st *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*st = schemaTypes{one}
+		return nil
+	}
+	var many []string
+	err := json.Unmarshal(data, &many)
+	*st = many
+	return err
+}
+
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+	err = s.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) compile() error {
+	if s.Pattern != "" {
+		rx, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = rx
+	}
+
+	if len(s.Items) > 0 {
+		if s.Items[0] == '[' {
+			err := json.Unmarshal(s.Items, &s.tuple)
+			if err != nil {
+				return err
+			}
+		} else {
+			s.items = &Schema{}
+			err := json.Unmarshal(s.Items, s.items)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.AdditionalProperties) > 0 {
+		var allowed bool
+		if json.Unmarshal(s.AdditionalProperties, &allowed) == nil {
+			s.closed = !allowed
+		} else {
+			s.additional = &Schema{}
+			err := json.Unmarshal(s.AdditionalProperties, s.additional)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	children := []*Schema{s.items, s.additional}
+	children = append(children, s.tuple...)
+	for _, prop := range s.Properties {
+		children = append(children, prop)
+	}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		err := child.compile()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the decoded JSON value, path names the value in errors.
+func (s *Schema) Validate(path string, value interface{}) error {
+	if len(s.Type) > 0 {
+		ok := false
+		for _, typ := range s.Type {
+			if hasType(typ, value) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s must be %s", path, strings.Join(s.Type, " or "))
+		}
+	}
+
+	if s.Const != nil && !reflect.DeepEqual(*s.Const, value) {
+		return fmt.Errorf("%s must be %v", path, *s.Const)
+	}
+	if len(s.Enum) > 0 {
+		ok := false
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(allowed, value) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s must be one of %v", path, s.Enum)
+		}
+	}
+
+	switch val := value.(type) {
+	case string:
+		return s.validateString(path, val)
+	case float64:
+		return s.validateNumber(path, val)
+	case []interface{}:
+		return s.validateArray(path, val)
+	case map[string]interface{}:
+		return s.validateObject(path, val)
+	}
+	return nil
+}
+
+func hasType(typ string, value interface{}) bool {
+	switch val := value.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case string:
+		return typ == "string"
+	case float64:
+		return typ == "number" || (typ == "integer" && val == math.Trunc(val))
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	}
+	return false
+}
+
+func (s *Schema) validateString(path string, val string) error {
+	length := len([]rune(val))
+	if s.MinLength != nil && length < *s.MinLength {
+		return fmt.Errorf("%s must be at least %d characters", path, *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return fmt.Errorf("%s must be at most %d characters", path, *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(val) {
+		return fmt.Errorf("%s must match %s", path, s.Pattern)
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(path string, val float64) error {
+	if s.Minimum != nil && val < *s.Minimum {
+		return fmt.Errorf("%s must be at least %v", path, *s.Minimum)
+	}
+	if s.Maximum != nil && val > *s.Maximum {
+		return fmt.Errorf("%s must be at most %v", path, *s.Maximum)
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(path string, val []interface{}) error {
+	if s.MinItems != nil && len(val) < *s.MinItems {
+		return fmt.Errorf("%s must have at least %d items", path, *s.MinItems)
+	}
+	if s.MaxItems != nil && len(val) > *s.MaxItems {
+		return fmt.Errorf("%s must have at most %d items", path, *s.MaxItems)
+	}
+	for idx, item := range val {
+		schema := s.items
+		if s.tuple != nil {
+			schema = nil
+			if idx < len(s.tuple) {
+				schema = s.tuple[idx]
+			}
+		}
+		if schema == nil {
+			continue
+		}
+		err := schema.Validate(fmt.Sprintf("%s[%d]", path, idx), item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(path string, val map[string]interface{}) error {
+	for _, name := range s.Required {
+		if _, ok := val[name]; !ok {
+			return fmt.Errorf("%s.%s is required", path, name)
+		}
+	}
+
+	// sorted so errors are consistent
+	names := make([]string, 0, len(val))
+	for name := range val {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema, ok := s.Properties[name]
+		if !ok {
+			if s.closed {
+				return fmt.Errorf("%s.%s is not allowed", path, name)
+			}
+			schema = s.additional
+		}
+		if schema == nil {
+			continue
+		}
+		err := schema.Validate(path+"."+name, val[name])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{
+		"type": "array",
+		"minItems": 2,
+		"maxItems": 3,
+		"items": [
+			{"type": "integer", "minimum": 1},
+			{
+				"type": "object",
+				"required": ["email"],
+				"properties": {
+					"email": {"type": "string", "pattern": "@", "maxLength": 20},
+					"plan": {"enum": ["free", "pro"]}
+				},
+				"additionalProperties": false
+			},
+			{"type": ["string", "null"]}
+		]
+	}`))
+	assert.NoError(t, err)
+
+	valid := []string{
+		`[1, {"email": "mike@example.com"}]`,
+		`[5, {"email": "a@b", "plan": "pro"}, null]`,
+		`[5, {"email": "a@b"}, "note"]`,
+	}
+	for _, args := range valid {
+		assert.NoError(t, schema.Validate("args", decode(t, args)), args)
+	}
+
+	invalid := map[string]string{
+		`{}`:                                    "args must be array",
+		`[1]`:                                   "args must have at least 2 items",
+		`[1, {"email": "a@b"}, null, 4]`:        "args must have at most 3 items",
+		`[1.5, {"email": "a@b"}]`:               "args[0] must be integer",
+		`[0, {"email": "a@b"}]`:                 "args[0] must be at least 1",
+		`[1, {}]`:                               "args[1].email is required",
+		`[1, {"email": "nope"}]`:                "args[1].email must match @",
+		`[1, {"email": "a@b", "plan": "gold"}]`: "args[1].plan must be one of [free pro]",
+		`[1, {"email": "a@b", "admin": true}]`:  "args[1].admin is not allowed",
+		`[1, {"email": "a@b"}, 2]`:              "args[2] must be string or null",
+		`[1, {"email": "averyveryverylong@example.com"}]`: "args[1].email must be at most 20 characters",
+	}
+	for args, msg := range invalid {
+		err := schema.Validate("args", decode(t, args))
+		if assert.Error(t, err, args) {
+			assert.Equal(t, msg, err.Error())
+		}
+	}
+
+	_, err = ParseSchema([]byte(`{"pattern": "("}`))
+	assert.Error(t, err)
+	_, err = ParseSchema([]byte(`[`))
+	assert.Error(t, err)
+}
+
+func decode(t *testing.T, data string) interface{} {
+	var value interface{}
+	assert.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
@@ -0,0 +1,232 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+)
+
+const (
+	// Remember completed JIDs for 30 minutes so producers
+	// can see their job finished.
+	CompletedTTL = 30 * time.Minute
+	// Forget where a job was enqueued after a month, in case it left
+	// the queue without the index hearing of it.
+	QueuedTTL = 30 * 24 * time.Hour
+)
+
+var errFound = errors.New("found")
+
+/*
+ * The JID index maps each job to its current location so TRACK
+ * can find it without scanning the dataset.  Each entry is stored
+ * in the KV as "jid:<jid>" with a value of:
+ *
+ *   queue <name>
+ *   scheduled|retries|dead|waiting <timestamp>
+ *   working
+ *   completed|canceled <timestamp>
+ *
+ * The index is the only record of where a job is: every path which
+ * moves or deletes a job, including the Web UI's, must update it.
+ * Lookups check the job is still there, so an entry left behind, e.g.
+ * by fetch middleware halting a job, is harmless.  The sorted sets
+ * are keyed by timestamp and JID, only the one queue is walked.
+ */
+func indexKey(jid string) string {
+	return "jid:" + jid
+}
+
+func track(store storage.Store, jid string, location string) {
+	err := store.Raw().Set(indexKey(jid), []byte(location))
+	if err != nil {
+		util.Warnf("Unable to index JID %s: %v", jid, err)
+	}
+}
+
+func queuedLocation(queue string) string {
+	return "queue " + queue
+}
+
+// trackDead indexes a job in the morgue until its entry expires, so
+// the index doesn't outlive it even if the dead set is trimmed
+// behind our back.
+func trackDead(store storage.Store, jid string, expiry time.Time) {
+	ttl := time.Until(expiry) + CompletedTTL
+	err := store.Raw().SetEx(indexKey(jid), []byte("dead "+util.Thens(expiry)), ttl)
+	if err != nil {
+		util.Warnf("Unable to index JID %s: %v", jid, err)
+	}
+}
+
+func forget(store storage.Store, jids ...string) {
+	if len(jids) == 0 {
+		return
+	}
+	keys := make([]string, len(jids))
+	for idx, jid := range jids {
+		keys[idx] = indexKey(jid)
+	}
+	err := store.Raw().DeleteAll(keys)
+	if err != nil {
+		util.Warnf("Unable to unindex %d jobs: %v", len(jids), err)
+	}
+}
+
+func complete(store storage.Store, jid string) {
+	err := store.Raw().SetEx(indexKey(jid), []byte("completed "+util.Nows()), CompletedTTL)
+	if err != nil {
+		util.Warnf("Unable to index JID %s: %v", jid, err)
+	}
+}
+
+func canceled(store storage.Store, jid string) {
+	err := store.Raw().SetEx(indexKey(jid), []byte("canceled "+util.Nows()), CompletedTTL)
+	if err != nil {
+		util.Warnf("Unable to index JID %s: %v", jid, err)
+	}
+}
+
+func (m *manager) Lookup(jid string) (*client.JobStatus, error) {
+	m.workingMutex.RLock()
+	res, ok := m.workingMap[jid]
+	m.workingMutex.RUnlock()
+	if ok {
+		return &client.JobStatus{
+			Jid:      jid,
+			State:    "working",
+			Location: m.store.Working().Name(),
+			At:       res.Expiry,
+			Wid:      res.Wid,
+			Progress: res.Progress,
+			Job:      res.Job,
+		}, nil
+	}
+
+	loc, err := m.store.Raw().Get(indexKey(jid))
+	if err != nil || loc == nil {
+		return nil, err
+	}
+	status, err := m.locate(jid, string(loc))
+	if err != nil || status != nil {
+		return status, err
+	}
+
+	// the job moved between reading the index and its location
+	again, err := m.store.Raw().Get(indexKey(jid))
+	if err != nil || again == nil || bytes.Equal(again, loc) {
+		return nil, err
+	}
+	return m.locate(jid, string(again))
+}
+
+// locate fetches the job from where the index says it is, nil if
+// it has moved on.
+func (m *manager) locate(jid string, location string) (*client.JobStatus, error) {
+	where, arg, _ := strings.Cut(location, " ")
+	switch where {
+	case "completed":
+		result, err := m.store.Raw().Get(resultKey(jid))
+		if err != nil {
+			return nil, err
+		}
+		return &client.JobStatus{Jid: jid, State: "completed", At: arg, Result: result}, nil
+	case "canceled":
+		return &client.JobStatus{Jid: jid, State: "canceled", At: arg}, nil
+	case "queue":
+		job, _, err := m.findQueued(arg, jid)
+		if err != nil || job == nil {
+			return nil, err
+		}
+		return &client.JobStatus{Jid: jid, State: "enqueued", Location: arg, Job: job}, nil
+	case "scheduled", "retries", "dead", "waiting":
+		set := m.sortedSet(where)
+		entry, err := set.Get([]byte(arg + "|" + jid))
+		if err != nil || entry == nil {
+			return nil, err
+		}
+		job, err := entry.Job()
+		if err != nil {
+			return nil, err
+		}
+		if job.Jid != jid {
+			return nil, nil
+		}
+		return setStatus(set, arg, job), nil
+	}
+	// "working" but not in the working map, the job must have moved on
+	return nil, nil
+}
+
+// findQueued walks the named queue for the job, returning it and its
+// payload or nil if it's not there.
+func (m *manager) findQueued(name string, jid string) (*client.Job, []byte, error) {
+	q, err := m.store.GetQueue(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var job *client.Job
+	var payload []byte
+	err = q.Each(func(_ int, data []byte) error {
+		if !bytes.Contains(data, []byte(jid)) {
+			return nil
+		}
+		var candidate client.Job
+		err := json.Unmarshal(data, &candidate)
+		if err != nil || candidate.Jid != jid {
+			return nil
+		}
+		job, payload = &candidate, append([]byte(nil), data...)
+		return errFound
+	})
+	if err != nil && err != errFound {
+		return nil, nil, err
+	}
+	return job, payload, nil
+}
+
+// queued returns the queue and payload of an enqueued job, an empty
+// name if the job isn't enqueued.
+func (m *manager) queued(jid string) (string, []byte, error) {
+	loc, err := m.store.Raw().Get(indexKey(jid))
+	if err != nil {
+		return "", nil, err
+	}
+	where, name, _ := strings.Cut(string(loc), " ")
+	if where != "queue" {
+		return "", nil, nil
+	}
+	job, payload, err := m.findQueued(name, jid)
+	if err != nil || job == nil {
+		return "", nil, err
+	}
+	return name, payload, nil
+}
+
+func (m *manager) sortedSet(name string) storage.SortedSet {
+	switch name {
+	case "scheduled":
+		return m.store.Scheduled()
+	case "retries":
+		return m.store.Retries()
+	case "dead":
+		return m.store.Dead()
+	case "waiting":
+		return m.store.Waiting()
+	}
+	return nil
+}
+
+func setStatus(set storage.SortedSet, at string, job *client.Job) *client.JobStatus {
+	state := set.Name()
+	if state == "retries" {
+		state = "retry"
+	}
+	return &client.JobStatus{Jid: job.Jid, State: state, Location: set.Name(), At: at, Job: job}
+}
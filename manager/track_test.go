@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	withRedis(t, "track", func(t *testing.T, store storage.Store) {
+
+		t.Run("Lifecycle", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			status, err := m.Lookup("nosuchjob")
+			assert.NoError(t, err)
+			assert.Nil(t, status)
+
+			job := client.NewJob("TrackedJob", 1, 2, 3)
+			err = m.Push(job)
+			assert.NoError(t, err)
+
+			status, err = m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "enqueued", status.State)
+			assert.Equal(t, "default", status.Location)
+			assert.Equal(t, job.Jid, status.Job.Jid)
+
+			fetched, err := m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.Equal(t, job.Jid, fetched.Jid)
+
+			status, err = m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "working", status.State)
+			assert.Equal(t, "workerId", status.Wid)
+
+			err = m.Fail(&FailPayload{Jid: job.Jid, ErrorType: "Oops", ErrorMessage: "oops"})
+			assert.NoError(t, err)
+
+			status, err = m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "retry", status.State)
+			assert.Equal(t, "retries", status.Location)
+			assert.NotEmpty(t, status.At)
+			assert.Equal(t, "Oops", status.Job.Failure.ErrorType)
+
+			// bring it back for another attempt
+			err = store.EnqueueAll(store.Retries())
+			assert.NoError(t, err)
+			fetched, err = m.Fetch(context.Background(), "workerId", "default")
+			assert.NoError(t, err)
+			assert.Equal(t, job.Jid, fetched.Jid)
+
+			_, err = m.Acknowledge(job.Jid)
+			assert.NoError(t, err)
+
+			status, err = m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "completed", status.State)
+			assert.NotEmpty(t, status.At)
+			assert.Nil(t, status.Job)
+		})
+
+		t.Run("Scheduled", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("TrackedJob", 1, 2, 3)
+			job.At = util.Thens(time.Now().Add(10 * time.Minute))
+			err := m.Push(job)
+			assert.NoError(t, err)
+
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "scheduled", status.State)
+			assert.Equal(t, job.At, status.At)
+		})
+
+		t.Run("Unindexed", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("TrackedJob", 1, 2, 3)
+			err := m.Push(job)
+			assert.NoError(t, err)
+
+			val, err := store.Raw().Get(indexKey(job.Jid))
+			assert.NoError(t, err)
+			assert.Equal(t, "queue default", string(val))
+
+			// the index is the only record, there's no scanning for the job
+			err = store.Raw().Delete(indexKey(job.Jid))
+			assert.NoError(t, err)
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Nil(t, status)
+		})
+
+		t.Run("Stale", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			job := client.NewJob("TrackedJob", 1, 2, 3)
+			assert.NoError(t, m.Push(job))
+			other := client.NewJob("TrackedJob", 4)
+			assert.NoError(t, m.Push(other))
+
+			// the job leaves the queue behind the index's back, e.g. fetch
+			// middleware halted it
+			q, err := store.GetQueue("default")
+			assert.NoError(t, err)
+			_, err = q.Pop()
+			assert.NoError(t, err)
+
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Nil(t, status)
+			status, err = m.Lookup(other.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "enqueued", status.State)
+			assert.Equal(t, other.Jid, status.Job.Jid)
+		})
+
+		t.Run("WebUI", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store)
+
+			at := util.Thens(time.Now().Add(10 * time.Minute))
+			jobs := []*client.Job{}
+			for i := 0; i < 3; i++ {
+				job := client.NewJob("TrackedJob", i)
+				job.At = at
+				err := m.Push(job)
+				assert.NoError(t, err)
+				jobs = append(jobs, job)
+			}
+			key := func(job *client.Job) []byte {
+				return []byte(at + "|" + job.Jid)
+			}
+
+			err := m.EnqueueJobs(store.Scheduled(), [][]byte{key(jobs[0])})
+			assert.NoError(t, err)
+			status, err := m.Lookup(jobs[0].Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "enqueued", status.State)
+			assert.Equal(t, jobs[0].Jid, status.Job.Jid)
+
+			err = m.KillJobs(store.Scheduled(), [][]byte{key(jobs[1])})
+			assert.NoError(t, err)
+			status, err = m.Lookup(jobs[1].Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "dead", status.State)
+
+			err = m.RemoveJobs(store.Scheduled(), nil)
+			assert.NoError(t, err)
+			status, err = m.Lookup(jobs[2].Jid)
+			assert.NoError(t, err)
+			assert.Nil(t, status)
+			val, err := store.Raw().Get(indexKey(jobs[2].Jid))
+			assert.NoError(t, err)
+			assert.Nil(t, val)
+
+			q, err := store.GetQueue("default")
+			assert.NoError(t, err)
+			err = m.RemoveQueued(q, nil)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 0, q.Size())
+			val, err = store.Raw().Get(indexKey(jobs[0].Jid))
+			assert.NoError(t, err)
+			assert.Nil(t, val)
+
+			err = m.RemoveJobs(store.Dead(), [][]byte{})
+			assert.NoError(t, err)
+			val, err = store.Raw().Get(indexKey(jobs[1].Jid))
+			assert.NoError(t, err)
+			assert.Nil(t, val)
+		})
+	})
+}
@@ -3,6 +3,7 @@ package manager
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/hunter-io/faktory/client"
@@ -18,12 +19,14 @@ var (
 )
 
 type Reservation struct {
-	Job     *client.Job `json:"job"`
-	Since   string      `json:"reserved_at"`
-	Expiry  string      `json:"expires_at"`
-	Wid     string      `json:"wid"`
-	tsince  time.Time
-	texpiry time.Time
+	Job      *client.Job      `json:"job"`
+	Since    string           `json:"reserved_at"`
+	Expiry   string           `json:"expires_at"`
+	Wid      string           `json:"wid"`
+	Progress *client.Progress `json:"progress,omitempty"`
+	Canceled bool             `json:"canceled,omitempty"`
+	tsince   time.Time
+	texpiry  time.Time
 }
 
 func (m *manager) WorkingCount() int {
@@ -52,11 +55,15 @@ func (m *manager) BusyCount(wid string) int {
  *
  * The alternative is that a server restart would re-execute
  * all outstanding jobs, something to be avoided when possible.
+ * A promoted replica does the same, replacing the reservations
+ * it loaded when it booted.
  */
-func (m *manager) loadWorkingSet() error {
+func (m *manager) LoadWorkingSet() error {
 	m.workingMutex.Lock()
 	defer m.workingMutex.Unlock()
 
+	m.workingMap = map[string]*Reservation{}
+
 	addedCount := 0
 	err := m.store.Working().Each(func(idx int, entry storage.SortedEntry) error {
 		var res Reservation
@@ -64,6 +71,8 @@ func (m *manager) loadWorkingSet() error {
 		if err != nil {
 			return err
 		}
+		res.tsince, _ = util.ParseTime(res.Since)
+		res.texpiry, _ = util.ParseTime(res.Expiry)
 		m.workingMap[res.Job.Jid] = &res
 		addedCount++
 		return nil
@@ -118,36 +127,207 @@ func (m *manager) reserve(wid string, job *client.Job) error {
 	m.workingMap[job.Jid] = res
 	m.workingMutex.Unlock()
 
+	track(m.store, job.Jid, "working")
 	return nil
 }
 
-func (m *manager) ack(jid string) (*client.Job, error) {
-	res := m.clearReservation(jid)
-	if res == nil {
-		util.Infof("No such job to acknowledge %s", jid)
-		return nil, nil
+// Find the reservation for the given job, which must be held by the given worker.
+func (m *manager) reservation(wid string, jid string) (*Reservation, error) {
+	m.workingMutex.RLock()
+	res, ok := m.workingMap[jid]
+	m.workingMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Job %s is not reserved", jid)
+	}
+	if res.Wid != wid {
+		return nil, fmt.Errorf("Job %s is not reserved by %s", jid, wid)
+	}
+	return res, nil
+}
+
+// Replace the given reservation with an updated copy, moving it
+// within the working set if its expiry changed.  The store is written
+// outside the lock so a slow Redis doesn't stall every FETCH and ACK.
+func (m *manager) updateReservation(res *Reservation, update func(*Reservation)) error {
+	jid := res.Job.Jid
+	done, err := m.beginUpdate(res)
+	if err != nil {
+		return err
+	}
+	defer m.endUpdate(jid, done)
+
+	updated := *res
+	update(&updated)
+	data, err := json.Marshal(&updated)
+	if err != nil {
+		return err
 	}
 
 	ok, err := m.store.Working().RemoveElement(res.Expiry, jid)
+	if err != nil {
+		return err
+	}
 	if !ok {
-		// doesn't matter, might not have acknowledged in time
+		// the reaper got to it first
+		return fmt.Errorf("Job %s reservation has expired", jid)
 	}
-	return res.Job, err
+	err = m.store.Working().AddElement(updated.Expiry, jid, data)
+	if err != nil {
+		return err
+	}
+
+	m.workingMutex.Lock()
+	current := m.workingMap[jid]
+	if current == res {
+		m.workingMap[jid] = &updated
+	}
+	m.workingMutex.Unlock()
+	if current != res {
+		// released meanwhile, don't leave it in the working set
+		_, err = m.store.Working().RemoveElement(updated.Expiry, jid)
+		if err != nil {
+			util.Warnf("Unable to remove reservation for %s: %v", jid, err)
+		}
+		return fmt.Errorf("Job %s is no longer reserved", jid)
+	}
+	return nil
 }
 
-func (m *manager) Acknowledge(jid string) (*client.Job, error) {
-	job, err := m.ack(jid)
+// beginUpdate claims the reservation for updating, waiting out any
+// update of it already under way.
+func (m *manager) beginUpdate(res *Reservation) (chan struct{}, error) {
+	jid := res.Job.Jid
+	for {
+		m.workingMutex.Lock()
+		if m.workingMap[jid] != res {
+			m.workingMutex.Unlock()
+			return nil, fmt.Errorf("Job %s is no longer reserved", jid)
+		}
+		busy, ok := m.updating[jid]
+		if !ok {
+			done := make(chan struct{})
+			m.updating[jid] = done
+			m.workingMutex.Unlock()
+			return done, nil
+		}
+		m.workingMutex.Unlock()
+		<-busy
+	}
+}
+
+func (m *manager) endUpdate(jid string, done chan struct{}) {
+	m.workingMutex.Lock()
+	delete(m.updating, jid)
+	m.workingMutex.Unlock()
+	close(done)
+}
+
+func (m *manager) Extend(wid string, jid string, seconds int) error {
+	if seconds < 1 || seconds > int(MaxReservation/time.Second) {
+		return fmt.Errorf("Invalid extension %d, must be 1-%d seconds", seconds, int(MaxReservation/time.Second))
+	}
+
+	res, err := m.reservation(wid, jid)
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(time.Duration(seconds) * time.Second)
+	return m.updateReservation(res, func(r *Reservation) {
+		if until.After(r.texpiry) {
+			r.Expiry = util.Thens(until)
+			r.texpiry = until
+		}
+	})
+}
+
+func (m *manager) ack(jid string) (*Reservation, error) {
+	res, _, err := m.releaseReservation(jid)
 	if err != nil {
 		return nil, err
 	}
+	if res == nil {
+		util.Infof("No such job to acknowledge %s", jid)
+	}
+	return res, nil
+}
+
+/*
+ * Removes the job's reservation from the working set and only then
+ * from memory, so an ACK or FAIL which fails while Redis is down
+ * leaves the job reserved and can be retried once it's back.  ok is
+ * false if the job had already left the working set, e.g. reaped.
+ */
+func (m *manager) releaseReservation(jid string) (*Reservation, bool, error) {
+	for {
+		m.workingMutex.RLock()
+		res := m.workingMap[jid]
+		m.workingMutex.RUnlock()
+		if res == nil {
+			return nil, false, nil
+		}
 
-	if job != nil {
-		m.store.Success()
-		err = callMiddleware(m.ackChain, Ctx{context.Background(), job, m}, func() error {
-			return nil
-		})
+		ok, err := m.store.Working().RemoveElement(res.Expiry, jid)
+		if err != nil {
+			return nil, false, err
+		}
+
+		m.workingMutex.Lock()
+		current := m.workingMap[jid]
+		busy := m.updating[jid]
+		moving := current == res && !ok && busy != nil
+		if current == res && !moving {
+			delete(m.workingMap, jid)
+		}
+		m.workingMutex.Unlock()
+		if moving {
+			// an update is moving it within the working set
+			<-busy
+			continue
+		}
+		if current == res {
+			return res, ok, nil
+		}
+		if current == nil {
+			// released concurrently
+			return nil, false, nil
+		}
+		// extended meanwhile, try again with the new expiry
+	}
+}
+
+func (m *manager) Acknowledge(jid string) (*client.Job, error) {
+	return m.AcknowledgeResult(jid, nil, 0)
+}
+
+func (m *manager) AcknowledgeResult(jid string, result json.RawMessage, ttl time.Duration) (*client.Job, error) {
+	if len(result) > MaxResultSize {
+		return nil, fmt.Errorf("Result for %s is too large, %d bytes maximum", jid, MaxResultSize)
 	}
 
+	res, err := m.ack(jid)
+	if err != nil || res == nil {
+		return nil, err
+	}
+
+	job := res.Job
+	if res.Canceled {
+		m.finishCancel(jid)
+		return job, fmt.Errorf("%w: %s", ErrCanceled, jid)
+	}
+
+	err = m.store.Success()
+	if err != nil {
+		util.Warnf("Unable to count %s as processed: %v", jid, err)
+	}
+	complete(m.store, jid)
+	if len(result) > 0 {
+		m.storeResult(jid, result, ttl)
+	}
+	m.notifyWaiters(jid)
+	err = callMiddleware(m.ackChain, Ctx{context.Background(), job, m}, func() error {
+		return nil
+	})
 	return job, err
 }
 
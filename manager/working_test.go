@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"io"
 	"testing"
 	"time"
 
@@ -30,6 +31,12 @@ func TestLoadWorkingSet(t *testing.T) {
 			m2 := NewManager(store).(*manager)
 			assert.EqualValues(t, 1, store.Working().Size())
 			assert.EqualValues(t, 1, m2.WorkingCount())
+
+			// reloading replaces what was loaded before
+			store.Working().Clear()
+			err = m2.LoadWorkingSet()
+			assert.NoError(t, err)
+			assert.EqualValues(t, 0, m2.WorkingCount())
 		})
 
 		t.Run("ManagerReserve", func(t *testing.T) {
@@ -110,6 +117,31 @@ func TestLoadWorkingSet(t *testing.T) {
 			assert.EqualValues(t, 0, store.TotalFailures())
 		})
 
+		t.Run("AcknowledgeWhileUnavailable", func(t *testing.T) {
+			store.Flush()
+			flaky := &flakyStore{Store: store}
+			m := NewManager(flaky).(*manager)
+
+			job := client.NewJob("AckJob", 1, 2, 3)
+			err := m.reserve("workerId", job)
+			assert.NoError(t, err)
+
+			flaky.down = true
+			_, err = m.Acknowledge(job.Jid)
+			assert.Error(t, err)
+			assert.True(t, storage.IsUnavailable(err))
+			assert.EqualValues(t, 1, m.WorkingCount())
+			assert.EqualValues(t, 1, store.Working().Size())
+
+			flaky.down = false
+			aJob, err := m.Acknowledge(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, job.Jid, aJob.Jid)
+			assert.EqualValues(t, 0, m.WorkingCount())
+			assert.EqualValues(t, 0, store.Working().Size())
+			assert.EqualValues(t, 1, store.TotalProcessed())
+		})
+
 		t.Run("ManagerReapExpiredJobs", func(t *testing.T) {
 			store.Flush()
 			m := NewManager(store).(*manager)
@@ -140,5 +172,124 @@ func TestLoadWorkingSet(t *testing.T) {
 			assert.Equal(t, 1, count)
 			assert.EqualValues(t, 1, store.Retries().Size())
 		})
+
+		t.Run("ManagerExtend", func(t *testing.T) {
+			store.Flush()
+			m := NewManager(store).(*manager)
+
+			job := client.NewJob("WorkingJob", 1, 2, 3)
+			err := m.reserve("workerId", job)
+			assert.NoError(t, err)
+
+			err = m.Extend("otherWorker", job.Jid, 3600)
+			assert.Error(t, err)
+			err = m.Extend("workerId", "nosuchjob", 3600)
+			assert.Error(t, err)
+			err = m.Extend("workerId", job.Jid, 0)
+			assert.Error(t, err)
+			err = m.Extend("workerId", job.Jid, 86401)
+			assert.Error(t, err)
+
+			err = m.Extend("workerId", job.Jid, 3600)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, store.Working().Size())
+
+			// the original expiry has passed but the job lives on
+			exp := time.Now().Add(time.Duration(DefaultTimeout+10) * time.Second)
+			count, err := m.ReapExpiredJobs(util.Thens(exp))
+			assert.NoError(t, err)
+			assert.Equal(t, 0, count)
+			assert.EqualValues(t, 1, m.WorkingCount())
+
+			exp = time.Now().Add(3610 * time.Second)
+			count, err = m.ReapExpiredJobs(util.Thens(exp))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, count)
+			assert.EqualValues(t, 1, store.Retries().Size())
+		})
+
+		t.Run("SlowUpdate", func(t *testing.T) {
+			store.Flush()
+			slow := &slowStore{Store: store}
+			m := NewManager(slow).(*manager)
+
+			job := client.NewJob("WorkingJob", 1, 2, 3)
+			job.ReserveFor = 600
+			err := m.reserve("workerId", job)
+			assert.NoError(t, err)
+
+			slow.stalled = make(chan struct{}, 1)
+			slow.release = make(chan struct{})
+			extended := make(chan error)
+			go func() {
+				extended <- m.Extend("workerId", job.Jid, 3600)
+			}()
+			<-slow.stalled
+
+			// the working set isn't locked while the store is slow
+			status, err := m.Lookup(job.Jid)
+			assert.NoError(t, err)
+			assert.Equal(t, "working", status.State)
+
+			// an ACK meanwhile waits for the update to land
+			acked := make(chan error)
+			go func() {
+				_, err := m.Acknowledge(job.Jid)
+				acked <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+			close(slow.release)
+			assert.NoError(t, <-extended)
+			assert.NoError(t, <-acked)
+			assert.EqualValues(t, 0, m.WorkingCount())
+			assert.EqualValues(t, 0, store.Working().Size())
+		})
 	})
 }
+
+// slowStore stalls adding to the working set once release is set,
+// as a slow Redis would.
+type slowStore struct {
+	storage.Store
+	stalled chan struct{}
+	release chan struct{}
+}
+
+func (ss *slowStore) Working() storage.SortedSet {
+	return slowSet{ss.Store.Working(), ss}
+}
+
+type slowSet struct {
+	storage.SortedSet
+	store *slowStore
+}
+
+func (s slowSet) AddElement(timestamp string, jid string, payload []byte) error {
+	if s.store.release != nil {
+		s.store.stalled <- struct{}{}
+		<-s.store.release
+	}
+	return s.SortedSet.AddElement(timestamp, jid, payload)
+}
+
+// flakyStore fails to change the working set while down, as Redis
+// would while restarting.
+type flakyStore struct {
+	storage.Store
+	down bool
+}
+
+func (fs *flakyStore) Working() storage.SortedSet {
+	if fs.down {
+		return flakySet{fs.Store.Working()}
+	}
+	return fs.Store.Working()
+}
+
+type flakySet struct {
+	storage.SortedSet
+}
+
+func (flakySet) RemoveElement(timestamp string, jid string) (bool, error) {
+	return false, io.ErrUnexpectedEOF
+}
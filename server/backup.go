@@ -0,0 +1,64 @@
+package server
+
+import (
+	"path/filepath"
+
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * Backups are written to the backups directory within the storage
+ * directory.  Only the newest are kept:
+ *
+ *   [backups]
+ *   keep = 24
+ */
+const DefaultBackupsKept = 10
+
+func (s *Server) BackupDirectory() string {
+	return filepath.Join(s.Options.StorageDirectory, "backups")
+}
+
+// Backup archives the store and purges old backups.
+func (s *Server) Backup() (*storage.BackupInfo, error) {
+	s.backupMu.Lock()
+	defer s.backupMu.Unlock()
+
+	dir := s.BackupDirectory()
+	info, err := storage.CreateBackup(s.store, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := s.Options.Int("backups", "keep", DefaultBackupsKept)
+	if keep < 1 {
+		util.Warnf("Config error: backups/keep must be at least 1, not %d", keep)
+		keep = 1
+	}
+	count, err := storage.PurgeOldBackups(dir, keep)
+	if err != nil {
+		// the backup itself succeeded
+		util.Warnf("Unable to purge old backups: %v", err)
+	} else if count > 0 {
+		util.Debugf("Purged %d old backups", count)
+	}
+	return info, nil
+}
+
+// Backups lists the backups on disk, newest first.
+func (s *Server) Backups() ([]storage.BackupInfo, error) {
+	return storage.Backups(s.BackupDirectory())
+}
+
+// Restore loads a backup, 0 is the newest, into the empty store.  It
+// is used offline, before the server is booted.
+func (s *Server) Restore(id int64) (*storage.BackupInfo, error) {
+	store, err := s.openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	return storage.RestoreBackup(store, s.BackupDirectory(), id)
+}
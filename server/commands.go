@@ -3,12 +3,16 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/hunter-io/faktory/client"
 	"github.com/hunter-io/faktory/manager"
+	"github.com/hunter-io/faktory/storage"
 	"github.com/hunter-io/faktory/util"
 )
 
@@ -20,21 +24,37 @@ type command func(c *Connection, s *Server, cmd string)
 var cmdSet = map[string]command{
 	"END":   end,
 	"PUSH":  push,
+	"PUSHB": pushBulk,
 	"FETCH": fetch,
 	"ACK":   ack,
 	"FAIL":  fail,
 	"BEAT":  heartbeat,
 	"INFO":  info,
 	"FLUSH": flush,
+	"TRACK": trackJob,
+
+	"PROGRESS": progress,
+	"EXTEND":   extend,
+	"RESULT":   result,
+
+	"RESCHEDULE": reschedule,
+	"CANCEL":     cancel,
+
+	"BACKUP":  backup,
+	"PROMOTE": promote,
 }
 
 func flush(c *Connection, s *Server, cmd string) {
+	name := "dataset"
+	if !c.tenant.IsRoot() {
+		name = fmt.Sprintf("tenant %s", c.tenant.Name)
+	}
 	if s.Options.Environment == "development" {
-		util.Info("Flushing dataset")
+		util.Infof("Flushing %s", name)
 	} else {
-		util.Warn("Flushing dataset")
+		util.Warnf("Flushing %s", name)
 	}
-	err := s.store.Flush()
+	err := c.tenant.store.Flush()
 	if err != nil {
 		c.Error(cmd, err)
 		return
@@ -57,15 +77,58 @@ func push(c *Connection, s *Server, cmd string) {
 		return
 	}
 
-	err = s.manager.Push(&job)
+	err = c.tenant.manager.Push(&job)
 	if err != nil {
-		c.Error(cmd, err)
+		c.Error(cmd, tagError(err))
 		return
 	}
 
 	c.Ok()
 }
 
+// Tag errors for jobs which break the configured limits.
+func tagError(err error) error {
+	var invalid *manager.InvalidError
+	switch {
+	case errors.As(err, &invalid):
+		return newTaggedError("INVALID", err)
+	case errors.Is(err, manager.ErrFull):
+		return newTaggedError("FULL", err)
+	case errors.Is(err, manager.ErrOOM):
+		return newTaggedError("OOM", err)
+	case storage.IsUnavailable(err):
+		return newTaggedError("UNAVAILABLE", err)
+	}
+	return err
+}
+
+func pushBulk(c *Connection, s *Server, cmd string) {
+	data := cmd[6:]
+
+	var jobs []*client.Job
+	err := json.Unmarshal([]byte(data), &jobs)
+	if err != nil {
+		c.Error(cmd, newTaggedError("MALFORMED", err))
+		return
+	}
+	if len(jobs) > manager.MaxBulkSize {
+		c.Error(cmd, fmt.Errorf("Too many jobs %d, %d maximum", len(jobs), manager.MaxBulkSize))
+		return
+	}
+
+	errs := c.tenant.manager.PushBulk(jobs)
+	rejected := make(map[int]string, len(errs))
+	for idx, err := range errs {
+		rejected[idx] = tagError(err).Error()
+	}
+	res, err := json.Marshal(rejected)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Result(res)
+}
+
 func fetch(c *Connection, s *Server, cmd string) {
 	if c.client.state != Running {
 		// quiet or terminated workers should not get new jobs
@@ -77,8 +140,28 @@ func fetch(c *Connection, s *Server, cmd string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	qs := strings.Split(cmd, " ")[1:]
-	job, err := s.manager.Fetch(ctx, c.client.Wid, qs...)
+	args := strings.Split(cmd, " ")[1:]
+	count := 0
+	if len(args) > 0 && strings.HasPrefix(args[0], "count=") {
+		n, err := strconv.Atoi(args[0][6:])
+		if err != nil || n < 1 {
+			c.Error(cmd, fmt.Errorf("Invalid fetch count %s", args[0][6:]))
+			return
+		}
+		count = n
+		args = args[1:]
+	}
+	qs, err := c.fetchOrder(args)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	if count > 0 {
+		fetchBatch(ctx, c, s, cmd, count, qs)
+		return
+	}
+
+	job, err := c.tenant.manager.Fetch(ctx, c.client.Wid, qs...)
 	if err != nil {
 		c.Error(cmd, err)
 		return
@@ -95,21 +178,73 @@ func fetch(c *Connection, s *Server, cmd string) {
 	}
 }
 
+// Order the queues for this fetch according to the dispatch mode, given
+// as "mode=<mode>" before the queues or in the worker's HELLO.
+// Weighted queues imply weighted mode.
+func (c *Connection) fetchOrder(args []string) ([]string, error) {
+	mode := c.client.FetchMode
+	if len(args) > 0 && strings.HasPrefix(args[0], "mode=") {
+		mode = args[0][5:]
+		args = args[1:]
+	}
+	if mode == "" {
+		mode = manager.StrictMode
+		for _, arg := range args {
+			if strings.Contains(arg, ":") {
+				mode = manager.WeightedMode
+				break
+			}
+		}
+	}
+	if !manager.ValidFetchMode(mode) {
+		return nil, fmt.Errorf("Invalid fetch mode %s", mode)
+	}
+
+	queues, err := manager.ParseQueues(args)
+	if err != nil {
+		return nil, err
+	}
+	turn := atomic.AddUint64(&c.fetches, 1)
+	return manager.OrderQueues(mode, queues, turn), nil
+}
+
+// FETCH count=<count> q1 q2 replies with a JSON array of up to count jobs.
+func fetchBatch(ctx context.Context, c *Connection, s *Server, cmd string, count int, qs []string) {
+	jobs, err := c.tenant.manager.FetchN(ctx, c.client.Wid, count, qs...)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	res, err := json.Marshal(jobs)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Result(res)
+}
+
 func ack(c *Connection, s *Server, cmd string) {
 	data := cmd[4:]
 
-	var hash map[string]string
-	err := json.Unmarshal([]byte(data), &hash)
-	if err != nil {
+	var payload struct {
+		Jid    string          `json:"jid"`
+		Result json.RawMessage `json:"result"`
+	}
+	err := json.Unmarshal([]byte(data), &payload)
+	if err != nil || payload.Jid == "" {
 		c.Error(cmd, fmt.Errorf("Invalid ACK %s", data))
 		return
 	}
-	jid, ok := hash["jid"]
-	if !ok {
-		c.Error(cmd, fmt.Errorf("Invalid ACK %s", data))
+	if string(payload.Result) == "null" {
+		payload.Result = nil
+	}
+
+	ttl := time.Duration(s.Options.Int("results", "ttl", int(manager.DefaultResultTTL/time.Second))) * time.Second
+	_, err = c.tenant.manager.AcknowledgeResult(payload.Jid, payload.Result, ttl)
+	if errors.Is(err, manager.ErrCanceled) {
+		c.Error(cmd, newTaggedError("CANCELED", err))
 		return
 	}
-	_, err = s.manager.Acknowledge(jid)
 	if err != nil {
 		c.Error(cmd, err)
 		return
@@ -128,7 +263,7 @@ func fail(c *Connection, s *Server, cmd string) {
 		return
 	}
 
-	err = s.manager.Fail(&failure)
+	err = c.tenant.manager.Fail(&failure)
 	if err != nil {
 		c.Error(cmd, err)
 		return
@@ -136,8 +271,156 @@ func fail(c *Connection, s *Server, cmd string) {
 	c.Ok()
 }
 
+func trackJob(c *Connection, s *Server, cmd string) {
+	args := strings.Split(cmd, " ")
+	if len(args) != 2 || args[1] == "" {
+		c.Error(cmd, fmt.Errorf("Invalid TRACK %s", cmd))
+		return
+	}
+
+	status, err := c.tenant.manager.Lookup(args[1])
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	if status == nil {
+		c.Result(nil)
+		return
+	}
+
+	res, err := json.Marshal(status)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Result(res)
+}
+
+func progress(c *Connection, s *Server, cmd string) {
+	data := cmd[len("PROGRESS"):]
+
+	var payload manager.ProgressPayload
+	err := json.Unmarshal([]byte(data), &payload)
+	if err != nil {
+		c.Error(cmd, fmt.Errorf("Invalid PROGRESS %s", data))
+		return
+	}
+
+	err = c.tenant.manager.Progress(c.client.Wid, &payload)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Ok()
+}
+
+func extend(c *Connection, s *Server, cmd string) {
+	args := strings.Split(cmd, " ")
+	if len(args) != 3 || args[1] == "" {
+		c.Error(cmd, fmt.Errorf("Invalid EXTEND %s", cmd))
+		return
+	}
+	secs, err := strconv.Atoi(args[2])
+	if err != nil {
+		c.Error(cmd, fmt.Errorf("Invalid EXTEND %s", cmd))
+		return
+	}
+
+	err = c.tenant.manager.Extend(c.client.Wid, args[1], secs)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Ok()
+}
+
+// RESCHEDULE <jid> <time> moves a scheduled job to the given
+// ISO8601 time, "now" enqueues it immediately.
+func reschedule(c *Connection, s *Server, cmd string) {
+	args := strings.Split(cmd, " ")
+	if len(args) != 3 || args[1] == "" {
+		c.Error(cmd, fmt.Errorf("Invalid RESCHEDULE %s", cmd))
+		return
+	}
+	at := time.Now()
+	if args[2] != "now" {
+		t, err := util.ParseTime(args[2])
+		if err != nil {
+			c.Error(cmd, fmt.Errorf("Invalid RESCHEDULE time %s", args[2]))
+			return
+		}
+		at = t
+	}
+
+	err := c.tenant.manager.Reschedule(args[1], at)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Ok()
+}
+
+func cancel(c *Connection, s *Server, cmd string) {
+	args := strings.Split(cmd, " ")
+	if len(args) != 2 || args[1] == "" {
+		c.Error(cmd, fmt.Errorf("Invalid CANCEL %s", cmd))
+		return
+	}
+
+	err := c.tenant.manager.Cancel(args[1])
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Ok()
+}
+
+func backup(c *Connection, s *Server, cmd string) {
+	info, err := s.Backup()
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	res, err := json.Marshal(info)
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Result(res)
+}
+
+// Don't tie up a connection forever waiting for a result.
+const maxResultWait = int(client.MaxResultWait / time.Second)
+
+func result(c *Connection, s *Server, cmd string) {
+	args := strings.Split(cmd, " ")
+	if len(args) < 2 || len(args) > 3 || args[1] == "" {
+		c.Error(cmd, fmt.Errorf("Invalid RESULT %s", cmd))
+		return
+	}
+	wait := 0
+	if len(args) == 3 {
+		secs, err := strconv.Atoi(args[2])
+		if err != nil || secs < 0 || secs > maxResultWait {
+			c.Error(cmd, fmt.Errorf("Invalid RESULT timeout %s, must be 0-%d", args[2], maxResultWait))
+			return
+		}
+		wait = secs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wait)*time.Second)
+	defer cancel()
+
+	data, err := c.tenant.manager.Result(ctx, args[1])
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Result(data)
+}
+
 func info(c *Connection, s *Server, cmd string) {
-	data, err := s.CurrentState()
+	data, err := s.TenantState(c.tenant)
 	if err != nil {
 		c.Error(cmd, err)
 		return
@@ -162,14 +445,25 @@ func heartbeat(c *Connection, s *Server, cmd string) {
 	}
 
 	worker, ok := s.workers.heartbeat(&client, nil)
-	if !ok {
+	if !ok || worker.tenant != c.tenant.Name {
 		c.Error(cmd, fmt.Errorf("Unknown worker %s", client.Wid))
 		return
 	}
 
-	if worker.state == Running {
+	canceled := c.tenant.manager.CanceledJobs(client.Wid)
+	if worker.state == Running && len(canceled) == 0 {
 		c.Ok()
-	} else {
-		c.Result([]byte(fmt.Sprintf(`{"state":"%s"}`, stateString(worker.state))))
+		return
+	}
+
+	reply := struct {
+		State    string   `json:"state,omitempty"`
+		Canceled []string `json:"canceled,omitempty"`
+	}{stateString(worker.state), canceled}
+	res, err := json.Marshal(reply)
+	if err != nil {
+		c.Error(cmd, err)
+		return
 	}
+	c.Result(res)
 }
@@ -1,15 +1,44 @@
 package server
 
-import "github.com/hunter-io/faktory/util"
+import (
+	"github.com/hunter-io/faktory/manager"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+)
 
 type ServerOptions struct {
 	Binding          string
 	StorageDirectory string
-	RedisSock        string
-	ConfigDirectory  string
-	Environment      string
-	Password         string
-	GlobalConfig     map[string]any
+	// "redis", the default, or "embedded"
+	DBType    string
+	RedisSock string
+	// Connect to this Redis instead of the one booted at RedisSock
+	Redis *storage.ExternalRedis
+	// Boot as a read-only replica, see ReplicaOptions
+	Replica         *ReplicaOptions
+	ConfigDirectory string
+	Environment     string
+	Password        string
+	// Tenant passwords by name, see Tenant
+	Tenants      map[string]string
+	GlobalConfig map[string]any
+}
+
+// applyConfig gives the manager the parts of the config which
+// can be reloaded.
+func (s *Server) applyConfig(m manager.Manager) error {
+	limits, err := s.limits()
+	if err != nil {
+		return err
+	}
+	defaults, queues, err := s.queueOptions()
+	if err != nil {
+		return err
+	}
+
+	m.SetLimits(limits)
+	m.SetQueueOptions(defaults, queues)
+	return nil
 }
 
 func (so *ServerOptions) String(subsys string, key string, defval string) string {
@@ -22,6 +51,19 @@ func (so *ServerOptions) String(subsys string, key string, defval string) string
 	return str
 }
 
+func (so *ServerOptions) Int(subsys string, key string, defval int) int {
+	val := so.Config(subsys, key, defval)
+	switch num := val.(type) {
+	case int:
+		return num
+	case int64:
+		return int(num)
+	default:
+		util.Warnf("Config error: %s/%s is not an Integer", subsys, key)
+		return defval
+	}
+}
+
 func (so *ServerOptions) Config(subsys string, key string, defval any) any {
 	mapp, ok := so.GlobalConfig[subsys]
 	if !ok {
@@ -16,8 +16,13 @@ import (
 // https://redis.io/topics/protocol
 type Connection struct {
 	client *ClientData
+	// whose namespace the client logged in to
+	tenant *Tenant
 	conn   net.Conn
 	buf    *bufio.Reader
+
+	// number of FETCHes, rotates the queues in round-robin mode
+	fetches uint64
 }
 
 func (c *Connection) Close() error {
@@ -25,7 +30,7 @@ func (c *Connection) Close() error {
 }
 
 func (c *Connection) Error(cmd string, err error) error {
-	re, ok := err.(*taggedError)
+	re, ok := tagError(err).(*taggedError)
 	if ok {
 		_, err = c.conn.Write([]byte(fmt.Sprintf("-%s\r\n", re.Error())))
 	} else {
@@ -32,6 +32,9 @@ func TestConnectionBasics(t *testing.T) {
 	dc.Error("bad command", fmt.Errorf("permission denied"))
 	assert.Equal(t, "-ERR permission denied\r\n", output(dc))
 
+	dc.Error("ACK", &net.OpError{Op: "dial", Net: "unix", Err: fmt.Errorf("connection refused")})
+	assert.Equal(t, "-UNAVAILABLE dial unix: connection refused\r\n", output(dc))
+
 	dc.Close()
 	assert.Equal(t, "", output(dc))
 }
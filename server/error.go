@@ -14,3 +14,12 @@ func (re *taggedError) Error() string {
 func newTaggedError(code string, err error) *taggedError {
 	return &taggedError{Code: code, Err: err}
 }
+
+// ErrorCode is the code clients of the command port get for err, e.g.
+// INVALID or FULL, ERR if it's not one of the tagged errors.
+func ErrorCode(err error) string {
+	if te, ok := tagError(err).(*taggedError); ok {
+		return te.Code
+	}
+	return "ERR"
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"io"
+
+	"github.com/hunter-io/faktory/storage"
+)
+
+// Export writes the dataset to w as JSON Lines.  Like Restore it is
+// used offline, before the server is booted.
+func (s *Server) Export(w io.Writer) (int, error) {
+	store, err := s.openStore()
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+
+	return storage.Export(store, w)
+}
+
+// Import loads an export into the empty store, a dry run only
+// reports what would be written.
+func (s *Server) Import(r io.Reader, dryRun bool) (*storage.ImportSummary, error) {
+	store, err := s.openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	return storage.Import(store, r, dryRun)
+}
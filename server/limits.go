@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hunter-io/faktory/manager"
+)
+
+/*
+ * Payload limits are configured in the [limits] section:
+ *
+ *   [limits]
+ *   max_payload = 1048576
+ *   max_args = 100
+ *
+ *   [limits.queues.bulk]
+ *   max_payload = 10485760
+ *
+ *   [limits.jobtypes.ImportCsv]
+ *   max_args = 2
+ *   schema = "schemas/import_csv.json"
+ *
+ * Schema paths are relative to conf.d.
+ */
+func (s *Server) limits() (*manager.Limits, error) {
+	raw, ok := s.Options.GlobalConfig["limits"]
+	if !ok {
+		return nil, nil
+	}
+	section, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("Invalid configuration, expected a limits subsystem")
+	}
+
+	limits := &manager.Limits{
+		Queues:   map[string]manager.Limit{},
+		Jobtypes: map[string]manager.Limit{},
+	}
+	var err error
+	limits.Default, err = s.limit("limits", section)
+	if err != nil {
+		return nil, err
+	}
+
+	for kind, dest := range map[string]map[string]manager.Limit{"queues": limits.Queues, "jobtypes": limits.Jobtypes} {
+		raw, ok := section[kind]
+		if !ok {
+			continue
+		}
+		entries, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("Invalid configuration, expected limits.%s to be a table", kind)
+		}
+		for name, raw := range entries {
+			rule := fmt.Sprintf("limits.%s.%s", kind, name)
+			cfg, ok := raw.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("Invalid configuration, expected %s to be a table", rule)
+			}
+			dest[name], err = s.limit(rule, cfg)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return limits, nil
+}
+
+func (s *Server) limit(rule string, cfg map[string]any) (manager.Limit, error) {
+	var limit manager.Limit
+	var err error
+	limit.MaxPayload, err = limitInt(rule, cfg, "max_payload")
+	if err != nil {
+		return limit, err
+	}
+	limit.MaxArgs, err = limitInt(rule, cfg, "max_args")
+	if err != nil {
+		return limit, err
+	}
+
+	raw, ok := cfg["schema"]
+	if !ok {
+		return limit, nil
+	}
+	path, ok := raw.(string)
+	if !ok {
+		return limit, fmt.Errorf("Invalid configuration, %s.schema must be a path", rule)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.Options.ConfigDirectory, "conf.d", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return limit, fmt.Errorf("%s.schema: %w", rule, err)
+	}
+	limit.Schema, err = manager.ParseSchema(data)
+	if err != nil {
+		return limit, fmt.Errorf("%s.schema %s: %w", rule, path, err)
+	}
+	return limit, nil
+}
+
+func limitInt(rule string, cfg map[string]any, key string) (int, error) {
+	num := 0
+	switch val := cfg[key].(type) {
+	case nil:
+	case int:
+		num = val
+	case int64:
+		num = int(val)
+	default:
+		num = -1
+	}
+	if num < 0 {
+		return 0, fmt.Errorf("Invalid configuration, %s.%s must be a positive integer", rule, key)
+	}
+	return num, nil
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitsConfig(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "conf.d", "schemas"), 0o755))
+	err := os.WriteFile(filepath.Join(dir, "conf.d", "schemas", "charge.json"), []byte(`{"items": [{"type": "integer"}]}`), 0o644)
+	assert.NoError(t, err)
+
+	s, err := NewServer(&ServerOptions{StorageDirectory: dir, ConfigDirectory: dir})
+	assert.NoError(t, err)
+
+	limits, err := s.limits()
+	assert.NoError(t, err)
+	assert.Nil(t, limits)
+
+	s.Options.GlobalConfig = map[string]any{
+		"limits": map[string]any{
+			"max_payload": int64(1024),
+			"queues": map[string]any{
+				"bulk": map[string]any{"max_args": int64(1)},
+			},
+			"jobtypes": map[string]any{
+				"Charge": map[string]any{"schema": "schemas/charge.json"},
+			},
+		},
+	}
+	limits, err = s.limits()
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, limits.Default.MaxPayload)
+	assert.Equal(t, 1, limits.Queues["bulk"].MaxArgs)
+	assert.NotNil(t, limits.Jobtypes["Charge"].Schema)
+
+	s.Options.GlobalConfig["limits"] = map[string]any{"max_args": "lots"}
+	_, err = s.limits()
+	assert.Error(t, err)
+
+	s.Options.GlobalConfig["limits"] = map[string]any{
+		"jobtypes": map[string]any{
+			"Charge": map[string]any{"schema": "missing.json"},
+		},
+	}
+	_, err = s.limits()
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * Redis rejects every write once it reaches maxmemory, ACK and FAIL
+ * included.  The memory monitor rejects pushes with OOM well before
+ * then so workers can drain the backlog:
+ *
+ *   [redis]
+ *   maxmemory = "2gb"
+ *   # percentages of maxmemory
+ *   soft_watermark = 80
+ *   hard_watermark = 95
+ *
+ * Past the soft watermark it warns in the log and the Web UI.  Only
+ * the Redis booted by Faktory is given the maxmemory, in its redis.conf
+ * and on reload; the watermarks apply to an external Redis's own.
+ */
+const (
+	DefaultSoftWatermark = 80
+	DefaultHardWatermark = 95
+)
+
+const (
+	memoryOK int32 = iota
+	memorySoft
+	memoryHard
+)
+
+type memoryMonitor struct {
+	s     *Server
+	usage func() (int64, int64, error)
+	level int32
+	used  int64
+	max   int64
+}
+
+func newMemoryMonitor(s *Server, store storage.Redis) *memoryMonitor {
+	return &memoryMonitor{s: s, usage: func() (int64, int64, error) {
+		return storage.MemoryUsage(store)
+	}}
+}
+
+func (mm *memoryMonitor) Name() string {
+	return "Memory"
+}
+
+func (mm *memoryMonitor) Execute() error {
+	used, max, err := mm.usage()
+	if err != nil {
+		// the storage monitor logs outages
+		return nil
+	}
+	atomic.StoreInt64(&mm.used, used)
+	atomic.StoreInt64(&mm.max, max)
+
+	soft, hard := mm.s.watermarks()
+	level := memoryOK
+	pct := mm.percent()
+	if max > 0 && pct >= hard {
+		level = memoryHard
+	} else if max > 0 && pct >= soft {
+		level = memorySoft
+	}
+	if atomic.SwapInt32(&mm.level, level) == level {
+		return nil
+	}
+
+	reason := ""
+	switch level {
+	case memoryHard:
+		reason = fmt.Sprintf("redis memory is past the %d%% hard watermark", hard)
+		util.Warnf("Redis memory is %d%% full, rejecting pushes until it drops below %d%%", pct, hard)
+	case memorySoft:
+		util.Warnf("Redis memory is %d%% full, pushes will be rejected at %d%%", pct, hard)
+	default:
+		util.Infof("Redis memory is down to %d%%", pct)
+	}
+	for _, t := range mm.s.everyTenant() {
+		t.manager.SetOutOfMemory(reason)
+	}
+	return nil
+}
+
+func (mm *memoryMonitor) percent() int {
+	max := atomic.LoadInt64(&mm.max)
+	if max <= 0 {
+		return 0
+	}
+	return int(atomic.LoadInt64(&mm.used) * 100 / max)
+}
+
+func (mm *memoryMonitor) levelName() string {
+	switch atomic.LoadInt32(&mm.level) {
+	case memoryHard:
+		return "hard"
+	case memorySoft:
+		return "soft"
+	}
+	return "ok"
+}
+
+func (mm *memoryMonitor) Stats() map[string]any {
+	return map[string]any{
+		"used":    atomic.LoadInt64(&mm.used),
+		"max":     atomic.LoadInt64(&mm.max),
+		"percent": mm.percent(),
+		"level":   mm.levelName(),
+	}
+}
+
+// RedisMemory is "ok", or "soft" or "hard" once Redis is past that
+// watermark, and the percentage of maxmemory used.
+func (s *Server) RedisMemory() (string, int) {
+	if s.memory == nil {
+		return "ok", 0
+	}
+	return s.memory.levelName(), s.memory.percent()
+}
+
+// watermarks are read on each check so they pick up config reloads.
+func (s *Server) watermarks() (int, int) {
+	soft := s.Options.Int("redis", "soft_watermark", DefaultSoftWatermark)
+	hard := s.Options.Int("redis", "hard_watermark", DefaultHardWatermark)
+	if soft < 1 || soft > hard || hard > 100 {
+		util.Warnf("Config error: redis watermarks need 0 < soft_watermark <= hard_watermark <= 100, not %d and %d", soft, hard)
+		return DefaultSoftWatermark, DefaultHardWatermark
+	}
+	return soft, hard
+}
+
+// MaxMemory reads the maxmemory for the Redis booted by Faktory, empty
+// if it isn't set:
+//
+//	[redis]
+//	maxmemory = "2gb"
+func MaxMemory(cfg map[string]any) (string, error) {
+	section, _ := cfg["redis"].(map[string]any)
+	switch val := section["maxmemory"].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case int:
+		return strconv.Itoa(val), nil
+	default:
+		return "", fmt.Errorf("Config error: redis/maxmemory must be a size like \"2gb\" or bytes, not %v", val)
+	}
+}
+
+// applyMaxMemory gives the Redis booted by Faktory the maxmemory of a
+// reloaded config, lifting the limit if it's been removed.  It boots
+// with the one in redis.conf.
+func (s *Server) applyMaxMemory(store storage.Store) error {
+	rs, ok := store.(storage.Redis)
+	if !ok || s.Options.Redis != nil {
+		return nil
+	}
+
+	size, err := MaxMemory(s.Options.GlobalConfig)
+	if err != nil {
+		return err
+	}
+	if size == "" {
+		size = "0"
+	}
+	err = storage.SetMaxMemory(rs, size)
+	if err != nil {
+		return fmt.Errorf("redis maxmemory %s: %w", size, err)
+	}
+	return nil
+}
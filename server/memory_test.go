@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/manager"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMonitor(t *testing.T) {
+	store, err := storage.OpenMemory("memory-monitor")
+	assert.NoError(t, err)
+	defer store.Close()
+
+	m := manager.NewManager(store)
+	s := &Server{
+		Options: &ServerOptions{GlobalConfig: map[string]any{
+			"redis": map[string]any{"soft_watermark": int64(50)},
+		}},
+		root: &Tenant{store: store, manager: m},
+	}
+	var used int64
+	mm := &memoryMonitor{s: s, usage: func() (int64, int64, error) {
+		return used, 1000, nil
+	}}
+	s.memory = mm
+
+	used = 400
+	assert.NoError(t, mm.Execute())
+	level, pct := s.RedisMemory()
+	assert.Equal(t, "ok", level)
+	assert.Equal(t, 40, pct)
+
+	used = 600
+	assert.NoError(t, mm.Execute())
+	level, _ = s.RedisMemory()
+	assert.Equal(t, "soft", level)
+	assert.NoError(t, m.Push(client.NewJob("Report", 1)))
+
+	used = 960
+	assert.NoError(t, mm.Execute())
+	level, _ = s.RedisMemory()
+	assert.Equal(t, "hard", level)
+	err = m.Push(client.NewJob("Report", 2))
+	assert.True(t, errors.Is(err, manager.ErrOOM), "got %v", err)
+	assert.True(t, strings.HasPrefix(tagError(err).Error(), "OOM "), tagError(err).Error())
+
+	used = 900
+	assert.NoError(t, mm.Execute())
+	level, _ = s.RedisMemory()
+	assert.Equal(t, "soft", level)
+	assert.NoError(t, m.Push(client.NewJob("Report", 3)))
+}
+
+func TestReloadMaxMemory(t *testing.T) {
+	if testDBType() != "redis" {
+		t.Skip("maxmemory requires redis")
+	}
+
+	dir := t.TempDir()
+	sock := dir + "/redis.sock"
+	stopper, err := storage.BootConfiguredRedis(dir, sock, &storage.RedisConfig{MaxMemory: "1gb"})
+	assert.NoError(t, err)
+	defer stopper()
+	store, err := storage.OpenRedis(sock)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	cfg := map[string]any{"redis": map[string]any{"maxmemory": int64(2 << 30)}}
+	s := &Server{Options: &ServerOptions{GlobalConfig: cfg}}
+	assert.NoError(t, s.applyMaxMemory(store))
+	conf, err := os.ReadFile(filepath.Join(dir, "redis.conf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nmaxmemory 2147483648\n")
+
+	// removing it lifts the limit rather than keeping the old one
+	delete(cfg["redis"].(map[string]any), "maxmemory")
+	assert.NoError(t, s.applyMaxMemory(store))
+	conf, err = os.ReadFile(filepath.Join(dir, "redis.conf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nmaxmemory 0\n")
+
+	cfg["redis"].(map[string]any)["maxmemory"] = true
+	assert.Error(t, s.applyMaxMemory(store))
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/hunter-io/faktory/manager"
+)
+
+/*
+ * Queue options are configured in the [queues] section, settings at
+ * the top apply to every queue unless a [queues.<name>] section
+ * overrides them:
+ *
+ *   [queues]
+ *   retry = 25
+ *   backpressure = 0
+ *
+ *   [queues.default]
+ *   reserve_for = 600
+ *   priority = 5
+ *   backoff = "linear"
+ *   backpressure = 100000
+ */
+func (s *Server) queueOptions() (manager.QueueOptions, map[string]manager.QueueOptions, error) {
+	var defaults manager.QueueOptions
+	queues := map[string]manager.QueueOptions{}
+
+	raw, ok := s.Options.GlobalConfig["queues"]
+	if !ok {
+		return defaults, queues, nil
+	}
+	section, ok := raw.(map[string]any)
+	if !ok {
+		return defaults, queues, fmt.Errorf("Invalid configuration, expected a queues subsystem")
+	}
+
+	err := parseQueueOptions("queues", section, &defaults)
+	if err != nil {
+		return defaults, queues, err
+	}
+	for name, raw := range section {
+		cfg, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		opts := defaults
+		err := parseQueueOptions("queues."+name, cfg, &opts)
+		if err != nil {
+			return defaults, queues, err
+		}
+		queues[name] = opts
+	}
+	return defaults, queues, nil
+}
+
+// Override opts with the settings given in cfg.
+func parseQueueOptions(rule string, cfg map[string]any, opts *manager.QueueOptions) error {
+	ints := map[string]func(int){
+		"retry":        func(v int) { opts.Retry = v },
+		"reserve_for":  func(v int) { opts.ReserveFor = v },
+		"priority":     func(v int) { opts.Priority = uint8(v) },
+		"backpressure": func(v int) { opts.Backpressure = uint64(v) },
+	}
+	for key, set := range ints {
+		if _, ok := cfg[key]; !ok {
+			continue
+		}
+		num, err := limitInt(rule, cfg, key)
+		if err != nil {
+			return err
+		}
+		if key == "priority" && num > 9 {
+			return fmt.Errorf("Invalid configuration, %s.priority must be 1-9", rule)
+		}
+		set(num)
+	}
+
+	if raw, ok := cfg["backoff"]; ok {
+		backoff, ok := raw.(string)
+		if !ok || !manager.ValidBackoff(backoff) {
+			return fmt.Errorf("Invalid configuration, %s.backoff must be exponential, linear or constant", rule)
+		}
+		opts.Backoff = backoff
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueOptionsConfig(t *testing.T) {
+	s, err := NewServer(&ServerOptions{StorageDirectory: t.TempDir()})
+	assert.NoError(t, err)
+
+	defaults, queues, err := s.queueOptions()
+	assert.NoError(t, err)
+	assert.Zero(t, defaults.Retry)
+	assert.Empty(t, queues)
+
+	s.Options.GlobalConfig = map[string]any{
+		"queues": map[string]any{
+			"retry":        int64(5),
+			"backpressure": int64(0),
+			"default": map[string]any{
+				"reserve_for":  int64(600),
+				"backoff":      "linear",
+				"backpressure": int64(100000),
+			},
+			"critical": map[string]any{
+				"retry":    int64(10),
+				"priority": int64(9),
+			},
+		},
+	}
+	defaults, queues, err = s.queueOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, defaults.Retry)
+	assert.Equal(t, 5, queues["default"].Retry)
+	assert.Equal(t, 600, queues["default"].ReserveFor)
+	assert.Equal(t, "linear", queues["default"].Backoff)
+	assert.EqualValues(t, 100000, queues["default"].Backpressure)
+	assert.Equal(t, 10, queues["critical"].Retry)
+	assert.EqualValues(t, 9, queues["critical"].Priority)
+	assert.Zero(t, queues["critical"].Backpressure)
+
+	for _, bad := range []map[string]any{
+		{"backoff": "random"},
+		{"priority": int64(10)},
+		{"retry": "lots"},
+		{"backpressure": int64(-1)},
+	} {
+		s.Options.GlobalConfig = map[string]any{"queues": map[string]any{"default": bad}}
+		_, _, err = s.queueOptions()
+		assert.Error(t, err, "%v", bad)
+	}
+}
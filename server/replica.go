@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/storage"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * A replica follows the primary's Redis and serves only read-only
+ * commands and the Web UI until it's promoted, by PROMOTE or once the
+ * primary has missed heartbeats for FailoverAfter.  The promoted
+ * replica reloads the working set and starts running jobs; clients
+ * with both addresses in client.Server.Addresses switch over when
+ * they reconnect.
+ *
+ * Automatic failover can't tell a dead primary from a network
+ * partition, so only enable it if the replica can't be cut off from
+ * the primary while clients can still reach it.
+ */
+type ReplicaOptions struct {
+	// The primary's command port, host:port
+	Primary string
+	// Promote after the primary misses heartbeats this long, 0 to
+	// only promote by command
+	FailoverAfter time.Duration
+}
+
+// The commands a replica serves before it's promoted, none of them
+// write to Redis.
+var replicaCommands = map[string]bool{
+	"END":     true,
+	"INFO":    true,
+	"TRACK":   true,
+	"BACKUP":  true,
+	"PROMOTE": true,
+}
+
+func (s *Server) IsReplica() bool {
+	return atomic.LoadInt32(&s.replica) == 1
+}
+
+func (s *Server) role() string {
+	if s.IsReplica() {
+		return "replica"
+	}
+	return "primary"
+}
+
+func (s *Server) errReplica() error {
+	return newTaggedError("REPLICA", fmt.Errorf("Read-only replica of %s", s.Options.Replica.Primary))
+}
+
+// Promote turns a replica into the primary: its Redis stops following
+// the primary, the reservations are reloaded from the working set and
+// every command is served.
+func (s *Server) Promote() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.IsReplica() {
+		return errors.New("Not a replica")
+	}
+
+	util.Warnf("Promoting replica of %s to primary", s.Options.Replica.Primary)
+	err := storage.StopReplication(s.store)
+	if err != nil {
+		return fmt.Errorf("promote: %w", err)
+	}
+	for _, t := range s.everyTenant() {
+		err = t.manager.LoadWorkingSet()
+		if err != nil {
+			return fmt.Errorf("promote: %w", err)
+		}
+	}
+	s.addJobTasks(s.taskRunner)
+	atomic.StoreInt32(&s.replica, 0)
+	util.Infof("Promoted, %d jobs in progress", s.manager.WorkingCount())
+	return nil
+}
+
+func promote(c *Connection, s *Server, cmd string) {
+	err := s.Promote()
+	if err != nil {
+		c.Error(cmd, err)
+		return
+	}
+	c.Ok()
+}
+
+/*
+ * Heartbeats the primary from a replica, promoting the replica once
+ * the primary has been unreachable for FailoverAfter.
+ */
+type primaryMonitor struct {
+	s        *Server
+	cl       *client.Client
+	lastSeen int64
+}
+
+func newPrimaryMonitor(s *Server) *primaryMonitor {
+	// give the primary the full timeout from boot
+	return &primaryMonitor{s: s, lastSeen: time.Now().Unix()}
+}
+
+func (pm *primaryMonitor) Name() string {
+	return "Primary"
+}
+
+func (pm *primaryMonitor) Execute() error {
+	if !pm.s.IsReplica() {
+		return nil
+	}
+
+	err := pm.beat()
+	if err == nil {
+		atomic.StoreInt64(&pm.lastSeen, time.Now().Unix())
+		return nil
+	}
+	if pm.cl != nil {
+		pm.cl.Close()
+		pm.cl = nil
+	}
+
+	opts := pm.s.Options.Replica
+	down := time.Since(time.Unix(atomic.LoadInt64(&pm.lastSeen), 0))
+	util.Warnf("Primary %s missed heartbeat, down for %v: %v", opts.Primary, down.Round(time.Second), err)
+	if opts.FailoverAfter > 0 && down >= opts.FailoverAfter {
+		// not inline, promoting adds tasks to the runner calling us
+		go func() {
+			err := pm.s.Promote()
+			if err != nil {
+				util.Warnf("Unable to fail over: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+func (pm *primaryMonitor) beat() error {
+	if pm.cl == nil {
+		srv := client.DefaultServer()
+		srv.Address = pm.s.Options.Replica.Primary
+		cl, err := client.Dial(srv, pm.s.Options.Password)
+		if err != nil {
+			return err
+		}
+		pm.cl = cl
+	}
+	_, err := pm.cl.Info()
+	return err
+}
+
+func (pm *primaryMonitor) Stats() map[string]any {
+	return map[string]any{
+		"primary":   pm.s.Options.Replica.Primary,
+		"last_seen": atomic.LoadInt64(&pm.lastSeen),
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplica(t *testing.T) {
+	if testDBType() != "redis" {
+		t.Skip("replication requires redis")
+	}
+
+	replica := func(opts *ServerOptions) {
+		// nothing listens there, it must be promoted by hand
+		opts.Replica = &ReplicaOptions{Primary: "localhost:7401"}
+	}
+	runServerWith("localhost:7402", replica, func() {
+		srv := client.DefaultServer()
+		srv.Address = "localhost:7402"
+		cl, err := client.Dial(srv, "")
+		assert.NoError(t, err)
+		defer cl.Close()
+
+		err = cl.Push(client.NewJob("Thing", 1))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "REPLICA")
+
+		info, err := cl.Info()
+		assert.NoError(t, err)
+		assert.Equal(t, "replica", info["server"].(map[string]interface{})["role"])
+
+		// a list of servers skips the replica
+		srv.Addresses = []string{"localhost:7402"}
+		_, err = client.Dial(srv, "")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "is a replica")
+
+		err = cl.Promote()
+		assert.NoError(t, err)
+		err = cl.Promote()
+		assert.Error(t, err)
+
+		err = cl.Push(client.NewJob("Thing", 1))
+		assert.NoError(t, err)
+		info, err = cl.Info()
+		assert.NoError(t, err)
+		assert.Equal(t, "primary", info["server"].(map[string]interface{})["role"])
+
+		promoted, err := client.Dial(srv, "")
+		assert.NoError(t, err)
+		promoted.Close()
+	})
+}
+
+func TestReplicaFailover(t *testing.T) {
+	if testDBType() != "redis" {
+		t.Skip("replication requires redis")
+	}
+
+	replica := func(opts *ServerOptions) {
+		opts.Replica = &ReplicaOptions{Primary: "localhost:7401", FailoverAfter: time.Second}
+	}
+	runServerWith("localhost:7403", replica, func() {
+		srv := client.DefaultServer()
+		srv.Addresses = []string{"localhost:7401", "localhost:7403"}
+
+		var err error
+		var cl *client.Client
+		for i := 0; i < 50; i++ {
+			cl, err = client.Dial(srv, "")
+			if err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, "localhost:7403", cl.Location)
+		cl.Close()
+	})
+}
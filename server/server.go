@@ -3,7 +3,6 @@ package server
 import (
 	"bufio"
 	"crypto/sha256"
-	"crypto/subtle"
 	"fmt"
 	"io"
 	"math/rand"
@@ -35,11 +34,18 @@ type Server struct {
 	listener   net.Listener
 	store      storage.Store
 	manager    manager.Manager
+	root       *Tenant
+	tenants    []*Tenant
 	workers    *workers
 	taskRunner *taskRunner
-	mu         sync.Mutex
-	stopper    chan bool
-	closed     bool
+	monitor    *storeMonitor
+	memory     *memoryMonitor
+	// 1 until a replica is promoted
+	replica  int32
+	mu       sync.Mutex
+	backupMu sync.Mutex
+	stopper  chan bool
+	closed   bool
 }
 
 func NewServer(opts *ServerOptions) (*Server, error) {
@@ -58,6 +64,9 @@ func NewServer(opts *ServerOptions) (*Server, error) {
 		stopper: make(chan bool),
 		closed:  false,
 	}
+	if opts.Replica != nil {
+		s.replica = 1
+	}
 
 	return s, nil
 }
@@ -75,6 +84,18 @@ func (s *Server) Manager() manager.Manager {
 }
 
 func (s *Server) Reload() {
+	for _, t := range s.everyTenant() {
+		err := s.applyConfig(t.manager)
+		if err != nil {
+			util.Warnf("Unable to reload configuration: %v", err)
+			break
+		}
+	}
+	err := s.applyMaxMemory(s.store)
+	if err != nil {
+		util.Warnf("Unable to reload configuration: %v", err)
+	}
+
 	for _, x := range s.Subsystems {
 		err := x.Reload(s)
 		if err != nil {
@@ -88,7 +109,7 @@ func (s *Server) AddTask(everySec int64, task Taskable) {
 }
 
 func (s *Server) Boot() error {
-	store, err := storage.Open("redis", s.Options.RedisSock)
+	store, err := s.openStore()
 	if err != nil {
 		return err
 	}
@@ -99,10 +120,26 @@ func (s *Server) Boot() error {
 		return err
 	}
 
+	m := manager.NewManager(store)
+	err = s.applyConfig(m)
+	if err != nil {
+		listener.Close()
+		store.Close()
+		return err
+	}
+	tenants, err := s.openTenants(store)
+	if err != nil {
+		listener.Close()
+		store.Close()
+		return err
+	}
+
 	s.mu.Lock()
 	s.store = store
 	s.workers = newWorkers()
-	s.manager = manager.NewManager(store)
+	s.manager = m
+	s.root = &Tenant{password: s.Options.Password, store: store, manager: m}
+	s.tenants = tenants
 	s.listener = listener
 	s.stopper = make(chan bool)
 	s.startTasks()
@@ -111,6 +148,18 @@ func (s *Server) Boot() error {
 	return nil
 }
 
+func (s *Server) openStore() (storage.Store, error) {
+	if s.Options.Redis != nil {
+		return storage.OpenExternalRedis(s.Options.Redis)
+	}
+	switch s.Options.DBType {
+	case "", "redis":
+		return storage.Open("redis", s.Options.RedisSock)
+	default:
+		return storage.Open(s.Options.DBType, s.Options.StorageDirectory)
+	}
+}
+
 func (s *Server) Run() error {
 	if s.store == nil {
 		panic("Server hasn't been booted")
@@ -191,6 +240,10 @@ func startConnection(conn net.Conn, s *Server) *Connection {
 
 	var salt string
 	conn.Write([]byte(`+HI {"v":2`))
+	if s.IsReplica() {
+		// clients with other addresses look for the primary
+		conn.Write([]byte(`,"replica":true`))
+	}
 	if s.Options.Password != "" {
 		conn.Write([]byte(`,"i":`))
 		iters := strconv.FormatInt(int64(iter), 10)
@@ -230,20 +283,24 @@ func startConnection(conn net.Conn, s *Server) *Connection {
 		return nil
 	}
 
+	tenant := s.root
 	if s.Options.Password != "" {
 		if client.Version < 2 {
 			iter = 1
 		}
 
-		if subtle.ConstantTimeCompare([]byte(client.PasswordHash), []byte(hash(s.Options.Password, salt, iter))) != 1 {
+		tenant = s.authenticate(client.PasswordHash, salt, iter)
+		if tenant == nil {
 			conn.Write([]byte("-ERR Invalid password\r\n"))
 			conn.Close()
 			return nil
 		}
 	}
+	client.tenant = tenant.Name
 
 	cn := &Connection{
 		client: client,
+		tenant: tenant,
 		conn:   conn,
 		buf:    buf,
 	}
@@ -300,6 +357,10 @@ func (s *Server) processLines(conn *Connection) {
 		proc, ok := cmdSet[verb]
 		if !ok {
 			conn.Error(cmd, fmt.Errorf("Unknown command %s", verb))
+		} else if s.IsReplica() && !replicaCommands[verb] {
+			conn.Error(cmd, s.errReplica())
+		} else if rootCommands[verb] && !conn.tenant.IsRoot() {
+			conn.Error(cmd, fmt.Errorf("%s requires the server's password", verb))
 		} else {
 			atomic.AddUint64(&s.Stats.Commands, 1)
 			proc(conn, s, cmd)
@@ -315,7 +376,13 @@ func (s *Server) uptimeInSeconds() int {
 }
 
 func (s *Server) CurrentState() (map[string]any, error) {
-	defalt, err := s.store.GetQueue("default")
+	return s.TenantState(s.root)
+}
+
+// TenantState is CurrentState scoped to the tenant's namespace.
+func (s *Server) TenantState(t *Tenant) (map[string]any, error) {
+	store := t.store
+	defalt, err := store.GetQueue("default")
 	if err != nil {
 		return nil, err
 	}
@@ -324,27 +391,48 @@ func (s *Server) CurrentState() (map[string]any, error) {
 	totalQueued := 0
 	totalQueues := 0
 	// each q.Size() call hits Redis (LLen), so this scales with queue count.
-	s.store.EachQueue(func(q storage.Queue) {
+	store.EachQueue(func(q storage.Queue) {
 		queues[q.Name()] = q.Size()
 		totalQueued += int(q.Size())
 		totalQueues++
 	})
 
+	faktory := map[string]any{
+		"default_size":    defalt.Size(),
+		"queues":          queues,
+		"total_failures":  store.TotalFailures(),
+		"total_canceled":  store.TotalCanceled(),
+		"total_processed": store.TotalProcessed(),
+		"total_enqueued":  totalQueued,
+		"total_queues":    totalQueues,
+	}
+	if t.IsRoot() {
+		// the tasks cover every tenant
+		faktory["tasks"] = s.taskRunner.Stats()
+	} else {
+		faktory["tenant"] = t.Name
+	}
+
+	memory, _ := s.RedisMemory()
 	return map[string]any{
 		"server_utc_time": time.Now().UTC().Format("03:04:05 UTC"),
-		"faktory": map[string]any{
-			"default_size":    defalt.Size(),
-			"queues":          queues,
-			"total_failures":  s.store.TotalFailures(),
-			"total_processed": s.store.TotalProcessed(),
-			"total_enqueued":  totalQueued,
-			"total_queues":    totalQueues,
-			"tasks":           s.taskRunner.Stats()},
+		"faktory":         faktory,
 		"server": map[string]any{
 			"faktory_version": client.Version,
 			"uptime":          s.uptimeInSeconds(),
 			"connections":     atomic.LoadUint64(&s.Stats.Connections),
 			"command_count":   atomic.LoadUint64(&s.Stats.Commands),
-			"used_memory_mb":  util.MemoryUsage()},
+			"used_memory_mb":  util.MemoryUsage(),
+			"health":          s.health(),
+			"memory":          memory,
+			"role":            s.role()},
 	}, nil
 }
+
+// health is "ok", or "unavailable" while Redis is down or restarting.
+func (s *Server) health() string {
+	if s.monitor != nil && !s.monitor.connected() {
+		return "unavailable"
+	}
+	return "ok"
+}
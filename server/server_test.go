@@ -17,22 +17,34 @@ import (
 )
 
 func runServer(binding string, runner func()) {
+	runServerWith(binding, nil, runner)
+}
+
+// runServerWith lets the test change the options before booting.
+func runServerWith(binding string, setup func(*ServerOptions), runner func()) {
 	dir := fmt.Sprintf("/tmp/%s", strings.Replace(binding, ":", "_", 1))
 	defer os.RemoveAll(dir)
 
 	sock := fmt.Sprintf("%s/test.sock", dir)
-	stopper, err := storage.BootRedis(dir, sock)
-	if err != nil {
-		panic(err)
+	dbtype := testDBType()
+	if dbtype == "redis" {
+		stopper, err := storage.BootRedis(dir, sock)
+		if err != nil {
+			panic(err)
+		}
+		defer stopper()
 	}
-	defer stopper()
 
 	opts := &ServerOptions{
 		Binding:          binding,
 		StorageDirectory: dir,
+		DBType:           dbtype,
 		RedisSock:        sock,
 		ConfigDirectory:  os.ExpandEnv("$HOME/.faktory"),
 	}
+	if setup != nil {
+		setup(opts)
+	}
 	s, err := NewServer(opts)
 	if err != nil {
 		panic(err)
@@ -52,6 +64,14 @@ func runServer(binding string, runner func()) {
 	s.Stop(nil)
 }
 
+// FAKTORY_TEST_DB=memory runs the tests without redis-server
+func testDBType() string {
+	if os.Getenv("FAKTORY_TEST_DB") == "memory" {
+		return "memory"
+	}
+	return "redis"
+}
+
 func TestServerStart(t *testing.T) {
 	runServer("localhost:7420", func() {
 		conn, err := net.DialTimeout("tcp", "localhost:7420", 1*time.Second)
@@ -114,6 +134,48 @@ func TestServerStart(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "+OK\r\n", result)
 
+		// the job had no retries so it's gone after the FAIL
+		conn.Write([]byte(fmt.Sprintf("TRACK %s\n", hash["jid"])))
+		result, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "$-1\r\n", result)
+
+		// a queue may be named with only digits, counts are explicit
+		conn.Write([]byte("PUSH {\"jid\":\"numericqueuejob1\",\"jobtype\":\"Thing\",\"args\":[1],\"queue\":\"123\"}\n"))
+		result, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "+OK\r\n", result)
+		conn.Write([]byte("PUSH {\"jid\":\"defaultqueuejob1\",\"jobtype\":\"Thing\",\"args\":[2]}\n"))
+		result, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "+OK\r\n", result)
+
+		conn.Write([]byte("FETCH 123 default\n"))
+		_, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		result, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Regexp(t, `^\{"jid":"numericqueuejob1"`, result)
+
+		conn.Write([]byte("FETCH count=2 default\n"))
+		_, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		result, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Regexp(t, `^\[\{"jid":"defaultqueuejob1"`, result)
+
+		conn.Write([]byte("FETCH count=x default\n"))
+		result, err = buf.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "-ERR Invalid fetch count x\r\n", result)
+
+		for _, jid := range []string{"numericqueuejob1", "defaultqueuejob1"} {
+			conn.Write([]byte(fmt.Sprintf("ACK {\"jid\":\"%s\"}\n", jid)))
+			result, err = buf.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, "+OK\r\n", result)
+		}
+
 		conn.Write([]byte(fmt.Sprintf("INFO\n")))
 		_, err = buf.ReadString('\n')
 		assert.NoError(t, err)
@@ -124,6 +186,7 @@ func TestServerStart(t *testing.T) {
 		err = json.Unmarshal([]byte(result), &stats)
 		assert.NoError(t, err)
 		assert.Equal(t, 3, len(stats))
+		assert.Equal(t, "ok", stats["server"].(map[string]any)["health"])
 
 		conn.Write([]byte("END\n"))
 		//result, err = buf.ReadString('\n')
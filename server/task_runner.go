@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/hunter-io/faktory/storage"
 	"github.com/hunter-io/faktory/util"
 )
 
@@ -113,16 +114,42 @@ func (ts *taskRunner) cycle() {
 
 func (s *Server) startTasks() {
 	ts := newTaskRunner()
-	// scan the various sets, looking for things to do
-	ts.AddTask(5, &scanner{name: "Scheduled", set: s.store.Scheduled(), task: s.manager.EnqueueScheduledJobs})
-	ts.AddTask(5, &scanner{name: "Retries", set: s.store.Retries(), task: s.manager.RetryJobs})
-	ts.AddTask(60, &scanner{name: "Dead", set: s.store.Dead(), task: s.manager.Purge})
+	// a replica can't change the data until it's promoted
+	if s.IsReplica() {
+		ts.AddTask(1, newPrimaryMonitor(s))
+	} else {
+		s.addJobTasks(ts)
+	}
 
-	// reaps job reservations which have expired
-	ts.AddTask(15, &reservationReaper{s.manager, 0})
 	// reaps workers who have not heartbeated
 	ts.AddTask(15, &beatReaper{s.workers, 0})
+	// logs when the connection to redis drops and recovers
+	if rs, ok := s.store.(storage.Redis); ok {
+		s.monitor = &storeMonitor{store: rs}
+		ts.AddTask(1, s.monitor)
+		// rejects pushes before redis runs out of memory
+		s.memory = newMemoryMonitor(s, rs)
+		ts.AddTask(1, s.memory)
+	}
 
 	ts.Run(s.Stopper())
 	s.taskRunner = ts
 }
+
+func (s *Server) addJobTasks(ts *taskRunner) {
+	for _, t := range s.everyTenant() {
+		// a tenant's tasks are named "acme/Scheduled", etc
+		prefix := ""
+		if !t.IsRoot() {
+			prefix = t.Name + "/"
+		}
+
+		// scan the various sets, looking for things to do
+		ts.AddTask(5, &scanner{name: prefix + "Scheduled", set: t.store.Scheduled(), task: t.manager.EnqueueScheduledJobs})
+		ts.AddTask(5, &scanner{name: prefix + "Retries", set: t.store.Retries(), task: t.manager.RetryJobs})
+		ts.AddTask(60, &scanner{name: prefix + "Dead", set: t.store.Dead(), task: t.manager.Purge})
+
+		// reaps job reservations which have expired
+		ts.AddTask(15, &reservationReaper{prefix + "Busy", t.manager, 0})
+	}
+}
@@ -5,16 +5,18 @@ import (
 	"time"
 
 	"github.com/hunter-io/faktory/manager"
+	"github.com/hunter-io/faktory/storage"
 	"github.com/hunter-io/faktory/util"
 )
 
 type reservationReaper struct {
+	name  string
 	m     manager.Manager
 	count int64
 }
 
 func (r *reservationReaper) Name() string {
-	return "Busy"
+	return r.name
 }
 
 func (r *reservationReaper) Execute() error {
@@ -58,3 +60,43 @@ func (r *beatReaper) Stats() map[string]any {
 		"reaped": atomic.LoadInt64(&r.count),
 	}
 }
+
+/*
+ * Watches the connection to Redis so an outage is logged once when it
+ * starts and once when it ends, rather than by every failing command.
+ */
+type storeMonitor struct {
+	store  storage.Redis
+	down   int32
+	losses int64
+}
+
+func (r *storeMonitor) Name() string {
+	return "Storage"
+}
+
+func (r *storeMonitor) Execute() error {
+	err := r.store.Redis().Ping().Err()
+	if err != nil {
+		if atomic.CompareAndSwapInt32(&r.down, 0, 1) {
+			atomic.AddInt64(&r.losses, 1)
+			util.Warnf("Lost connection to redis: %v", err)
+		}
+		return nil
+	}
+	if atomic.CompareAndSwapInt32(&r.down, 1, 0) {
+		util.Info("Reconnected to redis")
+	}
+	return nil
+}
+
+func (r *storeMonitor) connected() bool {
+	return atomic.LoadInt32(&r.down) == 0
+}
+
+func (r *storeMonitor) Stats() map[string]any {
+	return map[string]any{
+		"connected": r.connected(),
+		"losses":    atomic.LoadInt64(&r.losses),
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hunter-io/faktory/manager"
+	"github.com/hunter-io/faktory/storage"
+)
+
+/*
+ * Tenants let several apps share one server without seeing each
+ * other's data.  Each has its own password, which its clients and Web
+ * UI users log in with, and its own namespace in Redis holding its
+ * queues, sorted sets, counters and KV entries:
+ *
+ *   [tenants.acme]
+ *   password = "..."
+ *
+ * Connections using the server's password are in the root namespace,
+ * which doesn't see the tenants' data either.  Tenants require Redis
+ * and the server's password, and only change on restart.
+ */
+type Tenant struct {
+	// empty for the root namespace
+	Name     string
+	password string
+	store    storage.Store
+	manager  manager.Manager
+}
+
+// The commands which act on the whole server, tenants can't run them.
+var rootCommands = map[string]bool{
+	"BACKUP":  true,
+	"PROMOTE": true,
+}
+
+func (t *Tenant) Store() storage.Store {
+	return t.store
+}
+
+func (t *Tenant) Manager() manager.Manager {
+	return t.manager
+}
+
+// IsRoot is true for the server's own namespace, which administers
+// the tenants.
+func (t *Tenant) IsRoot() bool {
+	return t.Name == ""
+}
+
+// Authenticate checks the Web UI password of a tenant.
+func (t *Tenant) Authenticate(pwd string) bool {
+	return subtle.ConstantTimeCompare([]byte(pwd), []byte(t.password)) == 1
+}
+
+// Tenant returns the named tenant or nil, "" is the root namespace.
+func (s *Server) Tenant(name string) *Tenant {
+	if name == "" {
+		return s.root
+	}
+	for _, t := range s.tenants {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Tenants lists the tenants by name, without the root namespace.
+func (s *Server) Tenants() []*Tenant {
+	return s.tenants
+}
+
+// everyTenant includes the root namespace first.
+func (s *Server) everyTenant() []*Tenant {
+	return append([]*Tenant{s.root}, s.tenants...)
+}
+
+func (s *Server) openTenants(store storage.Store) ([]*Tenant, error) {
+	if len(s.Options.Tenants) == 0 {
+		return nil, nil
+	}
+	if s.Options.Password == "" {
+		return nil, errors.New("tenants require a server password")
+	}
+	ns, ok := store.(storage.Namespaced)
+	if !ok {
+		return nil, errors.New("tenants require redis")
+	}
+
+	tenants := []*Tenant{}
+	for name, pwd := range s.Options.Tenants {
+		if pwd == "" || pwd == s.Options.Password {
+			return nil, fmt.Errorf("tenant %s: a password of its own is required", name)
+		}
+		for _, t := range tenants {
+			if t.password == pwd {
+				return nil, fmt.Errorf("tenants %s and %s have the same password", t.Name, name)
+			}
+		}
+		tstore, err := ns.Namespace(name)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+		m := manager.NewManager(tstore)
+		err = s.applyConfig(m)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, &Tenant{Name: name, password: pwd, store: tstore, manager: m})
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].Name < tenants[j].Name
+	})
+	return tenants, nil
+}
+
+// authenticate finds the tenant whose password the client hashed,
+// nil if none match.
+func (s *Server) authenticate(pwdhash, salt string, iterations int) *Tenant {
+	var found *Tenant
+	for _, t := range s.everyTenant() {
+		// compare them all so the time taken doesn't tell which matched
+		if subtle.ConstantTimeCompare([]byte(pwdhash), []byte(hash(t.password, salt, iterations))) == 1 {
+			found = t
+		}
+	}
+	return found
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenants(t *testing.T) {
+	if testDBType() != "redis" {
+		t.Skip("tenants require redis")
+	}
+
+	tenants := func(opts *ServerOptions) {
+		opts.Password = "root-pwd"
+		opts.Tenants = map[string]string{"acme": "acme-pwd", "globex": "globex-pwd"}
+	}
+	runServerWith("localhost:7404", tenants, func() {
+		srv := client.DefaultServer()
+		srv.Address = "localhost:7404"
+		root, err := client.Dial(srv, "root-pwd")
+		assert.NoError(t, err)
+		defer root.Close()
+		acme, err := client.Dial(srv, "acme-pwd")
+		assert.NoError(t, err)
+		defer acme.Close()
+		globex, err := client.Dial(srv, "globex-pwd")
+		assert.NoError(t, err)
+		defer globex.Close()
+		_, err = client.Dial(srv, "nope")
+		assert.Error(t, err)
+
+		assert.NoError(t, acme.Push(client.NewJob("Invoice", 1)))
+		assert.NoError(t, acme.Push(client.NewJob("Invoice", 2)))
+		assert.NoError(t, globex.Push(client.NewJob("Report", 1)))
+
+		job, err := globex.Fetch("default")
+		assert.NoError(t, err)
+		assert.Equal(t, "Report", job.Type)
+		job, err = globex.Fetch("default")
+		assert.NoError(t, err)
+		assert.Nil(t, job)
+		job, err = root.Fetch("default")
+		assert.NoError(t, err)
+		assert.Nil(t, job)
+
+		info, err := acme.Info()
+		assert.NoError(t, err)
+		faktory := info["faktory"].(map[string]interface{})
+		assert.Equal(t, "acme", faktory["tenant"])
+		assert.EqualValues(t, 2, faktory["total_enqueued"])
+		assert.Nil(t, faktory["tasks"])
+
+		// a tenant can only flush its own data
+		assert.NoError(t, globex.Flush())
+		info, err = acme.Info()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, info["faktory"].(map[string]interface{})["total_enqueued"])
+
+		_, err = acme.Backup()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "requires the server's password")
+	})
+}
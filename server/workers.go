@@ -2,10 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"sync"
 	"time"
 
+	"github.com/hunter-io/faktory/manager"
 	"github.com/hunter-io/faktory/util"
 )
 
@@ -53,13 +55,16 @@ type ClientData struct {
 	Labels       []string `json:"labels"`
 	PasswordHash string   `json:"pwdhash"`
 	Version      uint8    `json:"v"`
-	StartedAt    time.Time
+	// how to order the queues for each FETCH, see manager.OrderQueues
+	FetchMode string `json:"fetch_mode"`
+	StartedAt time.Time
 
 	// this only applies to clients that are workers and
 	// are sending BEAT
 	lastHeartbeat time.Time
 	state         WorkerState
 	connections   map[io.Closer]bool
+	tenant        string
 }
 
 type WorkerState int
@@ -87,6 +92,9 @@ func clientDataFromHello(data string) (*ClientData, error) {
 	if err != nil {
 		return nil, err
 	}
+	if client.FetchMode != "" && !manager.ValidFetchMode(client.FetchMode) {
+		return nil, fmt.Errorf("Invalid fetch mode %s", client.FetchMode)
+	}
 
 	return &client, nil
 }
@@ -117,6 +125,12 @@ func (worker *ClientData) Signal(newstate WorkerState) {
 	}
 }
 
+// Tenant is the name of the tenant the worker logged in to, empty for
+// the root namespace.
+func (worker *ClientData) Tenant() string {
+	return worker.tenant
+}
+
 func (worker *ClientData) IsConsumer() bool {
 	return worker.Wid != ""
 }
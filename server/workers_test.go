@@ -24,6 +24,14 @@ func TestClientData(t *testing.T) {
 	assert.NotNil(t, cw)
 	assert.False(t, cw.IsConsumer())
 
+	cw, err = clientDataFromHello(`{"fetch_mode":"roundrobin"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "roundrobin", cw.FetchMode)
+
+	cw, err = clientDataFromHello(`{"fetch_mode":"random"}`)
+	assert.Error(t, err)
+	assert.Nil(t, cw)
+
 	ahoy := `{"hostname":"MikeBookPro.local","wid":"78629a0f5f3f164f","pid":40275,"labels":["blue","seven"],"salt":"123456","pwdhash":"958d51602bbfbd18b2a084ba848a827c29952bfef170c936419b0922994c0589"}`
 	cw, err = clientDataFromHello(ahoy)
 	assert.NoError(t, err)
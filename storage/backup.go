@@ -0,0 +1,542 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * Backups are gzipped JSON Lines archives, one line per key, named
+ * backup-<id>-<keys>.jsonl.gz.  They are taken online so they are not
+ * a point-in-time snapshot: jobs which move while the backup runs may
+ * appear twice or not at all.
+ */
+
+var (
+	ErrNotEmpty      = errors.New("store is not empty, restore requires an empty store")
+	ErrNoSuchBackup  = errors.New("no such backup")
+	backupNameFormat = "backup-%d-%d.jsonl.gz"
+)
+
+// A single key within a backup archive.
+type backupRecord struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	// Remaining time to live in seconds, 0 if the key doesn't expire
+	TTL    int64             `json:"ttl,omitempty"`
+	Value  []byte            `json:"value,omitempty"`
+	Values [][]byte          `json:"values,omitempty"`
+	Scores []float64         `json:"scores,omitempty"`
+	Fields map[string][]byte `json:"fields,omitempty"`
+}
+
+// Backup writes every key to w, returning the number written.
+func (store *redisStore) Backup(w io.Writer) (int, error) {
+	if store.namespace != "" {
+		return 0, ErrNamespaced
+	}
+	enc := json.NewEncoder(w)
+	count := 0
+	var cursor uint64
+	for {
+		keys, next, err := store.rclient.Scan(cursor, "", 1000).Result()
+		if err != nil {
+			return count, err
+		}
+		for _, key := range keys {
+			rec, err := store.backupKey(key)
+			if err != nil {
+				return count, fmt.Errorf("backup %s: %w", key, err)
+			}
+			if rec == nil {
+				// removed since the scan
+				continue
+			}
+			err = enc.Encode(rec)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		cursor = next
+		if cursor == 0 {
+			return count, nil
+		}
+	}
+}
+
+func (store *redisStore) backupKey(key string) (*backupRecord, error) {
+	rc := store.rclient
+	typ, err := rc.Type(key).Result()
+	if err != nil {
+		return nil, err
+	}
+	rec := &backupRecord{Key: key, Type: typ}
+
+	switch typ {
+	case "none":
+		return nil, nil
+	case "string":
+		val, err := rc.Get(key).Bytes()
+		if err == redis.Nil {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec.Value = val
+	case "list":
+		vals, err := rc.LRange(key, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range vals {
+			rec.Values = append(rec.Values, []byte(val))
+		}
+	case "zset":
+		entries, err := rc.ZRangeWithScores(key, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			rec.Values = append(rec.Values, []byte(entry.Member.(string)))
+			rec.Scores = append(rec.Scores, entry.Score)
+		}
+	case "set":
+		vals, err := rc.SMembers(key).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range vals {
+			rec.Values = append(rec.Values, []byte(val))
+		}
+	case "hash":
+		fields, err := rc.HGetAll(key).Result()
+		if err != nil {
+			return nil, err
+		}
+		rec.Fields = make(map[string][]byte, len(fields))
+		for name, val := range fields {
+			rec.Fields[name] = []byte(val)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+
+	ttl, err := rc.TTL(key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		rec.TTL = int64(ttl.Seconds())
+		if rec.TTL == 0 {
+			rec.TTL = 1
+		}
+	}
+	return rec, nil
+}
+
+// Restore loads a backup written by Backup into an empty store,
+// returning the number of keys restored.
+func (store *redisStore) Restore(r io.Reader) (int, error) {
+	if store.namespace != "" {
+		return 0, ErrNamespaced
+	}
+	rc := store.rclient
+	size, err := rc.DBSize().Result()
+	if err != nil {
+		return 0, err
+	}
+	if size > 0 {
+		return 0, ErrNotEmpty
+	}
+
+	dec := json.NewDecoder(r)
+	count := 0
+	for {
+		var rec backupRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("restore: invalid record %d: %w", count+1, err)
+		}
+
+		switch rec.Type {
+		case "string":
+			err = rc.Set(rec.Key, rec.Value, 0).Err()
+		case "list":
+			err = rc.RPush(rec.Key, toArgs(rec.Values)...).Err()
+		case "zset":
+			if len(rec.Scores) != len(rec.Values) {
+				return count, fmt.Errorf("restore %s: %d scores for %d values", rec.Key, len(rec.Scores), len(rec.Values))
+			}
+			entries := make([]redis.Z, len(rec.Values))
+			for idx := range rec.Values {
+				entries[idx] = redis.Z{Score: rec.Scores[idx], Member: rec.Values[idx]}
+			}
+			err = rc.ZAdd(rec.Key, entries...).Err()
+		case "set":
+			err = rc.SAdd(rec.Key, toArgs(rec.Values)...).Err()
+		case "hash":
+			fields := make(map[string]interface{}, len(rec.Fields))
+			for name, val := range rec.Fields {
+				fields[name] = val
+			}
+			err = rc.HMSet(rec.Key, fields).Err()
+		default:
+			err = fmt.Errorf("unsupported type %s", rec.Type)
+		}
+		if err == nil && rec.TTL > 0 {
+			err = rc.Expire(rec.Key, time.Duration(rec.TTL)*time.Second).Err()
+		}
+		if err != nil {
+			return count, fmt.Errorf("restore %s: %w", rec.Key, err)
+		}
+		count++
+	}
+}
+
+func toArgs(vals [][]byte) []interface{} {
+	args := make([]interface{}, len(vals))
+	for idx, val := range vals {
+		args[idx] = val
+	}
+	return args
+}
+
+func (bi BackupInfo) Time() time.Time {
+	return time.Unix(bi.Timestamp, 0)
+}
+
+// CreateBackup archives the store into dir.
+func CreateBackup(store Store, dir string) (*BackupInfo, error) {
+	err := os.MkdirAll(dir, os.ModeDir|0755)
+	if err != nil {
+		return nil, fmt.Errorf("backup: create directory %q: %w", dir, err)
+	}
+
+	start := time.Now()
+	id := start.UnixNano() / int64(time.Millisecond)
+	tmp, err := os.CreateTemp(dir, "backup-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	buf := bufio.NewWriter(tmp)
+	gz := gzip.NewWriter(buf)
+	count, err := store.Backup(gz)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf(backupNameFormat, id, count))
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+
+	info, err := backupInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	util.Infof("Backed up %d keys to %s in %v", count, path, time.Since(start))
+	return info, nil
+}
+
+func backupInfo(path string) (*BackupInfo, error) {
+	var id int64
+	var count int32
+	_, err := fmt.Sscanf(filepath.Base(path), backupNameFormat, &id, &count)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup name %s", path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupInfo{
+		Id:        id,
+		FileCount: count,
+		Size:      fi.Size(),
+		Timestamp: id / 1000,
+	}, nil
+}
+
+// Backups lists the archives in dir, newest first.
+func Backups(dir string) ([]BackupInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "backup-*.jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]BackupInfo, 0, len(paths))
+	for _, path := range paths {
+		info, err := backupInfo(path)
+		if err != nil {
+			util.Warnf("Skipping %v", err)
+			continue
+		}
+		infos = append(infos, *info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Id > infos[j].Id
+	})
+	return infos, nil
+}
+
+// PurgeOldBackups removes all but the newest keep archives.
+func PurgeOldBackups(dir string, keep int) (int, error) {
+	infos, err := Backups(dir)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for idx := keep; idx < len(infos); idx++ {
+		err := os.Remove(backupPath(dir, infos[idx]))
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// RestoreBackup loads the archive with the given id, or the newest
+// if id is 0, into an empty store.
+func RestoreBackup(store Store, dir string, id int64) (*BackupInfo, error) {
+	infos, err := Backups(dir)
+	if err != nil {
+		return nil, err
+	}
+	var info *BackupInfo
+	for idx := range infos {
+		if id == 0 || infos[idx].Id == id {
+			info = &infos[idx]
+			break
+		}
+	}
+	if info == nil {
+		return nil, ErrNoSuchBackup
+	}
+
+	path := backupPath(dir, *info)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("restore %s: %w", path, err)
+	}
+	defer gz.Close()
+
+	count, err := store.Restore(gz)
+	if err != nil {
+		return nil, err
+	}
+	util.Infof("Restored %d keys from %s", count, path)
+	return info, nil
+}
+
+func backupPath(dir string, info BackupInfo) string {
+	return filepath.Join(dir, fmt.Sprintf(backupNameFormat, info.Id, info.FileCount))
+}
+
+// ParseBackupId accepts an id as listed by Backups, "latest" is 0.
+func ParseBackupId(val string) (int64, error) {
+	if val == "" || strings.EqualFold(val, "latest") {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid backup id: %s", val)
+	}
+	return id, nil
+}
+
+// copyOps takes the ops which rebuild the embedded store's data so it
+// can be written out without holding the lock.
+func (store *embeddedStore) copyOps() []*journalOp {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	ops := []*journalOp{}
+	store.snapshot(func(op *journalOp) error {
+		op.Vals = append([][]byte(nil), op.Vals...)
+		ops = append(ops, op)
+		return nil
+	})
+	return ops
+}
+
+// remaining converts an expiry into a TTL in whole seconds.
+func remaining(expires int64) int64 {
+	if expires == 0 {
+		return 0
+	}
+	secs := (expires - nowMillis() + 999) / 1000
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// Backup writes the same archive as the Redis store so backups may be
+// restored into either.
+func (store *embeddedStore) Backup(w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	count := 0
+	for _, op := range store.copyOps() {
+		rec := &backupRecord{Key: op.Key}
+		switch op.Op {
+		case opPush:
+			rec.Type = "list"
+			for idx := len(op.Vals) - 1; idx >= 0; idx-- {
+				rec.Values = append(rec.Values, op.Vals[idx])
+			}
+		case opZAdd:
+			rec.Type = "zset"
+			rec.Values = op.Vals
+			rec.Scores = op.Scores
+		case opSet:
+			rec.Type = "string"
+			rec.Value = op.Vals[0]
+			rec.TTL = remaining(op.Expires)
+		case opSAdd:
+			rec.Type = "set"
+			rec.Values = op.Vals
+			rec.TTL = remaining(op.Expires)
+		}
+		err := enc.Encode(rec)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (store *embeddedStore) empty() bool {
+	for _, q := range store.queues {
+		if len(q.items) > 0 {
+			return false
+		}
+	}
+	for _, ss := range store.sorted {
+		if len(ss.entries) > 0 {
+			return false
+		}
+	}
+	return len(store.kv) == 0
+}
+
+func (store *embeddedStore) Restore(r io.Reader) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if !store.empty() {
+		return 0, ErrNotEmpty
+	}
+
+	// check the whole archive first, a bad record must not leave half
+	// of it in the journal
+	ops := []*journalOp{}
+	dec := json.NewDecoder(r)
+	for {
+		var rec backupRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("restore: invalid record %d: %w", len(ops)+1, err)
+		}
+		op, err := rec.journalOp()
+		if err != nil {
+			return 0, fmt.Errorf("restore %s: %w", rec.Key, err)
+		}
+		ops = append(ops, op)
+	}
+
+	for count, op := range ops {
+		_, err := store.write(op)
+		if err != nil {
+			return count, fmt.Errorf("restore %s: %w", op.Key, err)
+		}
+	}
+	return len(ops), nil
+}
+
+// journalOp converts a record of a backup into the op which restores
+// it into the embedded store, if the embedded store can hold it.
+func (rec *backupRecord) journalOp() (*journalOp, error) {
+	op := &journalOp{Key: rec.Key}
+	switch rec.Type {
+	case "string":
+		if rec.Value == nil {
+			return nil, ErrNilValue
+		}
+		op.Op = opSet
+		op.Vals = [][]byte{rec.Value}
+		if rec.TTL > 0 {
+			op.Expires = nowMillis() + rec.TTL*1000
+		}
+	case "list":
+		if !ValidQueueName.MatchString(rec.Key) || isSortedSet(rec.Key) {
+			return nil, fmt.Errorf("invalid queue name %q", rec.Key)
+		}
+		op.Op = opPush
+		for idx := len(rec.Values) - 1; idx >= 0; idx-- {
+			op.Vals = append(op.Vals, rec.Values[idx])
+		}
+	case "zset":
+		if !isSortedSet(rec.Key) {
+			return nil, fmt.Errorf("unknown sorted set %q", rec.Key)
+		}
+		if len(rec.Scores) != len(rec.Values) {
+			return nil, fmt.Errorf("%d scores for %d values", len(rec.Scores), len(rec.Values))
+		}
+		op.Op = opZAdd
+		op.Vals = rec.Values
+		op.Scores = rec.Scores
+	case "set":
+		for _, val := range rec.Values {
+			if val == nil {
+				return nil, ErrNilValue
+			}
+		}
+		op.Op = opSAdd
+		op.Vals = rec.Values
+		if rec.TTL > 0 {
+			op.Expires = nowMillis() + rec.TTL*1000
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", rec.Type)
+	}
+	return op, nil
+}
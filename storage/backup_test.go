@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup(t *testing.T) {
+	withStores(t, "backup", func(t *testing.T, store Store) {
+		store.Flush()
+		dir := t.TempDir()
+
+		q, err := store.GetQueue("default")
+		assert.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, q.Add(client.NewJob("Invoice", i)))
+		}
+		job := client.NewJob("Report", 1)
+		job.At = util.Thens(time.Now().Add(time.Hour))
+		assert.NoError(t, store.Scheduled().Add(job))
+		assert.NoError(t, store.Success())
+		assert.NoError(t, store.Raw().SetEx("result", []byte("{}"), time.Hour))
+		assert.NoError(t, store.Raw().SAdd("deps", []byte("a"), time.Hour))
+
+		var before []string
+		assert.NoError(t, q.Each(func(_ int, data []byte) error {
+			before = append(before, string(data))
+			return nil
+		}))
+
+		info, err := CreateBackup(store, dir)
+		assert.NoError(t, err)
+		assert.True(t, info.FileCount >= 4, "%d keys", info.FileCount)
+		assert.True(t, info.Size > 0)
+
+		// restore needs an empty store
+		_, err = RestoreBackup(store, dir, 0)
+		assert.Equal(t, ErrNotEmpty, err)
+
+		assert.NoError(t, store.Flush())
+		restored, err := RestoreBackup(store, dir, info.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, info.Id, restored.Id)
+
+		var after []string
+		assert.NoError(t, q.Each(func(_ int, data []byte) error {
+			after = append(after, string(data))
+			return nil
+		}))
+		assert.Equal(t, before, after)
+		assert.EqualValues(t, 1, store.Scheduled().Size())
+		assert.EqualValues(t, 1, store.TotalProcessed())
+		val, err := store.Raw().Get("result")
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", string(val))
+		members, err := store.Raw().SMembers("deps")
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("a")}, members)
+
+		_, err = RestoreBackup(store, dir, 12345)
+		assert.Equal(t, ErrNoSuchBackup, err)
+
+		// retention keeps the newest
+		for i := 0; i < 3; i++ {
+			time.Sleep(2 * time.Millisecond)
+			_, err = CreateBackup(store, dir)
+			assert.NoError(t, err)
+		}
+		infos, err := Backups(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(infos))
+		count, err := PurgeOldBackups(dir, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		kept, err := Backups(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, infos[:2], kept)
+	})
+}
+
+func TestParseBackupId(t *testing.T) {
+	for _, val := range []string{"", "latest"} {
+		id, err := ParseBackupId(val)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, id)
+	}
+	id, err := ParseBackupId("1530000000000")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1530000000000, id)
+	for _, val := range []string{"x", "-1", "0"} {
+		_, err := ParseBackupId(val)
+		assert.Error(t, err, fmt.Sprintf("%q", val))
+	}
+}
@@ -0,0 +1,879 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * The embedded store is a pure Go Store which needs no redis-server.
+ * All data lives in memory; every change is appended to a journal in
+ * the storage directory which is periodically compacted into a
+ * snapshot.  Opening the store loads the snapshot and replays the
+ * journal.  Select it with:
+ *
+ *   [faktory]
+ *   dbtype = "embedded"
+ *
+ * The journal is written on every change and synced to disk every
+ * second, so a machine crash may lose the last second of changes.
+ */
+type embeddedStore struct {
+	Name string
+	mu   sync.Mutex
+
+	queues map[string]*embeddedQueue
+	sorted map[string]*embeddedSorted
+	kv     map[string]*kvEntry
+
+	// closed and replaced whenever a job is pushed, waking BPop
+	pushed chan struct{}
+
+	// persistence, unused when dir is empty
+	dir     string
+	lock    *os.File
+	gen     int64
+	journal *os.File
+	jbuf    *bufio.Writer
+	jsize   int64
+	dirty   bool
+	stop    chan struct{}
+	stopped sync.WaitGroup
+	closed  bool
+}
+
+type kvEntry struct {
+	value []byte
+	// the members of a set, nil for a plain value
+	members [][]byte
+	// unix milliseconds, 0 never expires
+	expires int64
+}
+
+func (e *kvEntry) expired(now int64) bool {
+	return e.expires > 0 && e.expires <= now
+}
+
+func (e *kvEntry) isMember(val []byte) bool {
+	for _, member := range e.members {
+		if bytes.Equal(member, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// A journal line.  The snapshot is a header line with Gen followed by
+// the ops which rebuild the data.
+type journalOp struct {
+	Op      string    `json:"op"`
+	Key     string    `json:"key,omitempty"`
+	Vals    [][]byte  `json:"vals,omitempty"`
+	Scores  []float64 `json:"scores,omitempty"`
+	Expires int64     `json:"exp,omitempty"`
+	Gen     int64     `json:"gen,omitempty"`
+}
+
+const (
+	// append Vals to queue Key
+	opPush = "push"
+	// remove the oldest job in queue Key
+	opPop = "pop"
+	// remove the newest occurrence of each of Vals from queue Key
+	opLRem = "lrem"
+	// add Vals with Scores to sorted set Key
+	opZAdd = "zadd"
+	// remove Vals from sorted set Key
+	opZRem = "zrem"
+	// set Key to Vals[0], expiring at Expires
+	opSet = "set"
+	// add Vals to the set Key, which expires at Expires
+	opSAdd = "sadd"
+	// empty queue Key
+	opQDel = "qdel"
+	// empty sorted set Key
+	opZDel = "zdel"
+	// delete the value named Key
+	opDel = "del"
+	// remove everything
+	opFlush = "flush"
+)
+
+var (
+	// Compact the journal once it grows past this size
+	JournalCompactSize int64 = 64 * 1024 * 1024
+	ErrClosed                = errors.New("store is closed")
+)
+
+const snapshotName = "snapshot.jsonl"
+
+func newEmbeddedStore(name string) *embeddedStore {
+	store := &embeddedStore{
+		Name:   name,
+		queues: map[string]*embeddedQueue{},
+		sorted: map[string]*embeddedSorted{},
+		kv:     map[string]*kvEntry{},
+		pushed: make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	for _, name := range []string{"scheduled", "retries", "dead", "working", "waiting"} {
+		store.sorted[name] = &embeddedSorted{name: name, store: store, scores: map[string]float64{}}
+	}
+	return store
+}
+
+func OpenEmbedded(dir string) (Store, error) {
+	err := os.MkdirAll(dir, os.ModeDir|0755)
+	if err != nil {
+		return nil, fmt.Errorf("open embedded: create directory %q: %w", dir, err)
+	}
+	util.Infof("Initializing embedded storage at %s", dir)
+
+	store := newEmbeddedStore(dir)
+	store.dir = dir
+	store.lock, err = os.OpenFile(filepath.Join(dir, "LOCK"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(store.lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		store.lock.Close()
+		return nil, fmt.Errorf("open embedded: %s is in use by another process", dir)
+	}
+
+	err = store.load()
+	if err == nil {
+		// start from a fresh snapshot so the journal only holds new changes
+		store.mu.Lock()
+		err = store.compact()
+		store.mu.Unlock()
+	}
+	if err != nil {
+		store.lock.Close()
+		return nil, err
+	}
+
+	store.stopped.Add(1)
+	go store.syncLoop()
+	return store, nil
+}
+
+func (store *embeddedStore) load() error {
+	start := time.Now()
+	ops := 0
+	file, err := os.Open(filepath.Join(store.dir, snapshotName))
+	if err == nil {
+		dec := json.NewDecoder(bufio.NewReader(file))
+		var header journalOp
+		err = dec.Decode(&header)
+		if err == nil {
+			store.gen = header.Gen
+			ops, err = store.replay(dec, true)
+		}
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("open embedded: corrupt snapshot: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	file, err = os.Open(store.journalPath(store.gen))
+	if err == nil {
+		count, err := store.replay(json.NewDecoder(bufio.NewReader(file)), false)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		ops += count
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	util.Debugf("Loaded %d operations in %v", ops, time.Since(start))
+	return nil
+}
+
+// replay applies each op, a torn last line in the journal is expected
+// after a crash.
+func (store *embeddedStore) replay(dec *json.Decoder, strict bool) (int, error) {
+	count := 0
+	for {
+		var op journalOp
+		err := dec.Decode(&op)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			if strict {
+				return count, err
+			}
+			util.Warnf("Ignoring the end of the journal after %d operations: %v", count, err)
+			return count, nil
+		}
+		_, err = store.apply(&op)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func (store *embeddedStore) journalPath(gen int64) string {
+	return filepath.Join(store.dir, fmt.Sprintf("journal-%d.jsonl", gen))
+}
+
+// compact writes a snapshot of the data and starts a new, empty
+// journal.  The snapshot names the journal which follows it so a
+// crash part way through never replays a journal twice.
+func (store *embeddedStore) compact() error {
+	if store.dir == "" {
+		return nil
+	}
+	gen := store.gen + 1
+	tmp := filepath.Join(store.dir, snapshotName+".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(file)
+	enc := json.NewEncoder(buf)
+	err = enc.Encode(&journalOp{Op: "snapshot", Gen: gen})
+	if err == nil {
+		err = store.snapshot(func(op *journalOp) error {
+			return enc.Encode(op)
+		})
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(store.dir, snapshotName))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compact: %w", err)
+	}
+
+	if store.journal != nil {
+		store.journal.Close()
+	}
+	old, _ := filepath.Glob(filepath.Join(store.dir, "journal-*.jsonl"))
+	for _, path := range old {
+		os.Remove(path)
+	}
+	store.gen = gen
+	store.journal, err = os.OpenFile(store.journalPath(gen), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	store.jbuf = bufio.NewWriter(store.journal)
+	store.jsize = 0
+	store.dirty = false
+	return nil
+}
+
+// snapshot calls fn with the ops which rebuild the current data.
+func (store *embeddedStore) snapshot(fn func(*journalOp) error) error {
+	names := make([]string, 0, len(store.queues))
+	for name := range store.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		q := store.queues[name]
+		if len(q.items) == 0 {
+			continue
+		}
+		err := fn(&journalOp{Op: opPush, Key: name, Vals: q.items})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range store.sortedNames() {
+		ss := store.sorted[name]
+		if len(ss.entries) == 0 {
+			continue
+		}
+		op := &journalOp{Op: opZAdd, Key: name}
+		for _, e := range ss.entries {
+			op.Vals = append(op.Vals, e.member)
+			op.Scores = append(op.Scores, e.score)
+		}
+		err := fn(op)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := nowMillis()
+	for _, key := range store.kvKeys() {
+		entry := store.kv[key]
+		if entry.expired(now) {
+			continue
+		}
+		op := &journalOp{Op: opSet, Key: key, Vals: [][]byte{entry.value}, Expires: entry.expires}
+		if entry.members != nil {
+			op = &journalOp{Op: opSAdd, Key: key, Vals: entry.members, Expires: entry.expires}
+		}
+		err := fn(op)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *embeddedStore) sortedNames() []string {
+	names := make([]string, 0, len(store.sorted))
+	for name := range store.sorted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (store *embeddedStore) kvKeys() []string {
+	keys := make([]string, 0, len(store.kv))
+	for key := range store.kv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// syncLoop syncs the journal and drops expired values every second.
+func (store *embeddedStore) syncLoop() {
+	defer store.stopped.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-store.stop:
+			return
+		case <-ticker.C:
+			store.mu.Lock()
+			err := store.sync()
+			if err != nil {
+				util.Warnf("Unable to sync journal: %v", err)
+			}
+			now := nowMillis()
+			for key, entry := range store.kv {
+				if entry.expired(now) {
+					delete(store.kv, key)
+				}
+			}
+			store.mu.Unlock()
+		}
+	}
+}
+
+func (store *embeddedStore) sync() error {
+	if !store.dirty || store.journal == nil {
+		return nil
+	}
+	store.dirty = false
+	return store.journal.Sync()
+}
+
+// write applies the op and then journals it, so an op which fails
+// can't break every later replay.  The caller must hold the lock.
+func (store *embeddedStore) write(op *journalOp) ([]byte, error) {
+	if store.closed {
+		return nil, ErrClosed
+	}
+	val, err := store.apply(op)
+	if err != nil {
+		return nil, err
+	}
+
+	if store.jbuf != nil {
+		data, err := json.Marshal(op)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, '\n')
+		_, err = store.jbuf.Write(data)
+		if err == nil {
+			err = store.jbuf.Flush()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("journal: %w", err)
+		}
+		store.jsize += int64(len(data))
+		store.dirty = true
+	}
+
+	if store.jsize > JournalCompactSize {
+		err = store.compact()
+		if err != nil {
+			util.Warnf("Unable to compact journal: %v", err)
+		}
+	}
+	return val, nil
+}
+
+// apply changes the data in memory, returning the value of a pop.
+func (store *embeddedStore) apply(op *journalOp) ([]byte, error) {
+	switch op.Op {
+	case opPush:
+		q := store.queue(op.Key)
+		q.items = append(q.items, op.Vals...)
+		close(store.pushed)
+		store.pushed = make(chan struct{})
+	case opPop:
+		q := store.queue(op.Key)
+		if len(q.items) == 0 {
+			return nil, nil
+		}
+		val := q.items[0]
+		q.items[0] = nil
+		q.items = q.items[1:]
+		return val, nil
+	case opLRem:
+		q := store.queue(op.Key)
+		for _, val := range op.Vals {
+			q.remove(val)
+		}
+	case opZAdd:
+		ss, err := store.sortedSet(op.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(op.Scores) != len(op.Vals) {
+			return nil, fmt.Errorf("%s: %d scores for %d values", op.Key, len(op.Scores), len(op.Vals))
+		}
+		for idx, val := range op.Vals {
+			ss.add(op.Scores[idx], val)
+		}
+	case opZRem:
+		ss, err := store.sortedSet(op.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range op.Vals {
+			ss.remove(val)
+		}
+	case opSet:
+		if len(op.Vals) != 1 {
+			return nil, fmt.Errorf("%s: expected one value", op.Key)
+		}
+		store.kv[op.Key] = &kvEntry{value: op.Vals[0], expires: op.Expires}
+	case opSAdd:
+		entry, ok := store.kv[op.Key]
+		if !ok || entry.expired(nowMillis()) {
+			entry = &kvEntry{members: [][]byte{}}
+			store.kv[op.Key] = entry
+		} else if entry.members == nil {
+			return nil, fmt.Errorf("%s: not a set", op.Key)
+		}
+		for _, val := range op.Vals {
+			if !entry.isMember(val) {
+				entry.members = append(entry.members, val)
+			}
+		}
+		entry.expires = op.Expires
+	case opQDel:
+		if q, ok := store.queues[op.Key]; ok {
+			q.items = nil
+		}
+	case opZDel:
+		ss, err := store.sortedSet(op.Key)
+		if err != nil {
+			return nil, err
+		}
+		ss.clear()
+	case opDel:
+		delete(store.kv, op.Key)
+	case opFlush:
+		for _, q := range store.queues {
+			q.items = nil
+		}
+		for _, ss := range store.sorted {
+			ss.clear()
+		}
+		store.kv = map[string]*kvEntry{}
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+	return nil, nil
+}
+
+func (store *embeddedStore) queue(name string) *embeddedQueue {
+	q, ok := store.queues[name]
+	if !ok {
+		q = &embeddedQueue{name: name, store: store}
+		store.queues[name] = q
+	}
+	return q
+}
+
+func (store *embeddedStore) sortedSet(name string) (*embeddedSorted, error) {
+	ss, ok := store.sorted[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sorted set %q", name)
+	}
+	return ss, nil
+}
+
+func (store *embeddedStore) Close() error {
+	util.Debug("Stopping storage")
+	store.mu.Lock()
+	if store.closed {
+		store.mu.Unlock()
+		return nil
+	}
+	store.closed = true
+	close(store.stop)
+	// wake any blocked fetches
+	close(store.pushed)
+	store.pushed = make(chan struct{})
+
+	var err error
+	if store.journal != nil {
+		err = store.jbuf.Flush()
+		if err == nil {
+			err = store.journal.Sync()
+		}
+		store.journal.Close()
+	}
+	if store.lock != nil {
+		store.lock.Close()
+	}
+	store.mu.Unlock()
+
+	store.stopped.Wait()
+	return err
+}
+
+func (store *embeddedStore) Stats() map[string]string {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	jobs := 0
+	for _, q := range store.queues {
+		jobs += len(q.items)
+	}
+	sorted := 0
+	for _, ss := range store.sorted {
+		sorted += len(ss.entries)
+	}
+	return map[string]string{
+		"stats": fmt.Sprintf("queues: %d\nenqueued: %d\nsorted: %d\nkeys: %d\njournal_bytes: %d\n",
+			len(store.queues), jobs, sorted, len(store.kv), store.jsize),
+		"name": store.Name,
+	}
+}
+
+func (store *embeddedStore) Flush() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	_, err := store.write(&journalOp{Op: opFlush})
+	return err
+}
+
+func (store *embeddedStore) GetQueue(name string) (Queue, error) {
+	if name == "" {
+		return nil, fmt.Errorf("queue name cannot be blank")
+	}
+	if !ValidQueueName.MatchString(name) {
+		return nil, fmt.Errorf("queue names must match %v", ValidQueueName)
+	}
+	if isSortedSet(name) {
+		return nil, fmt.Errorf("queue name %q is reserved", name)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.queue(name), nil
+}
+
+// queues are iterated in sorted, lexigraphical order
+func (store *embeddedStore) EachQueue(x func(Queue)) {
+	store.mu.Lock()
+	names := make([]string, 0, len(store.queues))
+	for name := range store.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	queues := make([]*embeddedQueue, len(names))
+	for idx, name := range names {
+		queues[idx] = store.queues[name]
+	}
+	store.mu.Unlock()
+
+	for _, q := range queues {
+		x(q)
+	}
+}
+
+func (store *embeddedStore) Retries() SortedSet {
+	return store.sorted["retries"]
+}
+
+func (store *embeddedStore) Scheduled() SortedSet {
+	return store.sorted["scheduled"]
+}
+
+func (store *embeddedStore) Working() SortedSet {
+	return store.sorted["working"]
+}
+
+func (store *embeddedStore) Dead() SortedSet {
+	return store.sorted["dead"]
+}
+
+func (store *embeddedStore) Waiting() SortedSet {
+	return store.sorted["waiting"]
+}
+
+func (store *embeddedStore) EnqueueAll(sset SortedSet) error {
+	return enqueueAll(store, sset)
+}
+
+func (store *embeddedStore) EnqueueFrom(sset SortedSet, key []byte) error {
+	return enqueueFrom(store, sset, key)
+}
+
+func (store *embeddedStore) BPop(ctx context.Context, queues ...string) ([]byte, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("BPop must be called with one or more queue names")
+	}
+
+	timeout := time.NewTimer(DefaultBlockTimeout)
+	defer timeout.Stop()
+	for {
+		store.mu.Lock()
+		if store.closed {
+			store.mu.Unlock()
+			return nil, ErrClosed
+		}
+		for _, name := range queues {
+			q, ok := store.queues[name]
+			if !ok || len(q.items) == 0 {
+				continue
+			}
+			val, err := store.write(&journalOp{Op: opPop, Key: name})
+			store.mu.Unlock()
+			return val, err
+		}
+		pushed := store.pushed
+		store.mu.Unlock()
+
+		select {
+		case <-pushed:
+		case <-timeout.C:
+			return nil, nil
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, nil
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// History counters are decimal strings, like Redis.
+
+func (store *embeddedStore) incr(keys ...string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, key := range keys {
+		op := &journalOp{Op: opSet, Key: key}
+		count := int64(0)
+		if entry, ok := store.kv[key]; ok {
+			count, _ = strconv.ParseInt(string(entry.value), 10, 64)
+			op.Expires = entry.expires
+		}
+		op.Vals = [][]byte{[]byte(strconv.FormatInt(count+1, 10))}
+		_, err := store.write(op)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *embeddedStore) counter(key string) uint64 {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	entry, ok := store.kv[key]
+	if !ok || entry.expired(nowMillis()) {
+		return 0
+	}
+	count, _ := strconv.ParseUint(string(entry.value), 10, 64)
+	return count
+}
+
+func (store *embeddedStore) Success() error {
+	daystr := time.Now().Format("2006-01-02")
+	return store.incr(fmt.Sprintf("processed:%s", daystr), "processed")
+}
+
+func (store *embeddedStore) Failure() error {
+	daystr := time.Now().Format("2006-01-02")
+	return store.incr("processed", "failures", fmt.Sprintf("processed:%s", daystr), fmt.Sprintf("failures:%s", daystr))
+}
+
+func (store *embeddedStore) Canceled() error {
+	return store.incr("canceled")
+}
+
+func (store *embeddedStore) TotalProcessed() uint64 {
+	return store.counter("processed")
+}
+
+func (store *embeddedStore) TotalFailures() uint64 {
+	return store.counter("failures")
+}
+
+func (store *embeddedStore) TotalCanceled() uint64 {
+	return store.counter("canceled")
+}
+
+func (store *embeddedStore) History(days int, fn func(day string, procCnt uint64, failCnt uint64)) error {
+	ts := time.Now()
+	for idx := 0; idx < days; idx++ {
+		daystr := ts.Format("2006-01-02")
+		fn(daystr, store.counter("processed:"+daystr), store.counter("failures:"+daystr))
+		ts = ts.Add(-24 * time.Hour)
+	}
+	return nil
+}
+
+type embeddedKV struct {
+	store *embeddedStore
+}
+
+func (store *embeddedStore) Raw() KV {
+	return &embeddedKV{store}
+}
+
+func (kv *embeddedKV) Get(key string) ([]byte, error) {
+	kv.store.mu.Lock()
+	defer kv.store.mu.Unlock()
+	entry, ok := kv.store.kv[key]
+	if !ok || entry.expired(nowMillis()) {
+		return nil, nil
+	}
+	if entry.members != nil {
+		return nil, fmt.Errorf("%s: not a value", key)
+	}
+	return entry.value, nil
+}
+
+func (kv *embeddedKV) Set(key string, value []byte) error {
+	return kv.SetEx(key, value, 0)
+}
+
+func (kv *embeddedKV) SetEx(key string, value []byte, ttl time.Duration) error {
+	if value == nil {
+		return ErrNilValue
+	}
+	op := &journalOp{Op: opSet, Key: key, Vals: [][]byte{value}}
+	if ttl > 0 {
+		op.Expires = nowMillis() + int64(ttl/time.Millisecond)
+	}
+	kv.store.mu.Lock()
+	defer kv.store.mu.Unlock()
+	_, err := kv.store.write(op)
+	return err
+}
+
+func (kv *embeddedKV) Delete(key string) error {
+	kv.store.mu.Lock()
+	defer kv.store.mu.Unlock()
+	if _, ok := kv.store.kv[key]; !ok {
+		return nil
+	}
+	_, err := kv.store.write(&journalOp{Op: opDel, Key: key})
+	return err
+}
+
+func (kv *embeddedKV) DeleteAll(keys []string) error {
+	kv.store.mu.Lock()
+	defer kv.store.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := kv.store.kv[key]; !ok {
+			continue
+		}
+		_, err := kv.store.write(&journalOp{Op: opDel, Key: key})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (kv *embeddedKV) SetAll(values map[string][]byte, ttl time.Duration) error {
+	for _, value := range values {
+		if value == nil {
+			return ErrNilValue
+		}
+	}
+	var expires int64
+	if ttl > 0 {
+		expires = nowMillis() + int64(ttl/time.Millisecond)
+	}
+	kv.store.mu.Lock()
+	defer kv.store.mu.Unlock()
+	for key, value := range values {
+		_, err := kv.store.write(&journalOp{Op: opSet, Key: key, Vals: [][]byte{value}, Expires: expires})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (kv *embeddedKV) SAdd(key string, member []byte, ttl time.Duration) error {
+	if member == nil {
+		return ErrNilValue
+	}
+	op := &journalOp{Op: opSAdd, Key: key, Vals: [][]byte{member}}
+	if ttl > 0 {
+		op.Expires = nowMillis() + int64(ttl/time.Millisecond)
+	}
+	kv.store.mu.Lock()
+	defer kv.store.mu.Unlock()
+	_, err := kv.store.write(op)
+	return err
+}
+
+func (kv *embeddedKV) SMembers(key string) ([][]byte, error) {
+	kv.store.mu.Lock()
+	defer kv.store.mu.Unlock()
+	entry, ok := kv.store.kv[key]
+	if !ok || entry.expired(nowMillis()) {
+		return nil, nil
+	}
+	if entry.members == nil {
+		return nil, fmt.Errorf("%s: not a set", key)
+	}
+	return append([][]byte(nil), entry.members...), nil
+}
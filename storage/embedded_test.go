@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedPersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenEmbedded(dir)
+	assert.NoError(t, err)
+
+	// only one process may use the directory
+	_, err = OpenEmbedded(dir)
+	assert.Error(t, err)
+
+	q, err := store.GetQueue("default")
+	assert.NoError(t, err)
+	assert.NoError(t, q.PushAll([][]byte{[]byte("one"), []byte("two"), []byte("three")}))
+	data, err := q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "one", string(data))
+
+	job := client.NewJob("Report", 1)
+	job.At = util.Thens(time.Now().Add(time.Hour))
+	assert.NoError(t, store.Scheduled().Add(job))
+	assert.NoError(t, store.Success())
+	assert.NoError(t, store.Raw().Set("kept", []byte("yes")))
+	assert.NoError(t, store.Raw().SetEx("expired", []byte("no"), time.Millisecond))
+	assert.NoError(t, store.Close())
+
+	// a crash can tear the last line of the journal
+	journals, err := filepath.Glob(filepath.Join(dir, "journal-*.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(journals))
+	file, err := os.OpenFile(journals[0], os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"op":"push","key":"default","va`)
+	assert.NoError(t, err)
+	file.Close()
+
+	time.Sleep(5 * time.Millisecond)
+	store, err = OpenEmbedded(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	q, err = store.GetQueue("default")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, q.Size())
+	data, err = q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "two", string(data))
+	assert.EqualValues(t, 1, store.Scheduled().Size())
+	assert.EqualValues(t, 1, store.TotalProcessed())
+	val, err := store.Raw().Get("kept")
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", string(val))
+	val, err = store.Raw().Get("expired")
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+
+	// opening compacted the old journal into the snapshot
+	journals, err = filepath.Glob(filepath.Join(dir, "journal-*.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(journals))
+	assert.Equal(t, filepath.Join(dir, "journal-2.jsonl"), journals[0])
+}
+
+func TestEmbeddedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	defer func(size int64) { JournalCompactSize = size }(JournalCompactSize)
+	JournalCompactSize = 1024
+
+	store, err := OpenEmbedded(dir)
+	assert.NoError(t, err)
+	q, err := store.GetQueue("default")
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, data := fakeJob()
+		assert.NoError(t, q.Push(5, data))
+	}
+	for i := 0; i < 50; i++ {
+		_, err := q.Pop()
+		assert.NoError(t, err)
+	}
+	fi, err := os.Stat(store.(*embeddedStore).journalPath(store.(*embeddedStore).gen))
+	assert.NoError(t, err)
+	assert.True(t, fi.Size() <= 1024+512, "journal is %d bytes", fi.Size())
+	assert.NoError(t, store.Close())
+
+	store, err = OpenEmbedded(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	q, err = store.GetQueue("default")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 50, q.Size())
+}
+
+func TestEmbeddedKeyspaces(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenEmbedded(dir)
+	assert.NoError(t, err)
+
+	// a value may share its name with a sorted set or a queue
+	job := client.NewJob("Report", 1)
+	job.At = util.Thens(time.Now().Add(time.Hour))
+	assert.NoError(t, store.Retries().Add(job))
+	assert.NoError(t, store.Raw().Set("retries", []byte("kept")))
+	assert.NoError(t, store.Raw().Set("reports", []byte("kept")))
+	q, err := store.GetQueue("reports")
+	assert.NoError(t, err)
+	assert.NoError(t, q.Push(5, []byte("one")))
+
+	_, err = q.Clear()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, q.Size())
+	assert.EqualValues(t, 1, store.Retries().Size())
+
+	assert.NoError(t, q.Push(5, []byte("two")))
+	assert.NoError(t, store.Raw().Delete("retries"))
+	assert.NoError(t, store.Raw().Delete("reports"))
+	assert.NoError(t, store.Raw().SAdd("deps", []byte("x"), 0))
+	assert.EqualValues(t, 1, q.Size())
+	assert.EqualValues(t, 1, store.Retries().Size())
+	assert.NoError(t, store.Close())
+
+	// and the journal replays the same way
+	store, err = OpenEmbedded(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	q, err = store.GetQueue("reports")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, q.Size())
+	assert.EqualValues(t, 1, store.Retries().Size())
+	val, err := store.Raw().Get("retries")
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+	members, err := store.Raw().SMembers("deps")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("x")}, members)
+}
+
+func TestEmbeddedRestoreInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenEmbedded(dir)
+	assert.NoError(t, err)
+
+	// a Redis archive may hold a tenant's sets, which the embedded
+	// store has no place for
+	archive := `{"key":"default","type":"list","values":["b25l"]}
+{"key":"tenant:acme:retries","type":"zset","values":["b25l"],"scores":[1]}
+`
+	count, err := store.Restore(strings.NewReader(archive))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tenant:acme:retries")
+	assert.Equal(t, 0, count)
+	q, err := store.GetQueue("default")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, q.Size())
+
+	_, err = store.Restore(strings.NewReader(`{"key":"retries","type":"zset","values":["b25l"],"scores":[]}`))
+	assert.Error(t, err)
+
+	// nor does an op which fails to apply
+	es := store.(*embeddedStore)
+	es.mu.Lock()
+	_, err = es.write(&journalOp{Op: opZAdd, Key: "tenant:acme:retries", Vals: [][]byte{[]byte("one")}, Scores: []float64{1}})
+	es.mu.Unlock()
+	assert.Error(t, err)
+	assert.NoError(t, store.Close())
+
+	// nothing of it reached the journal
+	store, err = OpenEmbedded(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	q, err = store.GetQueue("default")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, q.Size())
+}
@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+/*
+ * An export is a portable copy of the dataset as JSON Lines, one
+ * Record per line, which doesn't depend on the backend or its version:
+ *
+ *   {"type":"queue","name":"default","job":{...}}
+ *   {"type":"sorted","name":"scheduled","score":1530000000.5,"job":{...}}
+ *   {"type":"counter","name":"processed","count":1234}
+ *   {"type":"kv","name":"result:abc","data":"e30=","ttl":1800}
+ *   {"type":"set","name":"deps:abc","data":"MjAy...","ttl":15552000}
+ *   {"type":"queue","tenant":"acme","name":"default","job":{...}}
+ *
+ * Queues are exported in the order their jobs will be fetched.  Jobs
+ * are exported verbatim and sorted set scores exactly, so importing
+ * preserves enqueued_at, scheduled times and when dead jobs expire.
+ */
+type Record struct {
+	Type string `json:"type"`
+	// The namespace holding the record, empty for the root
+	Tenant string          `json:"tenant,omitempty"`
+	Name   string          `json:"name"`
+	Job    json.RawMessage `json:"job,omitempty"`
+	Score  float64         `json:"score,omitempty"`
+	Count  int64           `json:"count,omitempty"`
+	Data   []byte          `json:"data,omitempty"`
+	// Remaining time to live in seconds, 0 if the entry doesn't expire
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+const (
+	QueueRecord   = "queue"
+	SortedRecord  = "sorted"
+	CounterRecord = "counter"
+	KVRecord      = "kv"
+	// one member of a set in the KV
+	SetRecord = "set"
+)
+
+// ImportSummary counts the records imported, or which would be in
+// a dry run.
+type ImportSummary struct {
+	Queues   map[string]int
+	Sorted   map[string]int
+	Counters int
+	KV       int
+	Sets     int
+}
+
+func (is *ImportSummary) String() string {
+	lines := []string{}
+	for kind, counts := range map[string]map[string]int{QueueRecord: is.Queues, SortedRecord: is.Sorted} {
+		for name, count := range counts {
+			lines = append(lines, fmt.Sprintf("%s %s: %d jobs", kind, name, count))
+		}
+	}
+	sort.Strings(lines)
+	lines = append(lines, fmt.Sprintf("counters: %d", is.Counters))
+	lines = append(lines, fmt.Sprintf("kv: %d", is.KV))
+	lines = append(lines, fmt.Sprintf("set members: %d", is.Sets))
+	return strings.Join(lines, "\n")
+}
+
+var errFound = errors.New("found")
+
+// Export writes every record in the store to w, returning the count.
+func Export(store Store, w io.Writer) (int, error) {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	count := 0
+	err := store.Export(func(rec *Record) error {
+		count++
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, buf.Flush()
+}
+
+// Import loads an export into an empty store.  A dry run validates
+// every record and reports what would be written without writing.
+func Import(store Store, r io.Reader, dryRun bool) (*ImportSummary, error) {
+	if !dryRun {
+		err := store.Export(func(*Record) error { return errFound })
+		if err == errFound {
+			return nil, ErrNotEmpty
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	summary := &ImportSummary{Queues: map[string]int{}, Sorted: map[string]int{}}
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for line := 1; ; line++ {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return summary, nil
+		}
+		if err == nil {
+			err = rec.validate()
+		}
+		if err == nil && !dryRun {
+			err = store.Import(&rec)
+		}
+		if err != nil {
+			return summary, fmt.Errorf("import: record %d: %w", line, err)
+		}
+
+		switch rec.Type {
+		case QueueRecord:
+			summary.Queues[rec.Name]++
+		case SortedRecord:
+			summary.Sorted[rec.Name]++
+		case CounterRecord:
+			summary.Counters++
+		case KVRecord:
+			summary.KV++
+		case SetRecord:
+			summary.Sets++
+		}
+	}
+}
+
+func (rec *Record) validate() error {
+	if rec.Name == "" {
+		return errors.New("missing name")
+	}
+	if rec.Tenant != "" && !ValidNamespace.MatchString(rec.Tenant) {
+		return fmt.Errorf("invalid tenant %q", rec.Tenant)
+	}
+	switch rec.Type {
+	case QueueRecord:
+		if !ValidQueueName.MatchString(rec.Name) || isSortedSet(rec.Name) {
+			return fmt.Errorf("invalid queue name %q", rec.Name)
+		}
+		if len(rec.Job) == 0 {
+			return fmt.Errorf("queue %s: missing job", rec.Name)
+		}
+	case SortedRecord:
+		if !isSortedSet(rec.Name) {
+			return fmt.Errorf("unknown sorted set %q", rec.Name)
+		}
+		if len(rec.Job) == 0 {
+			return fmt.Errorf("%s: missing job", rec.Name)
+		}
+	case CounterRecord:
+		if !isCounter(rec.Name) {
+			return fmt.Errorf("unknown counter %q", rec.Name)
+		}
+	case KVRecord:
+		if rec.Data == nil {
+			return fmt.Errorf("kv %s: %w", rec.Name, ErrNilValue)
+		}
+	case SetRecord:
+		if rec.Data == nil {
+			return fmt.Errorf("set %s: %w", rec.Name, ErrNilValue)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", rec.Type)
+	}
+	return nil
+}
+
+func isSortedSet(name string) bool {
+	switch name {
+	case "scheduled", "retries", "dead", "working", "waiting":
+		return true
+	}
+	return false
+}
+
+// the counters kept by Success, Failure and Canceled
+func isCounter(name string) bool {
+	switch name {
+	case "processed", "failures", "canceled":
+		return true
+	}
+	return strings.HasPrefix(name, "processed:") || strings.HasPrefix(name, "failures:")
+}
+
+// Export from the root namespace includes every tenant's records.
+func (store *redisStore) Export(fn func(*Record) error) error {
+	return store.eachKey(true, func(keys []string) error {
+		for _, key := range keys {
+			tenant, name := "", strings.TrimPrefix(key, store.namespace)
+			if store.namespace == "" {
+				tenant, name = splitTenant(key)
+			}
+			err := store.exportKey(key, func(rec *Record) error {
+				rec.Tenant = tenant
+				rec.Name = name
+				return fn(rec)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (store *redisStore) exportKey(key string, fn func(*Record) error) error {
+	bkey, err := store.backupKey(key)
+	if err != nil {
+		return fmt.Errorf("export %s: %w", key, err)
+	}
+	if bkey == nil {
+		// removed since the scan
+		return nil
+	}
+
+	switch bkey.Type {
+	case "list":
+		// LPUSH and RPOP, so the oldest job is last
+		for idx := len(bkey.Values) - 1; idx >= 0; idx-- {
+			err = fn(&Record{Type: QueueRecord, Name: key, Job: bkey.Values[idx]})
+			if err != nil {
+				return err
+			}
+		}
+	case "zset":
+		for idx := range bkey.Values {
+			err = fn(&Record{Type: SortedRecord, Name: key, Score: bkey.Scores[idx], Job: bkey.Values[idx]})
+			if err != nil {
+				return err
+			}
+		}
+	case "string":
+		if isCounter(key) {
+			var count int64
+			_, err = fmt.Sscan(string(bkey.Value), &count)
+			if err != nil {
+				return fmt.Errorf("export %s: invalid counter: %w", key, err)
+			}
+			return fn(&Record{Type: CounterRecord, Name: key, Count: count, TTL: bkey.TTL})
+		}
+		return fn(&Record{Type: KVRecord, Name: key, Data: bkey.Value, TTL: bkey.TTL})
+	case "set":
+		for _, val := range bkey.Values {
+			err = fn(&Record{Type: SetRecord, Name: key, Data: val, TTL: bkey.TTL})
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("export %s: unsupported type %s", key, bkey.Type)
+	}
+	return nil
+}
+
+func (store *redisStore) Import(rec *Record) error {
+	if rec.Tenant != "" {
+		ns, err := store.tenant(rec.Tenant)
+		if err != nil {
+			return err
+		}
+		tenantRec := *rec
+		tenantRec.Tenant = ""
+		return ns.Import(&tenantRec)
+	}
+
+	rc := store.rclient
+	ttl := time.Duration(rec.TTL) * time.Second
+	switch rec.Type {
+	case QueueRecord:
+		q, err := store.GetQueue(rec.Name)
+		if err != nil {
+			return err
+		}
+		return q.Push(0, rec.Job)
+	case SortedRecord:
+		return rc.ZAdd(store.key(rec.Name), redis.Z{Score: rec.Score, Member: []byte(rec.Job)}).Err()
+	case CounterRecord:
+		return rc.Set(store.key(rec.Name), rec.Count, ttl).Err()
+	case KVRecord:
+		return rc.Set(store.key(rec.Name), rec.Data, ttl).Err()
+	case SetRecord:
+		return store.Raw().SAdd(rec.Name, rec.Data, ttl)
+	}
+	return fmt.Errorf("unknown type %q", rec.Type)
+}
+
+func (store *embeddedStore) Export(fn func(*Record) error) error {
+	for _, op := range store.copyOps() {
+		switch op.Op {
+		case opPush:
+			for _, val := range op.Vals {
+				err := fn(&Record{Type: QueueRecord, Name: op.Key, Job: val})
+				if err != nil {
+					return err
+				}
+			}
+		case opZAdd:
+			for idx, val := range op.Vals {
+				err := fn(&Record{Type: SortedRecord, Name: op.Key, Score: op.Scores[idx], Job: val})
+				if err != nil {
+					return err
+				}
+			}
+		case opSet:
+			rec := &Record{Type: KVRecord, Name: op.Key, Data: op.Vals[0], TTL: remaining(op.Expires)}
+			if isCounter(op.Key) {
+				_, err := fmt.Sscan(string(op.Vals[0]), &rec.Count)
+				if err != nil {
+					return fmt.Errorf("export %s: invalid counter: %w", op.Key, err)
+				}
+				rec.Type = CounterRecord
+				rec.Data = nil
+			}
+			err := fn(rec)
+			if err != nil {
+				return err
+			}
+		case opSAdd:
+			for _, val := range op.Vals {
+				err := fn(&Record{Type: SetRecord, Name: op.Key, Data: val, TTL: remaining(op.Expires)})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (store *embeddedStore) Import(rec *Record) error {
+	if rec.Tenant != "" {
+		return fmt.Errorf("tenant %s: tenants require redis", rec.Tenant)
+	}
+	op := &journalOp{Key: rec.Name}
+	if rec.TTL > 0 {
+		op.Expires = nowMillis() + rec.TTL*1000
+	}
+	switch rec.Type {
+	case QueueRecord:
+		op.Op = opPush
+		op.Vals = [][]byte{rec.Job}
+	case SortedRecord:
+		op.Op = opZAdd
+		op.Vals = [][]byte{rec.Job}
+		op.Scores = []float64{rec.Score}
+	case CounterRecord:
+		op.Op = opSet
+		op.Vals = [][]byte{[]byte(strconv.FormatInt(rec.Count, 10))}
+	case KVRecord:
+		op.Op = opSet
+		op.Vals = [][]byte{rec.Data}
+	case SetRecord:
+		op.Op = opSAdd
+		op.Vals = [][]byte{rec.Data}
+	default:
+		return fmt.Errorf("unknown type %q", rec.Type)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	_, err := store.write(op)
+	return err
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	withStores(t, "export", func(t *testing.T, store Store) {
+		store.Flush()
+
+		q, err := store.GetQueue("default")
+		assert.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, q.Add(client.NewJob("Invoice", i)))
+		}
+		job := client.NewJob("Report", 1)
+		job.At = util.Thens(time.Now().Add(time.Hour))
+		assert.NoError(t, store.Scheduled().Add(job))
+		job = client.NewJob("Report", 2)
+		job.At = util.Thens(time.Now().Add(-time.Hour))
+		assert.NoError(t, store.Dead().Add(job))
+		assert.NoError(t, store.Success())
+		assert.NoError(t, store.Failure())
+		assert.NoError(t, store.Raw().SetEx("result", []byte("{}"), time.Hour))
+		assert.NoError(t, store.Raw().SAdd("deps", []byte("a"), time.Hour))
+		assert.NoError(t, store.Raw().SAdd("deps", []byte("b"), time.Hour))
+
+		snapshot := func() map[string][]string {
+			data := map[string][]string{}
+			assert.NoError(t, q.Each(func(_ int, payload []byte) error {
+				data["default"] = append(data["default"], string(payload))
+				return nil
+			}))
+			for _, set := range []SortedSet{store.Scheduled(), store.Dead()} {
+				assert.NoError(t, set.Each(func(_ int, entry SortedEntry) error {
+					key, err := entry.Key()
+					data[set.Name()] = append(data[set.Name()], string(key), string(entry.Value()))
+					return err
+				}))
+			}
+			return data
+		}
+		before := snapshot()
+
+		var buf bytes.Buffer
+		count, err := Export(store, &buf)
+		assert.NoError(t, err)
+		// 3 queued, 2 sorted, 4 counters, the result and 2 set members
+		assert.Equal(t, 12, count)
+		assert.Equal(t, 12, strings.Count(buf.String(), "\n"))
+		export := buf.String()
+
+		_, err = Import(store, strings.NewReader(export), false)
+		assert.Equal(t, ErrNotEmpty, err)
+
+		assert.NoError(t, store.Flush())
+		summary, err := Import(store, strings.NewReader(export), true)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, summary.Queues["default"])
+		assert.Equal(t, 1, summary.Sorted["scheduled"])
+		assert.Equal(t, 1, summary.Sorted["dead"])
+		assert.Equal(t, 4, summary.Counters)
+		assert.Equal(t, 1, summary.KV)
+		assert.Equal(t, 2, summary.Sets)
+		assert.EqualValues(t, 0, q.Size())
+
+		_, err = Import(store, strings.NewReader(export), false)
+		assert.NoError(t, err)
+		assert.Equal(t, before, snapshot())
+		assert.EqualValues(t, 2, store.TotalProcessed())
+		assert.EqualValues(t, 1, store.TotalFailures())
+		members, err := store.Raw().SMembers("deps")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, [][]byte{[]byte("a"), []byte("b")}, members)
+		err = store.Export(func(rec *Record) error {
+			if rec.Name == "result" {
+				assert.True(t, rec.TTL > 3500, "%d", rec.TTL)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.Flush())
+		for _, line := range []string{
+			`{"type":"queue","name":"bad queue","job":{}}`,
+			`{"type":"queue","name":"waiting","job":{}}`,
+			`{"type":"set","name":"nope"}`,
+			`{"type":"sorted","name":"nope","job":{}}`,
+			`{"type":"counter","name":"nope","count":1}`,
+			`{"type":"kv","name":"nope"}`,
+			`{"type":"other","name":"nope"}`,
+			`{"type":`,
+		} {
+			_, err = Import(store, strings.NewReader(line), true)
+			assert.Error(t, err, line)
+		}
+	})
+}
@@ -5,30 +5,55 @@ import (
 	"time"
 
 	"github.com/go-redis/redis"
+	"github.com/hunter-io/faktory/util"
 )
 
 func (store *redisStore) Success() error {
 	daystr := time.Now().Format("2006-01-02")
-	store.rclient.Incr(fmt.Sprintf("processed:%s", daystr))
-	store.rclient.Incr("processed")
-	return nil
+	return store.incr("processed", fmt.Sprintf("processed:%s", daystr))
+}
+
+func (store *redisStore) Failure() error {
+	daystr := time.Now().Format("2006-01-02")
+	return store.incr("processed", "failures", fmt.Sprintf("processed:%s", daystr), fmt.Sprintf("failures:%s", daystr))
 }
 
+func (store *redisStore) Canceled() error {
+	return store.incr("canceled")
+}
+
+// incr bumps the counters together so they can't drift apart if
+// Redis goes away midway.
+func (store *redisStore) incr(keys ...string) error {
+	_, err := store.rclient.TxPipelined(func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Incr(store.key(key))
+		}
+		return nil
+	})
+	return err
+}
+
+// The totals are 0 while Redis is unavailable.
 func (store *redisStore) TotalProcessed() uint64 {
-	return uint64(store.rclient.IncrBy("processed", 0).Val())
+	return store.total("processed")
 }
+
 func (store *redisStore) TotalFailures() uint64 {
-	return uint64(store.rclient.IncrBy("failures", 0).Val())
+	return store.total("failures")
 }
 
-func (store *redisStore) Failure() error {
-	store.rclient.Incr("processed")
-	store.rclient.Incr("failures")
+func (store *redisStore) TotalCanceled() uint64 {
+	return store.total("canceled")
+}
 
-	daystr := time.Now().Format("2006-01-02")
-	store.rclient.Incr(fmt.Sprintf("processed:%s", daystr))
-	store.rclient.Incr(fmt.Sprintf("failures:%s", daystr))
-	return nil
+func (store *redisStore) total(key string) uint64 {
+	val, err := store.rclient.IncrBy(store.key(key), 0).Result()
+	if err != nil {
+		util.Debugf("Unable to read %s: %v", key, err)
+		return 0
+	}
+	return uint64(val)
 }
 
 func (store *redisStore) History(days int, fn func(day string, procCnt uint64, failCnt uint64)) error {
@@ -41,8 +66,8 @@ func (store *redisStore) History(days int, fn func(day string, procCnt uint64, f
 		for idx := 0; idx < days; idx++ {
 			daystr := ts.Format("2006-01-02")
 			daystrs[idx] = daystr
-			procds[idx] = pipe.IncrBy(fmt.Sprintf("processed:%s", daystr), 0)
-			fails[idx] = pipe.IncrBy(fmt.Sprintf("failures:%s", daystr), 0)
+			procds[idx] = pipe.IncrBy(store.key("processed:"+daystr), 0)
+			fails[idx] = pipe.IncrBy(store.key("failures:"+daystr), 0)
 			ts = ts.Add(-24 * time.Hour)
 		}
 		return nil
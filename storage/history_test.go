@@ -8,7 +8,7 @@ import (
 )
 
 func TestStats(t *testing.T) {
-	withRedis(t, "history", func(t *testing.T, store Store) {
+	withStores(t, "history", func(t *testing.T, store Store) {
 		store.Flush()
 		for i := 0; i < 10000; i++ {
 			if i%100 == 99 {
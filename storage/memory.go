@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * The memory store is the embedded store without a journal: nothing
+ * is written to disk and everything is lost when the process exits.
+ * It's meant for tests and throwaway development servers:
+ *
+ *   faktory -d memory
+ *
+ * or
+ *
+ *   [faktory]
+ *   dbtype = "memory"
+ */
+func OpenMemory(name string) (Store, error) {
+	util.Infof("Initializing in-memory storage, data will not persist")
+
+	store := newEmbeddedStore(name)
+	// expire values as the embedded store does
+	store.stopped.Add(1)
+	go store.syncLoop()
+	return store, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+ * A namespace keeps one tenant's data apart from the others sharing a
+ * Redis: its queues, sorted sets, counters and KV entries are all
+ * prefixed with "tenant:<name>:".  Queue names can't contain ':' so
+ * they never collide with the root namespace's keys.
+ */
+const tenantPrefix = "tenant:"
+
+var (
+	ValidNamespace = regexp.MustCompile(`\A[a-zA-Z0-9_-]+\z`)
+	ErrNamespaced  = errors.New("Backups hold every tenant, take them from the root namespace")
+)
+
+// Namespaced is implemented by stores which can hold many tenants'
+// data apart.  Check for it with a type assertion.
+type Namespaced interface {
+	// Namespace returns the tenant's store, which shares this store's
+	// connection.
+	Namespace(name string) (Store, error)
+}
+
+func (store *redisStore) Namespace(name string) (Store, error) {
+	return store.tenant(name)
+}
+
+func (store *redisStore) tenant(name string) (*redisStore, error) {
+	if store.namespace != "" {
+		return nil, errors.New("namespaces can't be nested")
+	}
+	if !ValidNamespace.MatchString(name) {
+		return nil, fmt.Errorf("namespaces must match %v", ValidNamespace)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.namespaces == nil {
+		store.namespaces = map[string]*redisStore{}
+	}
+	ns, ok := store.namespaces[name]
+	if !ok {
+		ns = &redisStore{
+			Name:      store.Name,
+			DB:        store.DB,
+			queueSet:  map[string]*redisQueue{},
+			rclient:   store.rclient,
+			namespace: tenantPrefix + name + ":",
+		}
+		ns.initSorted()
+		store.namespaces[name] = ns
+	}
+	return ns, nil
+}
+
+func (store *redisStore) hasTenants() bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return len(store.namespaces) > 0
+}
+
+func (store *redisStore) key(name string) string {
+	return store.namespace + name
+}
+
+// splitTenant splits a key in the root namespace into the tenant, if
+// any, and the key within the tenant's namespace.
+func splitTenant(key string) (string, string) {
+	if !strings.HasPrefix(key, tenantPrefix) {
+		return "", key
+	}
+	rest := key[len(tenantPrefix):]
+	idx := strings.Index(rest, ":")
+	if idx < 1 {
+		return "", key
+	}
+	return rest[:idx], rest[idx+1:]
+}
+
+// eachKey calls fn with batches of the namespace's keys.  The root
+// namespace only includes the tenants' keys if asked.
+func (store *redisStore) eachKey(tenants bool, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := store.rclient.Scan(cursor, store.namespace+"*", 1000).Result()
+		if err != nil {
+			return err
+		}
+		if store.namespace == "" && !tenants {
+			own := keys[:0]
+			for _, key := range keys {
+				if tenant, _ := splitTenant(key); tenant == "" {
+					own = append(own, key)
+				}
+			}
+			keys = own
+		}
+		if len(keys) > 0 {
+			err = fn(keys)
+			if err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisNamespace(t *testing.T) {
+	withRedis(t, "namespace", func(t *testing.T, store Store) {
+		store.Flush()
+
+		_, err := store.(Namespaced).Namespace("acme:corp")
+		assert.Error(t, err)
+		acme, err := store.(Namespaced).Namespace("acme")
+		assert.NoError(t, err)
+		_, err = acme.(Namespaced).Namespace("nested")
+		assert.Error(t, err)
+
+		root, err := store.GetQueue("default")
+		assert.NoError(t, err)
+		assert.NoError(t, root.Add(client.NewJob("Root", 1)))
+		q, err := acme.GetQueue("default")
+		assert.NoError(t, err)
+		assert.NoError(t, q.Add(client.NewJob("Acme", 1)))
+		assert.NoError(t, q.Add(client.NewJob("Acme", 2)))
+		assert.EqualValues(t, 1, root.Size())
+		assert.EqualValues(t, 2, q.Size())
+
+		data, err := acme.BPop(context.Background(), "default")
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"jobtype":"Acme"`)
+
+		job := client.NewJob("Acme", 3)
+		job.At = util.Thens(time.Now().Add(time.Hour))
+		assert.NoError(t, acme.Scheduled().Add(job))
+		assert.EqualValues(t, 0, store.Scheduled().Size())
+		assert.EqualValues(t, 1, acme.Scheduled().Size())
+
+		assert.NoError(t, acme.Success())
+		assert.EqualValues(t, 0, store.TotalProcessed())
+		assert.EqualValues(t, 1, acme.TotalProcessed())
+		assert.NoError(t, acme.Raw().Set("result", []byte("{}")))
+		val, err := store.Raw().Get("result")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		var buf bytes.Buffer
+		count, err := Export(store, &buf)
+		assert.NoError(t, err)
+		// reading the root's total created its counter
+		assert.Equal(t, 7, count)
+		assert.Contains(t, buf.String(), `{"type":"queue","tenant":"acme","name":"default"`)
+		_, err = acme.Backup(io.Discard)
+		assert.Equal(t, ErrNamespaced, err)
+
+		// the root's flush leaves the tenants alone
+		assert.NoError(t, store.Flush())
+		assert.EqualValues(t, 0, root.Size())
+		assert.EqualValues(t, 1, q.Size())
+		assert.NoError(t, acme.Flush())
+		assert.EqualValues(t, 0, q.Size())
+
+		summary, err := Import(store, strings.NewReader(buf.String()), false)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, summary.Queues["default"])
+		assert.EqualValues(t, 1, root.Size())
+		assert.EqualValues(t, 1, q.Size())
+		assert.EqualValues(t, 1, acme.TotalProcessed())
+	})
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+)
+
+// items are oldest first, indexes into the queue count from the
+// newest as with a Redis list.
+type embeddedQueue struct {
+	name  string
+	store *embeddedStore
+	items [][]byte
+}
+
+func (q *embeddedQueue) Name() string {
+	return q.name
+}
+
+func (q *embeddedQueue) Size() uint64 {
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	return uint64(len(q.items))
+}
+
+func (q *embeddedQueue) Add(job *client.Job) error {
+	job.EnqueuedAt = util.Nows()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return q.Push(job.Priority, data)
+}
+
+func (q *embeddedQueue) Push(priority uint8, payload []byte) error {
+	return q.PushAll([][]byte{payload})
+}
+
+func (q *embeddedQueue) PushAndSet(priority uint8, payload []byte, key string, value []byte, ttl time.Duration) error {
+	if value == nil {
+		return ErrNilValue
+	}
+	set := &journalOp{Op: opSet, Key: key, Vals: [][]byte{value}}
+	if ttl > 0 {
+		set.Expires = nowMillis() + int64(ttl/time.Millisecond)
+	}
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	_, err := q.store.write(&journalOp{Op: opPush, Key: q.name, Vals: [][]byte{payload}})
+	if err != nil {
+		return err
+	}
+	_, err = q.store.write(set)
+	return err
+}
+
+func (q *embeddedQueue) PushAll(payloads [][]byte) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	_, err := q.store.write(&journalOp{Op: opPush, Key: q.name, Vals: payloads})
+	return err
+}
+
+// non-blocking, returns immediately if there's nothing enqueued
+func (q *embeddedQueue) Pop() ([]byte, error) {
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	if len(q.items) == 0 {
+		return nil, nil
+	}
+	return q.store.write(&journalOp{Op: opPop, Key: q.name})
+}
+
+func (q *embeddedQueue) BPop(ctx context.Context) ([]byte, error) {
+	return q.store.BPop(ctx, q.name)
+}
+
+// Clear returns 0, like the Redis queue.
+func (q *embeddedQueue) Clear() (uint64, error) {
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	_, err := q.store.write(&journalOp{Op: opQDel, Key: q.name})
+	return 0, err
+}
+
+// Page visits the jobs from start to start+count inclusive, newest
+// first, with negative indexes counting from the oldest, like LRANGE.
+func (q *embeddedQueue) Page(start int64, count int64, fn func(index int, data []byte) error) error {
+	q.store.mu.Lock()
+	size := int64(len(q.items))
+	from, to, ok := rangeIndexes(start, start+count, size)
+	var page [][]byte
+	if ok {
+		page = make([][]byte, 0, to-from+1)
+		for idx := from; idx <= to; idx++ {
+			page = append(page, q.items[size-1-idx])
+		}
+	}
+	q.store.mu.Unlock()
+
+	for idx, data := range page {
+		err := fn(idx, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *embeddedQueue) Each(fn func(index int, data []byte) error) error {
+	return q.Page(0, -1, fn)
+}
+
+func (q *embeddedQueue) Delete(vals [][]byte) error {
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	_, err := q.store.write(&journalOp{Op: opLRem, Key: q.name, Vals: vals})
+	return err
+}
+
+func (q *embeddedQueue) Remove(val []byte) (bool, error) {
+	q.store.mu.Lock()
+	defer q.store.mu.Unlock()
+	found := false
+	for _, item := range q.items {
+		if bytes.Equal(item, val) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	_, err := q.store.write(&journalOp{Op: opLRem, Key: q.name, Vals: [][]byte{val}})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// remove drops the newest copy of val.
+func (q *embeddedQueue) remove(val []byte) {
+	for idx := len(q.items) - 1; idx >= 0; idx-- {
+		if bytes.Equal(q.items[idx], val) {
+			q.items = append(q.items[:idx], q.items[idx+1:]...)
+			return
+		}
+	}
+}
+
+// rangeIndexes resolves an inclusive range as Redis does, negative
+// indexes count back from the end.
+func rangeIndexes(start, end, size int64) (int64, int64, bool) {
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end || start >= size {
+		return 0, 0, false
+	}
+	return start, end, true
+}
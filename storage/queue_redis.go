@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -12,6 +13,7 @@ import (
 
 type redisQueue struct {
 	name  string
+	key   string
 	store *redisStore
 	done  bool
 }
@@ -19,6 +21,7 @@ type redisQueue struct {
 func (store *redisStore) NewQueue(name string) *redisQueue {
 	return &redisQueue{
 		name:  name,
+		key:   store.key(name),
 		store: store,
 		done:  false,
 	}
@@ -35,7 +38,7 @@ func (q *redisQueue) Name() string {
 func (q *redisQueue) Page(start int64, count int64, fn func(index int, data []byte) error) error {
 	index := 0
 
-	slice, err := q.store.rclient.LRange(q.name, start, start+count).Result()
+	slice, err := q.store.rclient.LRange(q.key, start, start+count).Result()
 	for _, job := range slice {
 		err = fn(index, []byte(job))
 		if err != nil {
@@ -51,7 +54,7 @@ func (q *redisQueue) Each(fn func(index int, data []byte) error) error {
 }
 
 func (q *redisQueue) Clear() (uint64, error) {
-	err := q.store.rclient.Del(q.name).Err()
+	err := q.store.rclient.Del(q.key).Err()
 	return 0, err
 }
 
@@ -61,7 +64,7 @@ func (q *redisQueue) init() error {
 }
 
 func (q *redisQueue) Size() uint64 {
-	return uint64(q.store.rclient.LLen(q.name).Val())
+	return uint64(q.store.rclient.LLen(q.key).Val())
 }
 
 func (q *redisQueue) Add(job *client.Job) error {
@@ -75,7 +78,30 @@ func (q *redisQueue) Add(job *client.Job) error {
 }
 
 func (q *redisQueue) Push(priority uint8, payload []byte) error {
-	return q.store.rclient.LPush(q.name, payload).Err()
+	return q.store.rclient.LPush(q.key, payload).Err()
+}
+
+func (q *redisQueue) PushAndSet(priority uint8, payload []byte, key string, value []byte, ttl time.Duration) error {
+	if value == nil {
+		return ErrNilValue
+	}
+	_, err := q.store.rclient.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.LPush(q.key, payload)
+		pipe.Set(q.store.key(key), value, ttl)
+		return nil
+	})
+	return err
+}
+
+func (q *redisQueue) PushAll(payloads [][]byte) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	vals := make([]interface{}, len(payloads))
+	for idx, payload := range payloads {
+		vals[idx] = payload
+	}
+	return q.store.rclient.LPush(q.key, vals...).Err()
 }
 
 // non-blocking, returns immediately if there's nothing enqueued
@@ -88,7 +114,7 @@ func (q *redisQueue) Pop() ([]byte, error) {
 }
 
 func (q *redisQueue) _pop() ([]byte, error) {
-	val, err := q.store.rclient.RPop(q.name).Result()
+	val, err := q.store.rclient.RPop(q.key).Result()
 	if val == "" {
 		return nil, nil
 	}
@@ -96,10 +122,37 @@ func (q *redisQueue) _pop() ([]byte, error) {
 }
 
 func (q *redisQueue) BPop(ctx context.Context) ([]byte, error) {
+	return q.store.BPop(ctx, q.name)
+}
+
+// Block for this long when the context has no deadline.
+const DefaultBlockTimeout = 2 * time.Second
+
+// BPop blocks until a job is pushed to any of the named queues or the
+// context's deadline passes.  Redis only blocks for whole seconds so
+// the deadline is rounded up.
+func (store *redisStore) BPop(ctx context.Context, queues ...string) ([]byte, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
-	val, err := q.store.rclient.BRPop(2*time.Second, q.name).Result()
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("BPop must be called with one or more queue names")
+	}
+
+	timeout := DefaultBlockTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = (time.Until(deadline) + time.Second - 1).Truncate(time.Second)
+	}
+	if timeout < time.Second {
+		// zero would block forever
+		timeout = time.Second
+	}
+
+	keys := make([]string, len(queues))
+	for idx, name := range queues {
+		keys[idx] = store.key(name)
+	}
+	val, err := store.rclient.BRPop(timeout, keys...).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil
@@ -110,9 +163,14 @@ func (q *redisQueue) BPop(ctx context.Context) ([]byte, error) {
 	return []byte(val[1]), nil
 }
 
+func (q *redisQueue) Remove(val []byte) (bool, error) {
+	count, err := q.store.rclient.LRem(q.key, 1, val).Result()
+	return count > 0, err
+}
+
 func (q *redisQueue) Delete(vals [][]byte) error {
 	for _, val := range vals {
-		err := q.store.rclient.LRem(q.name, 1, val).Err()
+		err := q.store.rclient.LRem(q.key, 1, val).Err()
 		if err != nil {
 			return err
 		}
@@ -13,7 +13,7 @@ import (
 )
 
 func TestBasicQueueOps(t *testing.T) {
-	withRedis(t, "queue", func(t *testing.T, store Store) {
+	withStores(t, "queue", func(t *testing.T, store Store) {
 
 		t.Run("Push", func(t *testing.T) {
 			store.Flush()
@@ -53,6 +53,24 @@ func TestBasicQueueOps(t *testing.T) {
 			assert.EqualValues(t, 0, cnt)
 			assert.EqualValues(t, 0, q.Size())
 
+			err = q.PushAll([][]byte{[]byte("one"), []byte("two"), []byte("three")})
+			assert.NoError(t, err)
+			assert.EqualValues(t, 3, q.Size())
+			data, err = q.Pop()
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("one"), data)
+			_, err = q.Clear()
+			assert.NoError(t, err)
+
+			err = q.PushAndSet(0, []byte("four"), "where", []byte("here"), time.Minute)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, q.Size())
+			value, err := store.Raw().Get("where")
+			assert.NoError(t, err)
+			assert.Equal(t, "here", string(value))
+			_, err = q.Clear()
+			assert.NoError(t, err)
+
 			// valid names:
 			_, err = store.GetQueue("A-Za-z0-9_.-")
 			assert.NoError(t, err)
@@ -68,6 +86,8 @@ func TestBasicQueueOps(t *testing.T) {
 			assert.Error(t, err)
 			_, err = store.GetQueue("user@example.com")
 			assert.Error(t, err)
+			_, err = store.GetQueue("waiting")
+			assert.Error(t, err)
 			_, err = store.GetQueue("c&c")
 			assert.Error(t, err)
 			_, err = store.GetQueue("priority|high")
@@ -174,7 +194,7 @@ func pushAndPop(t *testing.T, n int, q Queue) {
 }
 
 func TestBPopRespectsContext(t *testing.T) {
-	withRedis(t, "bpop-ctx", func(t *testing.T, store Store) {
+	withStores(t, "bpop-ctx", func(t *testing.T, store Store) {
 		store.Flush()
 		q, err := store.GetQueue("default")
 		assert.NoError(t, err)
@@ -195,8 +215,41 @@ func TestBPopRespectsContext(t *testing.T) {
 	})
 }
 
+func TestBPopMultipleQueues(t *testing.T) {
+	withStores(t, "bpop-multi", func(t *testing.T, store Store) {
+		store.Flush()
+		_, err := store.GetQueue("first")
+		assert.NoError(t, err)
+		second, err := store.GetQueue("second")
+		assert.NoError(t, err)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			second.Push(5, []byte("hello"))
+		}()
+
+		start := time.Now()
+		data, err := store.BPop(context.Background(), "first", "second")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+		assert.True(t, time.Since(start) < time.Second, "should wake on the second queue, took %v", time.Since(start))
+
+		// honours the deadline rather than the default timeout
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		start = time.Now()
+		data, err = store.BPop(ctx, "first", "second")
+		assert.NoError(t, err)
+		assert.Nil(t, data)
+		assert.True(t, time.Since(start) < DefaultBlockTimeout, "should block for at most a second, took %v", time.Since(start))
+
+		_, err = store.BPop(context.Background())
+		assert.Error(t, err)
+	})
+}
+
 func TestEachQueueConcurrentAccess(t *testing.T) {
-	withRedis(t, "eachqueue", func(t *testing.T, store Store) {
+	withStores(t, "eachqueue", func(t *testing.T, store Store) {
 		store.Flush()
 
 		// Create some initial queues
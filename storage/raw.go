@@ -2,6 +2,7 @@ package storage
 
 import (
 	"errors"
+	"time"
 
 	"github.com/go-redis/redis"
 )
@@ -13,6 +14,20 @@ var (
 type KV interface {
 	Get(key string) ([]byte, error)
 	Set(key string, value []byte) error
+	// SetEx sets the value, which will be removed after the given TTL
+	SetEx(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	// DeleteAll deletes many values in a single round trip
+	DeleteAll(keys []string) error
+	// SetAll sets many values in a single round trip, removing them
+	// after the TTL unless it's 0
+	SetAll(values map[string][]byte, ttl time.Duration) error
+	// SAdd adds the member to the set at key, which will be removed
+	// after the TTL unless it's 0
+	SAdd(key string, member []byte, ttl time.Duration) error
+	// SMembers returns the members of the set at key, nil if there
+	// is no such set
+	SMembers(key string) ([][]byte, error)
 }
 
 // Provide a basic KV scratch pad, for misc feature usage.
@@ -26,7 +41,7 @@ func (s *redisStore) Raw() KV {
 }
 
 func (kv *redisKV) Get(key string) ([]byte, error) {
-	value, err := kv.store.rclient.Get(key).Result()
+	value, err := kv.store.rclient.Get(kv.store.key(key)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil
@@ -40,5 +55,71 @@ func (kv *redisKV) Set(key string, value []byte) error {
 	if value == nil {
 		return ErrNilValue
 	}
-	return kv.store.rclient.Set(key, value, 0).Err()
+	return kv.store.rclient.Set(kv.store.key(key), value, 0).Err()
+}
+
+func (kv *redisKV) SetEx(key string, value []byte, ttl time.Duration) error {
+	if value == nil {
+		return ErrNilValue
+	}
+	return kv.store.rclient.Set(kv.store.key(key), value, ttl).Err()
+}
+
+func (kv *redisKV) Delete(key string) error {
+	return kv.store.rclient.Del(kv.store.key(key)).Err()
+}
+
+func (kv *redisKV) DeleteAll(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	names := make([]string, len(keys))
+	for idx, key := range keys {
+		names[idx] = kv.store.key(key)
+	}
+	return kv.store.rclient.Del(names...).Err()
+}
+
+func (kv *redisKV) SetAll(values map[string][]byte, ttl time.Duration) error {
+	for _, value := range values {
+		if value == nil {
+			return ErrNilValue
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := kv.store.rclient.Pipelined(func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			pipe.Set(kv.store.key(key), value, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (kv *redisKV) SAdd(key string, member []byte, ttl time.Duration) error {
+	if member == nil {
+		return ErrNilValue
+	}
+	_, err := kv.store.rclient.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SAdd(kv.store.key(key), member)
+		if ttl > 0 {
+			pipe.Expire(kv.store.key(key), ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (kv *redisKV) SMembers(key string) ([][]byte, error) {
+	members, err := kv.store.rclient.SMembers(kv.store.key(key)).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	values := make([][]byte, len(members))
+	for idx, member := range members {
+		values[idx] = []byte(member)
+	}
+	return values, nil
 }
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
@@ -16,7 +17,6 @@ import (
 	"regexp"
 
 	"github.com/go-redis/redis"
-	"github.com/hunter-io/faktory/client"
 	"github.com/hunter-io/faktory/util"
 )
 
@@ -28,18 +28,61 @@ type redisStore struct {
 	retries   *redisSorted
 	dead      *redisSorted
 	working   *redisSorted
+	waiting   *redisSorted
 
 	rclient *redis.Client
 	DB      int
+
+	// prefixes every key, empty in the root namespace
+	namespace string
+	// the root's tenants, see Namespace
+	namespaces map[string]*redisStore
 }
 
 var (
 	opens      = 0
 	instances  = map[string]*exec.Cmd{}
 	redisMutex = sync.Mutex{}
+	// the requirepass of replicated instances
+	passwords = map[string]string{}
+	// the directory and settings each instance was booted with, its
+	// redis.conf is rewritten from them before a restart
+	dirs    = map[string]string{}
+	configs = map[string]*RedisConfig{}
 )
 
+// IsUnavailable is true for errors caused by Redis being down or
+// restarting rather than by the command itself.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	// restarted and still loading the dataset
+	return strings.HasPrefix(err.Error(), "LOADING")
+}
+
 func BootRedis(path string, sock string) (func(), error) {
+	return BootConfiguredRedis(path, sock, nil)
+}
+
+// BootConfiguredRedis boots Redis with the given settings, the defaults
+// if conf is nil.
+func BootConfiguredRedis(path string, sock string, conf *RedisConfig) (func(), error) {
+	if conf == nil {
+		conf = &RedisConfig{}
+	}
+	err := conf.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	redisMutex.Lock()
 	defer redisMutex.Unlock()
 	if _, ok := instances[sock]; ok {
@@ -47,30 +90,31 @@ func BootRedis(path string, sock string) (func(), error) {
 	}
 	util.Infof("Initializing redis storage at %s, socket %s", path, sock)
 
-	err := os.MkdirAll(path, os.ModeDir|0755)
+	err = os.MkdirAll(path, os.ModeDir|0755)
 	if err != nil {
 		return nil, fmt.Errorf("boot redis: create directory %q: %w", path, err)
 	}
 
+	password := ""
+	if conf.Replication != nil {
+		password = conf.Replication.Password
+	}
 	rclient := redis.NewClient(&redis.Options{
-		Network: "unix",
-		Addr:    sock,
+		Network:  "unix",
+		Addr:     sock,
+		Password: password,
 	})
 
 	_, err = rclient.Ping().Result()
 	if err != nil {
 		//util.Debugf("Redis not alive, booting... -- %s", err)
 
-		conffilename := "/tmp/redis.conf"
-		if _, err := os.Stat(conffilename); err != nil {
-			if err != nil && os.IsNotExist(err) {
-				err := os.WriteFile("/tmp/redis.conf", []byte(fmt.Sprintf(redisconf, client.Version)), 0444)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				return nil, err
-			}
+		// regenerated every boot, so upgrades and config changes apply;
+		// it's kept out of the arguments so the password doesn't show up
+		// in ps
+		conffilename, err := writeRedisConf(path, conf)
+		if err != nil {
+			return nil, err
 		}
 
 		binary, err := exec.LookPath("redis-server")
@@ -78,35 +122,23 @@ func BootRedis(path string, sock string) (func(), error) {
 			return nil, err
 		}
 
-		logfile := fmt.Sprintf("%s/redis.log", path)
-		loglevel := "warning"
-		if util.LogDebug {
-			loglevel = "notice"
-		}
 		arguments := []string{
 			binary,
 			conffilename,
 			"--unixsocket",
 			sock,
-			"--loglevel",
-			loglevel,
-			"--dir",
-			path,
-			"--logfile",
-			logfile,
 		}
 
 		util.Debugf("Booting Redis: %s", strings.Join(arguments, " "))
 
-		cmd := exec.Command(arguments[0], arguments[1:]...)
-		util.EnsureChildShutdown(cmd, util.SIGTERM) // platform-specific tuning
-		//cmd.Stdout = os.Stdout
-		//cmd.Stderr = os.Stderr
-		instances[sock] = cmd
-		err = cmd.Start()
+		cmd, err := startRedis(arguments)
 		if err != nil {
-			return nil, fmt.Errorf("boot redis: start process: %w", err)
+			return nil, err
 		}
+		instances[sock] = cmd
+		passwords[sock] = password
+		dirs[sock] = path
+		configs[sock] = conf
 
 		// wait a few seconds for Redis to start
 		start := time.Now()
@@ -122,14 +154,7 @@ func BootRedis(path string, sock string) (func(), error) {
 		done := time.Now()
 		util.Debugf("Redis booted in %s", done.Sub(start))
 
-		// This will panic and crash Faktory if Redis dies for some reason.
-		go func() {
-			err := cmd.Wait()
-			if err != nil {
-				util.Warnf("Redis at %s crashed: %s", path, err)
-				panic(err)
-			}
-		}()
+		go superviseRedis(path, sock, cmd)
 	}
 
 	for i := 0; i < 250; i++ {
@@ -172,6 +197,83 @@ func BootRedis(path string, sock string) (func(), error) {
 	return func() { StopRedis(sock) }, nil
 }
 
+func startRedis(arguments []string) (*exec.Cmd, error) {
+	cmd := exec.Command(arguments[0], arguments[1:]...)
+	util.EnsureChildShutdown(cmd, util.SIGTERM) // platform-specific tuning
+	err := cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("boot redis: start process: %w", err)
+	}
+	return cmd, nil
+}
+
+var (
+	// Restarts of a crashed Redis back off exponentially from the
+	// minimum to the maximum, a Redis which stays up for RedisStableAfter
+	// resets the backoff.
+	RedisRestartMin  = 100 * time.Millisecond
+	RedisRestartMax  = 30 * time.Second
+	RedisStableAfter = time.Minute
+)
+
+// superviseRedis restarts redis-server whenever it exits, until
+// StopRedis is called.  Commands fail meanwhile with connection errors,
+// which IsUnavailable recognizes so clients get an UNAVAILABLE error.
+func superviseRedis(path string, sock string, cmd *exec.Cmd) {
+	backoff := RedisRestartMin
+	started := time.Now()
+	for {
+		err := cmd.Wait()
+
+		redisMutex.Lock()
+		stopped := instances[sock] != cmd
+		redisMutex.Unlock()
+		if stopped {
+			return
+		}
+
+		if err == nil {
+			err = errors.New("exited")
+		}
+		if time.Since(started) > RedisStableAfter {
+			backoff = RedisRestartMin
+		}
+		util.Warnf("Redis at %s crashed: %s", path, err)
+
+		for {
+			util.Infof("Restarting Redis in %v", backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > RedisRestartMax {
+				backoff = RedisRestartMax
+			}
+
+			redisMutex.Lock()
+			if instances[sock] != cmd {
+				redisMutex.Unlock()
+				return
+			}
+			// e.g. a promoted replica no longer follows the primary
+			_, err := writeRedisConf(path, configs[sock])
+			if err != nil {
+				util.Warnf("Unable to update redis.conf: %v", err)
+			}
+			next, err := startRedis(cmd.Args)
+			if err == nil {
+				instances[sock] = next
+			}
+			redisMutex.Unlock()
+
+			if err == nil {
+				cmd = next
+				started = time.Now()
+				break
+			}
+			util.Warnf("Unable to restart Redis at %s: %v", path, err)
+		}
+	}
+}
+
 func OpenRedis(sock string) (Store, error) {
 	redisMutex.Lock()
 	defer redisMutex.Unlock()
@@ -191,6 +293,7 @@ func OpenRedis(sock string) (Store, error) {
 	rs.rclient = redis.NewClient(&redis.Options{
 		Network:      "unix",
 		Addr:         sock,
+		Password:     passwords[sock],
 		DB:           db,
 		PoolSize:     9950,
 		PoolTimeout:  time.Minute,
@@ -225,8 +328,15 @@ func (store *redisStore) EachQueue(x func(Queue)) {
 	}
 }
 
+// Flush only clears the store's namespace, the root leaves the
+// tenants' data alone.
 func (store *redisStore) Flush() error {
-	return store.rclient.FlushDB().Err()
+	if store.namespace == "" && !store.hasTenants() {
+		return store.rclient.FlushDB().Err()
+	}
+	return store.eachKey(false, func(keys []string) error {
+		return store.rclient.Del(keys...).Err()
+	})
 }
 
 var (
@@ -249,6 +359,9 @@ func (store *redisStore) GetQueue(name string) (Queue, error) {
 	if !ValidQueueName.MatchString(name) {
 		return nil, fmt.Errorf("queue names must match %v", ValidQueueName)
 	}
+	if isSortedSet(name) {
+		return nil, fmt.Errorf("queue name %q is reserved", name)
+	}
 
 	q = store.NewQueue(name)
 	err := q.init()
@@ -260,6 +373,10 @@ func (store *redisStore) GetQueue(name string) (Queue, error) {
 }
 
 func (store *redisStore) Close() error {
+	if store.namespace != "" {
+		// the root owns the connection
+		return nil
+	}
 	util.Debug("Stopping storage")
 	store.mu.Lock()
 	defer store.mu.Unlock()
@@ -280,15 +397,24 @@ func StopRedis(sock string) error {
 		return errors.New("No such redis instance " + sock)
 	}
 
+	// delete first so the supervisor doesn't restart it
+	delete(instances, sock)
+	delete(passwords, sock)
+	delete(dirs, sock)
+	delete(configs, sock)
+
 	util.Debugf("Shutting down Redis PID %d", cmd.Process.Pid)
 	before := time.Now()
 	p := cmd.Process
 	pid := p.Pid
 	err := p.Signal(syscall.SIGTERM)
+	if errors.Is(err, os.ErrProcessDone) {
+		// crashed and waiting to be restarted
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	delete(instances, sock)
 
 	// Test suite hack: Redis will not exit if we
 	// don't give it enough time to reopen the RDB
@@ -323,98 +449,14 @@ func (store *redisStore) Dead() SortedSet {
 	return store.dead
 }
 
-func (store *redisStore) EnqueueAll(sset SortedSet) error {
-	return sset.Each(func(_ int, entry SortedEntry) error {
-		j, err := entry.Job()
-		if err != nil {
-			return err
-		}
-
-		k, err := entry.Key()
-		if err != nil {
-			return err
-		}
-
-		q, err := store.GetQueue(j.Queue)
-		if err != nil {
-			return err
-		}
-
-		ok, err := sset.Remove(k)
-		if err != nil {
-			return err
-		}
-		if !ok {
-			return nil
-		}
+func (store *redisStore) Waiting() SortedSet {
+	return store.waiting
+}
 
-		return q.Add(j)
-	})
+func (store *redisStore) EnqueueAll(sset SortedSet) error {
+	return enqueueAll(store, sset)
 }
 
 func (store *redisStore) EnqueueFrom(sset SortedSet, key []byte) error {
-	entry, err := sset.Get(key)
-	if err != nil {
-		return err
-	}
-	if entry == nil {
-		// race condition, element was removed already
-		return nil
-	}
-
-	job, err := entry.Job()
-	if err != nil {
-		return err
-	}
-
-	q, err := store.GetQueue(job.Queue)
-	if err != nil {
-		return err
-	}
-
-	ok, err := sset.Remove(key)
-	if err != nil {
-		return err
-	}
-	if !ok {
-		return nil
-	}
-
-	return q.Add(job)
+	return enqueueFrom(store, sset, key)
 }
-
-const (
-	redisconf = `
-# DO NOT EDIT
-# Created by Faktory %s
-bind 127.0.0.1 ::1
-port 0
-
-# Faktory's redis is only available via local Unix socket.
-# This maximizes performance and minimizes opsec risk.
-unixsocket /tmp/faktory-redis.sock
-unixsocketperm 700
-timeout 0
-
-daemonize no
-maxmemory-policy noeviction
-
-# Specify the server verbosity level.
-# This can be one of:
-# debug (a lot of information, useful for development/testing)
-# verbose (many rarely useful info, but not a mess like the debug level)
-# notice (moderately verbose, what you want in production probably)
-# warning (only very important / critical messages are logged)
-loglevel notice
-logfile /tmp/faktory-redis.log
-
-# we're pretty aggressive on persistence to minimize data loss.
-# remember you can take backups of the RDB file with a simple 'cp'.
-save 120 1
-save 30 5
-stop-writes-on-bgsave-error yes
-rdbcompression yes
-rdbchecksum yes
-dbfilename faktory.rdb
-`
-)
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * RedisConfig tunes the redis-server booted by Faktory.  It's rendered
+ * into redis.conf within the storage directory, so each instance has
+ * its own, and rewritten on boot whenever it changes, e.g. after an
+ * upgrade.  From the config:
+ *
+ *   [redis]
+ *   maxmemory = "2gb"
+ *   save = ["900 1", "300 10"]
+ *   appendonly = true
+ *   appendfsync = "always"
+ */
+type RedisConfig struct {
+	// Redis rejects writes past this size, e.g. "2gb" or bytes, empty
+	// keeps the default of no limit
+	MaxMemory string
+	// RDB snapshot rules, "seconds changes", nil keeps the defaults and
+	// an empty slice disables snapshots
+	Save []string
+	// Log every write to an append only file as well
+	AppendOnly bool
+	// How often the append only file is synced: "always", "everysec",
+	// the default, or "no"
+	AppendFsync string
+	// Pair with a Redis on another box, see RedisReplication
+	Replication *RedisReplication
+}
+
+var DefaultRedisSave = []string{"120 1", "30 5"}
+
+func (rc *RedisConfig) Validate() error {
+	if rc.MaxMemory != "" && !memorySize.MatchString(rc.MaxMemory) {
+		return fmt.Errorf("redis: invalid maxmemory %q, need a size like \"2gb\" or bytes", rc.MaxMemory)
+	}
+	for _, rule := range rc.Save {
+		fields := strings.Fields(rule)
+		valid := len(fields) == 2
+		for _, field := range fields {
+			if _, err := strconv.ParseUint(field, 10, 32); err != nil {
+				valid = false
+			}
+		}
+		if !valid {
+			return fmt.Errorf("redis: invalid save rule %q, need \"seconds changes\"", rule)
+		}
+	}
+	switch rc.AppendFsync {
+	case "", "always", "everysec", "no":
+	default:
+		return fmt.Errorf("redis: invalid appendfsync %q, need always, everysec or no", rc.AppendFsync)
+	}
+	if rc.Replication != nil {
+		return rc.Replication.Validate()
+	}
+	return nil
+}
+
+var redisConfTemplate = template.Must(template.New("redis.conf").Funcs(template.FuncMap{"quote": redisQuote}).Parse(`# DO NOT EDIT
+# Created by Faktory {{.Version}} from the [redis] config section,
+# changes are overwritten on boot.
+{{- with .Replication}}
+bind {{.Host}}
+port {{.Port}}
+requirepass {{quote .Password}}
+masterauth {{quote .Password}}
+{{- if .PrimaryHost}}
+replicaof {{.PrimaryHost}} {{.PrimaryPort}}
+{{- end}}
+{{- else}}
+bind 127.0.0.1 ::1
+port 0
+{{- end}}
+
+# Faktory's redis is only available via local Unix socket, given on
+# the command line.  This maximizes performance and minimizes opsec risk.
+unixsocketperm 700
+timeout 0
+
+daemonize no
+maxmemory-policy noeviction
+{{- with .MaxMemory}}
+maxmemory {{.}}
+{{- end}}
+
+dir {{quote .Dir}}
+loglevel {{.LogLevel}}
+logfile {{quote .LogFile}}
+
+# we're pretty aggressive on persistence to minimize data loss.
+# remember you can take backups of the RDB file with a simple 'cp'.
+{{- range .Save}}
+save {{.}}
+{{- else}}
+save ""
+{{- end}}
+stop-writes-on-bgsave-error yes
+rdbcompression yes
+rdbchecksum yes
+dbfilename faktory.rdb
+{{- if .AppendOnly}}
+
+appendonly yes
+appendfilename "faktory.aof"
+appendfsync {{.AppendFsync}}
+{{- end}}
+`))
+
+// redisQuote quotes s the way redis.conf arguments are parsed.  Unlike
+// Go's %q, UTF-8 is kept as is and control characters become \xHH.
+func redisQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' || c == '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+type redisConfReplication struct {
+	Host        string
+	Port        string
+	Password    string
+	PrimaryHost string
+	PrimaryPort string
+}
+
+// renderRedisConf returns the redis.conf for the instance at path.
+func renderRedisConf(path string, rc *RedisConfig) ([]byte, error) {
+	loglevel := "warning"
+	if util.LogDebug {
+		loglevel = "notice"
+	}
+	save := rc.Save
+	if save == nil {
+		save = DefaultRedisSave
+	}
+	fsync := rc.AppendFsync
+	if fsync == "" {
+		fsync = "everysec"
+	}
+
+	var repl *redisConfReplication
+	if rr := rc.Replication; rr != nil {
+		repl = &redisConfReplication{Password: rr.Password}
+		repl.Host, repl.Port, _ = net.SplitHostPort(rr.Listen)
+		if rr.Primary != "" && !rr.Promoted(path) {
+			repl.PrimaryHost, repl.PrimaryPort, _ = net.SplitHostPort(rr.Primary)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := redisConfTemplate.Execute(&buf, map[string]any{
+		"Version":     client.Version,
+		"Replication": repl,
+		"MaxMemory":   rc.MaxMemory,
+		"Dir":         path,
+		"LogLevel":    loglevel,
+		"LogFile":     filepath.Join(path, "redis.log"),
+		"Save":        save,
+		"AppendOnly":  rc.AppendOnly,
+		"AppendFsync": fsync,
+	})
+	return buf.Bytes(), err
+}
+
+// writeRedisConf renders redis.conf into the storage directory unless
+// it's already up to date, returning its filename.  It may hold the
+// replication password so only we can read it.
+func writeRedisConf(path string, rc *RedisConfig) (string, error) {
+	conf, err := renderRedisConf(path, rc)
+	if err != nil {
+		return "", fmt.Errorf("redis.conf: %w", err)
+	}
+
+	filename := filepath.Join(path, "redis.conf")
+	current, err := os.ReadFile(filename)
+	if err == nil && bytes.Equal(current, conf) {
+		return filename, nil
+	}
+	if err == nil {
+		util.Infof("Regenerating %s", filename)
+	}
+	err = os.WriteFile(filename, conf, 0600)
+	if err != nil {
+		return "", fmt.Errorf("redis.conf: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	err = os.Chmod(filename, 0600)
+	if err != nil {
+		return "", fmt.Errorf("redis.conf: %w", err)
+	}
+	return filename, nil
+}
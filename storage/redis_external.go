@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * ExternalRedis points Faktory at a Redis it does not manage, e.g.
+ * a managed cloud instance, rather than booting redis-server itself.
+ *
+ *   redis://[:password@]host[:port][/db]
+ *   rediss://[:password@]host[:port][/db]   (TLS)
+ */
+type ExternalRedis struct {
+	URL string
+	// Overrides any password in the URL
+	Password string
+	// Overrides the db in the URL when non-zero
+	DB int
+	// Maximum number of connections, defaults to 1000
+	PoolSize int
+}
+
+const DefaultExternalPoolSize = 1000
+
+func (er *ExternalRedis) Options() (*redis.Options, error) {
+	opts, err := redis.ParseURL(er.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	if er.Password != "" {
+		opts.Password = er.Password
+	}
+	if er.DB < 0 {
+		return nil, fmt.Errorf("invalid redis db: %d", er.DB)
+	}
+	if er.DB > 0 {
+		opts.DB = er.DB
+	}
+	opts.PoolSize = er.PoolSize
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = DefaultExternalPoolSize
+	}
+	opts.PoolTimeout = 30 * time.Second
+	opts.ReadTimeout = 10 * time.Second
+	opts.WriteTimeout = 10 * time.Second
+	// reconnect transparently when the network blips
+	opts.MaxRetries = 2
+	return opts, nil
+}
+
+// String is safe to log, it never includes the password.
+func (er *ExternalRedis) String() string {
+	opts, err := er.Options()
+	if err != nil {
+		return er.URL
+	}
+	scheme := "redis"
+	if opts.TLSConfig != nil {
+		scheme = "rediss"
+	}
+	return fmt.Sprintf("%s://%s/%d", scheme, opts.Addr, opts.DB)
+}
+
+func OpenExternalRedis(er *ExternalRedis) (Store, error) {
+	opts, err := er.Options()
+	if err != nil {
+		return nil, err
+	}
+	util.Infof("Using external redis at %s", er)
+
+	rs := &redisStore{
+		Name:     er.String(),
+		DB:       opts.DB,
+		mu:       sync.Mutex{},
+		queueSet: map[string]*redisQueue{},
+	}
+	rs.initSorted()
+	rs.rclient = redis.NewClient(opts)
+
+	_, err = rs.rclient.Ping().Result()
+	if err != nil {
+		rs.rclient.Close()
+		return nil, fmt.Errorf("connect to redis at %s: %w", er, err)
+	}
+	return rs, nil
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalRedis(t *testing.T) {
+	er := &ExternalRedis{URL: "rediss://:secret@redis.example.com:6380/2"}
+	opts, err := er.Options()
+	assert.NoError(t, err)
+	assert.Equal(t, "redis.example.com:6380", opts.Addr)
+	assert.Equal(t, "secret", opts.Password)
+	assert.Equal(t, 2, opts.DB)
+	assert.Equal(t, DefaultExternalPoolSize, opts.PoolSize)
+	assert.NotNil(t, opts.TLSConfig)
+	assert.Equal(t, "rediss://redis.example.com:6380/2", er.String())
+
+	er = &ExternalRedis{URL: "redis://localhost", Password: "other", DB: 4, PoolSize: 10}
+	opts, err = er.Options()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:6379", opts.Addr)
+	assert.Equal(t, "other", opts.Password)
+	assert.Equal(t, 4, opts.DB)
+	assert.Equal(t, 10, opts.PoolSize)
+	assert.Nil(t, opts.TLSConfig)
+
+	_, err = (&ExternalRedis{URL: "localhost:6379"}).Options()
+	assert.Error(t, err)
+
+	// nothing listens on port 1, opening fails rather than panicking
+	store, err := OpenExternalRedis(&ExternalRedis{URL: "redis://127.0.0.1:1"})
+	assert.Error(t, err)
+	assert.Nil(t, store)
+}
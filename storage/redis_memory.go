@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MemoryUsage returns the bytes of memory Redis uses and its
+// maxmemory, 0 if it's unlimited.
+func MemoryUsage(store Redis) (int64, int64, error) {
+	info, err := store.Redis().Info("memory").Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseMemoryInfo(info)
+}
+
+func parseMemoryInfo(info string) (int64, int64, error) {
+	var used, max int64 = -1, -1
+	scn := bufio.NewScanner(strings.NewReader(info))
+	for scn.Scan() {
+		key, val, ok := strings.Cut(strings.TrimSpace(scn.Text()), ":")
+		if !ok || (key != "used_memory" && key != "maxmemory") {
+			continue
+		}
+		num, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s %q", key, val)
+		}
+		if key == "used_memory" {
+			used = num
+		} else {
+			max = num
+		}
+	}
+	if used < 0 || max < 0 {
+		return 0, 0, fmt.Errorf("INFO memory is missing used_memory or maxmemory")
+	}
+	return used, max, nil
+}
+
+// a maxmemory in redis.conf's units, bytes unless suffixed
+var memorySize = regexp.MustCompile(`(?i)^[0-9]+(b|k|kb|m|mb|g|gb)?$`)
+
+// SetMaxMemory changes the maxmemory of a running Redis, e.g. "2gb"
+// or a number of bytes.  The Redis booted by Faktory keeps it in its
+// redis.conf too, so it survives a restart.
+func SetMaxMemory(store Redis, size string) error {
+	if !memorySize.MatchString(size) {
+		return fmt.Errorf("invalid size %q", size)
+	}
+	err := store.Redis().ConfigSet("maxmemory", size).Err()
+	if err != nil {
+		return err
+	}
+
+	sock := store.Redis().Options().Addr
+	redisMutex.Lock()
+	defer redisMutex.Unlock()
+	conf, ok := configs[sock]
+	if !ok || conf.MaxMemory == size {
+		return nil
+	}
+	conf.MaxMemory = size
+	_, err = writeRedisConf(dirs[sock], conf)
+	return err
+}
@@ -3,13 +3,16 @@ package storage
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/hunter-io/faktory/client"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRedisKV(t *testing.T) {
-	withRedis(t, "default", func(t *testing.T, store Store) {
+	withStores(t, "default", func(t *testing.T, store Store) {
 		store.Flush()
 		kv := store.Raw()
 		assert.NotNil(t, kv)
@@ -28,12 +31,82 @@ func TestRedisKV(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, val)
 		assert.Equal(t, "bob", string(val))
+
+		err = kv.SetEx("temp", []byte("x"), 50*time.Millisecond)
+		assert.NoError(t, err)
+		val, err = kv.Get("temp")
+		assert.NoError(t, err)
+		assert.Equal(t, "x", string(val))
+		time.Sleep(100 * time.Millisecond)
+		val, err = kv.Get("temp")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		err = kv.Delete("mike")
+		assert.NoError(t, err)
+		val, err = kv.Get("mike")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		err = kv.SetAll(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, 0)
+		assert.NoError(t, err)
+		val, err = kv.Get("b")
+		assert.NoError(t, err)
+		assert.Equal(t, "2", string(val))
+		err = kv.SetAll(map[string][]byte{"c": nil}, 0)
+		assert.Equal(t, ErrNilValue, err)
+
+		err = kv.SetAll(map[string][]byte{"d": []byte("4")}, 50*time.Millisecond)
+		assert.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+		val, err = kv.Get("d")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		members, err := kv.SMembers("deps")
+		assert.NoError(t, err)
+		assert.Nil(t, members)
+		assert.NoError(t, kv.SAdd("deps", []byte("x"), 0))
+		assert.NoError(t, kv.SAdd("deps", []byte("y"), time.Hour))
+		assert.NoError(t, kv.SAdd("deps", []byte("x"), time.Hour))
+		assert.Equal(t, ErrNilValue, kv.SAdd("deps", nil, 0))
+		members, err = kv.SMembers("deps")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, [][]byte{[]byte("x"), []byte("y")}, members)
+		assert.NoError(t, kv.Delete("deps"))
+		members, err = kv.SMembers("deps")
+		assert.NoError(t, err)
+		assert.Nil(t, members)
 	})
 }
 
-func withRedis(t *testing.T, name string, fn func(*testing.T, Store)) {
+// withStores runs fn against each backend.
+func withStores(t *testing.T, name string, fn func(*testing.T, Store)) {
 	t.Parallel()
 
+	t.Run("redis", func(t *testing.T) {
+		withRedis(t, name, fn)
+	})
+	t.Run("embedded", func(t *testing.T) {
+		withEmbedded(t, name, fn)
+	})
+	t.Run("memory", func(t *testing.T) {
+		store, err := OpenMemory(name)
+		if err != nil {
+			panic(err)
+		}
+		defer store.Close()
+
+		fn(t, store)
+	})
+}
+
+// FAKTORY_TEST_DB=memory skips redis for machines without redis-server
+func withRedis(t *testing.T, name string, fn func(*testing.T, Store)) {
+	if os.Getenv("FAKTORY_TEST_DB") == "memory" {
+		t.Skip("FAKTORY_TEST_DB=memory")
+	}
+
 	dir := fmt.Sprintf("/tmp/faktory-test-%s", name)
 	defer os.RemoveAll(dir)
 
@@ -54,3 +127,232 @@ func withRedis(t *testing.T, name string, fn func(*testing.T, Store)) {
 
 	fn(t, store)
 }
+
+func withEmbedded(t *testing.T, name string, fn func(*testing.T, Store)) {
+	dir := fmt.Sprintf("/tmp/faktory-test-embedded-%s", name)
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenEmbedded(dir)
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+
+	fn(t, store)
+}
+
+func TestRedisRestart(t *testing.T) {
+	if os.Getenv("FAKTORY_TEST_DB") == "memory" {
+		t.Skip("FAKTORY_TEST_DB=memory")
+	}
+	t.Parallel()
+
+	dir := "/tmp/faktory-test-restart"
+	defer os.RemoveAll(dir)
+	sock := fmt.Sprintf("%s/redis.sock", dir)
+	stopper, err := BootRedis(dir, sock)
+	if stopper != nil {
+		defer stopper()
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	store, err := OpenRedis(sock)
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+
+	kv := store.Raw()
+	assert.NoError(t, kv.Set("foo", []byte("bar")))
+
+	redisMutex.Lock()
+	cmd := instances[sock]
+	redisMutex.Unlock()
+	assert.NoError(t, cmd.Process.Kill())
+
+	_, err = kv.Get("foo")
+	for i := 0; i < 1000 && err == nil; i++ {
+		// not dead yet
+		time.Sleep(time.Millisecond)
+		_, err = kv.Get("foo")
+	}
+	assert.True(t, IsUnavailable(err), "%v", err)
+
+	// the supervisor restarts it after RedisRestartMin
+	for i := 0; i < 100 && err != nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+		_, err = kv.Get("foo")
+	}
+	assert.NoError(t, err)
+
+	redisMutex.Lock()
+	assert.NotEqual(t, cmd, instances[sock])
+	redisMutex.Unlock()
+}
+
+func TestRedisReplication(t *testing.T) {
+	repl := &RedisReplication{Listen: "10.0.0.2:7421", Primary: "10.0.0.1:7421", Password: "sekrit"}
+	assert.NoError(t, repl.Validate())
+
+	conf, err := renderRedisConf(t.TempDir(), &RedisConfig{Replication: repl})
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nbind 10.0.0.2\nport 7421\nrequirepass \"sekrit\"\nmasterauth \"sekrit\"\nreplicaof 10.0.0.1 7421\n")
+
+	// quoted for redis.conf rather than Go
+	repl.Password = "s\"e\\k\tr\u00eft"
+	conf, err = renderRedisConf(t.TempDir(), &RedisConfig{Replication: repl})
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nrequirepass \"s\\\"e\\\\k\\x09r\u00eft\"\n")
+
+	assert.Error(t, (&RedisReplication{Listen: "7421", Password: "sekrit"}).Validate())
+	assert.Error(t, (&RedisReplication{Listen: "10.0.0.2:7421"}).Validate())
+}
+
+func TestRedisPromotion(t *testing.T) {
+	if os.Getenv("FAKTORY_TEST_DB") == "memory" {
+		t.Skip("FAKTORY_TEST_DB=memory")
+	}
+	t.Parallel()
+
+	dir := "/tmp/faktory-test-promotion"
+	defer os.RemoveAll(dir)
+	sock := fmt.Sprintf("%s/redis.sock", dir)
+	repl := &RedisReplication{Listen: "127.0.0.1:7431", Primary: "10.0.0.1:7421", Password: "sekrit"}
+	stopper, err := BootConfiguredRedis(dir, sock, &RedisConfig{Replication: repl})
+	if stopper != nil {
+		defer stopper()
+	}
+	if err != nil {
+		panic(err)
+	}
+	conf, err := os.ReadFile(filepath.Join(dir, "redis.conf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nreplicaof 10.0.0.1 7421\n")
+	assert.False(t, repl.Promoted(dir))
+
+	store, err := OpenRedis(sock)
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+	assert.NoError(t, StopReplication(store))
+	assert.True(t, repl.Promoted(dir))
+
+	// the supervisor restarts it as a primary
+	redisMutex.Lock()
+	cmd := instances[sock]
+	redisMutex.Unlock()
+	assert.NoError(t, cmd.Process.Kill())
+	for i := 0; i < 100; i++ {
+		time.Sleep(50 * time.Millisecond)
+		redisMutex.Lock()
+		restarted := instances[sock] != cmd
+		redisMutex.Unlock()
+		if restarted {
+			break
+		}
+	}
+	redisMutex.Lock()
+	assert.NotEqual(t, cmd, instances[sock])
+	redisMutex.Unlock()
+	conf, err = os.ReadFile(filepath.Join(dir, "redis.conf"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(conf), "replicaof")
+
+	// and so does a reboot, until it's pointed at another primary
+	conf, err = renderRedisConf(dir, &RedisConfig{Replication: repl})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(conf), "replicaof")
+	other := &RedisReplication{Listen: repl.Listen, Primary: "10.0.0.3:7421", Password: "sekrit"}
+	assert.False(t, other.Promoted(dir))
+	conf, err = renderRedisConf(dir, &RedisConfig{Replication: other})
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nreplicaof 10.0.0.3 7421\n")
+}
+
+func TestRedisConf(t *testing.T) {
+	dir := t.TempDir()
+	filename, err := writeRedisConf(dir, &RedisConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "redis.conf"), filename)
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	conf := string(data)
+	assert.Contains(t, conf, "# Created by Faktory "+client.Version)
+	assert.Contains(t, conf, "\nbind 127.0.0.1 ::1\nport 0\n")
+	assert.Contains(t, conf, fmt.Sprintf("\ndir %q\n", dir))
+	assert.Contains(t, conf, "\nsave 120 1\nsave 30 5\n")
+	assert.NotContains(t, conf, "appendonly")
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// rewritten when the config, or Faktory's version, changes
+	rc := &RedisConfig{Save: []string{}, AppendOnly: true, AppendFsync: "always"}
+	assert.NoError(t, rc.Validate())
+	_, err = writeRedisConf(dir, rc)
+	assert.NoError(t, err)
+	data, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	conf = string(data)
+	assert.Contains(t, conf, "\nsave \"\"\n")
+	assert.Contains(t, conf, "\nappendonly yes\nappendfilename \"faktory.aof\"\nappendfsync always\n")
+
+	assert.Error(t, (&RedisConfig{Save: []string{"60"}}).Validate())
+	assert.Error(t, (&RedisConfig{Save: []string{"60 x"}}).Validate())
+	assert.Error(t, (&RedisConfig{AppendFsync: "sometimes"}).Validate())
+	assert.NoError(t, (&RedisConfig{MaxMemory: "2GB"}).Validate())
+	assert.Error(t, (&RedisConfig{MaxMemory: "lots"}).Validate())
+}
+
+func TestRedisMaxMemory(t *testing.T) {
+	if os.Getenv("FAKTORY_TEST_DB") == "memory" {
+		t.Skip("FAKTORY_TEST_DB=memory")
+	}
+	t.Parallel()
+
+	dir := "/tmp/faktory-test-maxmemory"
+	defer os.RemoveAll(dir)
+	sock := fmt.Sprintf("%s/redis.sock", dir)
+	stopper, err := BootConfiguredRedis(dir, sock, &RedisConfig{MaxMemory: "1gb"})
+	if stopper != nil {
+		defer stopper()
+	}
+	if err != nil {
+		panic(err)
+	}
+	filename := filepath.Join(dir, "redis.conf")
+	conf, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nmaxmemory-policy noeviction\nmaxmemory 1gb\n")
+
+	store, err := OpenRedis(sock)
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+
+	// a new size is kept for restarts
+	assert.NoError(t, SetMaxMemory(store.(Redis), "2gb"))
+	conf, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "\nmaxmemory 2gb\n")
+	assert.NotContains(t, string(conf), "1gb")
+
+	assert.Error(t, SetMaxMemory(store.(Redis), "2 gb"))
+}
+
+func TestParseMemoryInfo(t *testing.T) {
+	used, max, err := parseMemoryInfo("# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\nmaxmemory:4194304\r\n")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1048576, used)
+	assert.EqualValues(t, 4194304, max)
+
+	_, _, err = parseMemoryInfo("# Memory\r\nused_memory:1048576\r\n")
+	assert.Error(t, err)
+	_, _, err = parseMemoryInfo("used_memory:lots\r\nmaxmemory:0\r\n")
+	assert.Error(t, err)
+}
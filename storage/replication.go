@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+/*
+ * RedisReplication pairs the redis-server booted by Faktory with the
+ * one on another Faktory box, so a replica Faktory can take over if the
+ * primary is lost.  Both sides listen for replication on TCP with the
+ * same password; the replica follows the primary:
+ *
+ *   primary: Listen "10.0.0.1:7421"
+ *   replica: Listen "10.0.0.2:7421", Primary "10.0.0.1:7421"
+ */
+type RedisReplication struct {
+	// Where this Redis listens for replication, host:port
+	Listen string
+	// The primary's Listen address, empty on the primary
+	Primary string
+	// Required by both sides, it also protects the local socket
+	Password string
+}
+
+func (rr *RedisReplication) Validate() error {
+	host, _, err := net.SplitHostPort(rr.Listen)
+	if err != nil || host == "" {
+		return fmt.Errorf("replication: invalid listen address %q, need host:port", rr.Listen)
+	}
+	if rr.Primary != "" {
+		_, _, err = net.SplitHostPort(rr.Primary)
+		if err != nil {
+			return fmt.Errorf("replication: invalid primary address %q: %w", rr.Primary, err)
+		}
+	}
+	if rr.Password == "" {
+		return errors.New("replication: a password is required")
+	}
+	return nil
+}
+
+// The file in the storage directory recording a promotion, it holds
+// the address of the primary the replica stopped following.
+const promotedFile = "promoted"
+
+// Promoted is true if the replica at path has been promoted since it
+// was set up to follow Primary, so its Redis must stay a primary when
+// it's restarted.  Following another primary starts afresh.
+func (rr *RedisReplication) Promoted(path string) bool {
+	if rr.Primary == "" {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(path, promotedFile))
+	return err == nil && string(bytes.TrimSpace(data)) == rr.Primary
+}
+
+// StopReplication makes a replica's Redis a primary which accepts
+// writes, replicas of it carry on following.  The booted Redis records
+// the promotion and drops replicaof from its redis.conf, so it doesn't
+// go back to following the old primary when it's restarted.
+func StopReplication(store Store) error {
+	rs, ok := store.(Redis)
+	if !ok {
+		return errors.New("replication requires redis")
+	}
+	err := rs.Redis().SlaveOf("NO", "ONE").Err()
+	if err != nil {
+		return err
+	}
+
+	sock := rs.Redis().Options().Addr
+	redisMutex.Lock()
+	defer redisMutex.Unlock()
+	conf, ok := configs[sock]
+	if !ok || conf.Replication == nil || conf.Replication.Primary == "" {
+		return nil
+	}
+	path := dirs[sock]
+	err = os.WriteFile(filepath.Join(path, promotedFile), []byte(conf.Replication.Primary+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("record promotion: %w", err)
+	}
+	_, err = writeRedisConf(path, conf)
+	return err
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/util"
+)
+
+// entries are ordered by score and then member, like a Redis ZSET.
+type embeddedSorted struct {
+	name    string
+	store   *embeddedStore
+	entries []zentry
+	scores  map[string]float64
+}
+
+type zentry struct {
+	score  float64
+	member []byte
+}
+
+func (ss *embeddedSorted) Name() string {
+	return ss.name
+}
+
+func (ss *embeddedSorted) Size() uint64 {
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	return uint64(len(ss.entries))
+}
+
+func (ss *embeddedSorted) Clear() error {
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	_, err := ss.store.write(&journalOp{Op: opZDel, Key: ss.name})
+	return err
+}
+
+func (ss *embeddedSorted) Add(job *client.Job) error {
+	if job.At == "" {
+		return errors.New("Job does not have an At timestamp")
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return ss.AddElement(job.At, job.Jid, data)
+}
+
+func (ss *embeddedSorted) AddElement(timestamp string, jid string, payload []byte) error {
+	tim, err := util.ParseTime(timestamp)
+	if err != nil {
+		return err
+	}
+	time_f := float64(tim.Unix()) + (float64(tim.Nanosecond()) / 1000000000)
+
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	_, err = ss.store.write(&journalOp{Op: opZAdd, Key: ss.name, Vals: [][]byte{payload}, Scores: []float64{time_f}})
+	return err
+}
+
+// search finds where the member belongs.
+func (ss *embeddedSorted) search(score float64, member []byte) int {
+	return sort.Search(len(ss.entries), func(idx int) bool {
+		e := ss.entries[idx]
+		return e.score > score || (e.score == score && bytes.Compare(e.member, member) >= 0)
+	})
+}
+
+func (ss *embeddedSorted) add(score float64, member []byte) {
+	ss.remove(member)
+	idx := ss.search(score, member)
+	ss.entries = append(ss.entries, zentry{})
+	copy(ss.entries[idx+1:], ss.entries[idx:])
+	ss.entries[idx] = zentry{score, member}
+	ss.scores[string(member)] = score
+}
+
+func (ss *embeddedSorted) remove(member []byte) bool {
+	score, ok := ss.scores[string(member)]
+	if !ok {
+		return false
+	}
+	idx := ss.search(score, member)
+	ss.entries = append(ss.entries[:idx], ss.entries[idx+1:]...)
+	delete(ss.scores, string(member))
+	return true
+}
+
+func (ss *embeddedSorted) clear() {
+	ss.entries = nil
+	ss.scores = map[string]float64{}
+}
+
+// find returns the member with the given score, picking by jid when
+// several jobs share it.  The caller must hold the lock.
+func (ss *embeddedSorted) find(score float64, jid string) []byte {
+	idx := sort.Search(len(ss.entries), func(idx int) bool {
+		return ss.entries[idx].score >= score
+	})
+	end := idx
+	for end < len(ss.entries) && ss.entries[end].score == score {
+		end++
+	}
+	if end-idx == 1 {
+		return ss.entries[idx].member
+	}
+	for ; idx < end; idx++ {
+		if strings.Index(string(ss.entries[idx].member), jid) > 0 {
+			return ss.entries[idx].member
+		}
+	}
+	return nil
+}
+
+// key is "timestamp|jid"
+func (ss *embeddedSorted) Get(key []byte) (SortedEntry, error) {
+	time_f, jid, err := decompose(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	member := ss.find(time_f, jid)
+	if member == nil {
+		return nil, nil
+	}
+	return NewEntry(time_f, member), nil
+}
+
+// Page visits entries start to start+count inclusive, like ZRANGE.
+func (ss *embeddedSorted) Page(start int, count int, fn func(index int, e SortedEntry) error) (int, error) {
+	ss.store.mu.Lock()
+	from, to, ok := rangeIndexes(int64(start), int64(start+count), int64(len(ss.entries)))
+	var page []zentry
+	if ok {
+		page = append(page, ss.entries[from:to+1]...)
+	}
+	ss.store.mu.Unlock()
+
+	for idx, e := range page {
+		err := fn(idx, NewEntry(e.score, e.member))
+		if err != nil {
+			return idx, err
+		}
+	}
+	return len(page), nil
+}
+
+func (ss *embeddedSorted) Each(fn func(idx int, e SortedEntry) error) error {
+	ss.store.mu.Lock()
+	entries := append([]zentry(nil), ss.entries...)
+	ss.store.mu.Unlock()
+
+	for idx, e := range entries {
+		err := fn(idx, NewEntry(e.score, e.member))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ss *embeddedSorted) rem(time_f float64, jid string) (bool, error) {
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	member := ss.find(time_f, jid)
+	if member == nil {
+		return false, nil
+	}
+	_, err := ss.store.write(&journalOp{Op: opZRem, Key: ss.name, Vals: [][]byte{member}})
+	return err == nil, err
+}
+
+func (ss *embeddedSorted) Remove(key []byte) (bool, error) {
+	time_f, jid, err := decompose(key)
+	if err != nil {
+		return false, err
+	}
+	return ss.rem(time_f, jid)
+}
+
+func (ss *embeddedSorted) RemoveElement(timestamp string, jid string) (bool, error) {
+	tim, err := util.ParseTime(timestamp)
+	if err != nil {
+		return false, err
+	}
+	time_f := float64(tim.Unix()) + (float64(tim.Nanosecond()) / 1000000000)
+	return ss.rem(time_f, jid)
+}
+
+func (ss *embeddedSorted) RemoveBefore(timestamp string) ([][]byte, error) {
+	tim, err := util.ParseTime(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	time_f := float64(tim.Unix()) + (float64(tim.Nanosecond()) / 1000000000)
+
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	results := [][]byte{}
+	for _, e := range ss.entries {
+		if e.score > time_f {
+			break
+		}
+		results = append(results, e.member)
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+	_, err = ss.store.write(&journalOp{Op: opZRem, Key: ss.name, Vals: results})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (ss *embeddedSorted) Reschedule(entry SortedEntry, newtime time.Time, key string, value []byte) (bool, error) {
+	job, err := entry.Job()
+	if err != nil {
+		return false, err
+	}
+	job.At = util.Thens(newtime)
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+	tim, err := util.ParseTime(job.At)
+	if err != nil {
+		return false, err
+	}
+	time_f := float64(tim.Unix()) + (float64(tim.Nanosecond()) / 1000000000)
+
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	if _, ok := ss.scores[string(entry.Value())]; !ok {
+		return false, nil
+	}
+	ops := []*journalOp{
+		{Op: opZAdd, Key: ss.name, Vals: [][]byte{payload}, Scores: []float64{time_f}},
+		{Op: opZRem, Key: ss.name, Vals: [][]byte{entry.Value()}},
+		{Op: opSet, Key: key, Vals: [][]byte{value}},
+	}
+	for _, op := range ops {
+		_, err = ss.store.write(op)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// MoveTo is atomic, the entry is never in both sets or neither.
+func (ss *embeddedSorted) MoveTo(sset SortedSet, entry SortedEntry, newtime time.Time) error {
+	target, ok := sset.(*embeddedSorted)
+	if !ok || target.store != ss.store {
+		return fmt.Errorf("cannot move %s entries to %s", ss.name, sset.Name())
+	}
+	job, err := entry.Job()
+	if err != nil {
+		return err
+	}
+
+	payload := entry.Value()
+	if target == ss && job.At != "" {
+		// rescheduling within the set, keep the job's own timestamp in step
+		job.At = util.Thens(newtime)
+		payload, err = json.Marshal(job)
+		if err != nil {
+			return err
+		}
+	}
+	// round trip through the timestamp format as AddElement does
+	tim, err := util.ParseTime(util.Thens(newtime))
+	if err != nil {
+		return err
+	}
+	time_f := float64(tim.Unix()) + (float64(tim.Nanosecond()) / 1000000000)
+
+	ss.store.mu.Lock()
+	defer ss.store.mu.Unlock()
+	if _, ok := ss.scores[string(entry.Value())]; !ok {
+		// race condition, element was removed or moved elsewhere
+		return nil
+	}
+	// add before removing so a crash between the two journal writes
+	// can't lose the job
+	_, err = ss.store.write(&journalOp{Op: opZAdd, Key: target.name, Vals: [][]byte{payload}, Scores: []float64{time_f}})
+	if err != nil || (target == ss && string(payload) == string(entry.Value())) {
+		return err
+	}
+	_, err = ss.store.write(&journalOp{Op: opZRem, Key: ss.name, Vals: [][]byte{entry.Value()}})
+	return err
+}
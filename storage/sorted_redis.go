@@ -15,14 +15,20 @@ import (
 
 type redisSorted struct {
 	name  string
+	key   string
 	store *redisStore
 }
 
 func (rs *redisStore) initSorted() {
-	rs.scheduled = &redisSorted{name: "scheduled", store: rs}
-	rs.retries = &redisSorted{name: "retries", store: rs}
-	rs.dead = &redisSorted{name: "dead", store: rs}
-	rs.working = &redisSorted{name: "working", store: rs}
+	rs.scheduled = rs.newSorted("scheduled")
+	rs.retries = rs.newSorted("retries")
+	rs.dead = rs.newSorted("dead")
+	rs.working = rs.newSorted("working")
+	rs.waiting = rs.newSorted("waiting")
+}
+
+func (rs *redisStore) newSorted(name string) *redisSorted {
+	return &redisSorted{name: name, key: rs.key(name), store: rs}
 }
 
 func (rs *redisSorted) Name() string {
@@ -30,11 +36,11 @@ func (rs *redisSorted) Name() string {
 }
 
 func (rs *redisSorted) Size() uint64 {
-	return uint64(rs.store.rclient.ZCard(rs.name).Val())
+	return uint64(rs.store.rclient.ZCard(rs.key).Val())
 }
 
 func (rs *redisSorted) Clear() error {
-	return rs.store.rclient.Del(rs.name).Err()
+	return rs.store.rclient.Del(rs.key).Err()
 }
 
 func (rs *redisSorted) Add(job *client.Job) error {
@@ -55,7 +61,7 @@ func (rs *redisSorted) AddElement(timestamp string, jid string, payload []byte)
 		return err
 	}
 	time_f := float64(tim.Unix()) + (float64(tim.Nanosecond()) / 1000000000)
-	_, err = rs.store.rclient.ZAdd(rs.name, redis.Z{Score: time_f, Member: payload}).Result()
+	_, err = rs.store.rclient.ZAdd(rs.key, redis.Z{Score: time_f, Member: payload}).Result()
 	return err
 }
 
@@ -75,7 +81,7 @@ func decompose(key []byte) (float64, string, error) {
 
 func (rs *redisSorted) getScore(score float64) ([]string, error) {
 	strf := strconv.FormatFloat(score, 'f', -1, 64)
-	elms, err := rs.store.rclient.ZRangeByScore(rs.name, redis.ZRangeBy{Min: strf, Max: strf}).Result()
+	elms, err := rs.store.rclient.ZRangeByScore(rs.key, redis.ZRangeBy{Min: strf, Max: strf}).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +165,7 @@ func (e *setEntry) Job() (*client.Job, error) {
 }
 
 func (rs *redisSorted) Page(start int, count int, fn func(index int, e SortedEntry) error) (int, error) {
-	zs, err := rs.store.rclient.ZRangeWithScores(rs.name, int64(start), int64(start+count)).Result()
+	zs, err := rs.store.rclient.ZRangeWithScores(rs.key, int64(start), int64(start+count)).Result()
 	if err != nil {
 		return 0, err
 	}
@@ -200,13 +206,13 @@ func (rs *redisSorted) rem(time_f float64, jid string) (bool, error) {
 		return false, nil
 	}
 	if len(elms) == 1 {
-		count, err := rs.store.rclient.ZRem(rs.name, elms[0]).Result()
+		count, err := rs.store.rclient.ZRem(rs.key, elms[0]).Result()
 		return count == 1, err
 	}
 
 	for _, elm := range elms {
 		if strings.Index(elm, jid) > 0 {
-			count, err := rs.store.rclient.ZRem(rs.name, elm).Result()
+			count, err := rs.store.rclient.ZRem(rs.key, elm).Result()
 			return count == 1, err
 		}
 	}
@@ -242,8 +248,8 @@ func (rs *redisSorted) RemoveBefore(timestamp string) ([][]byte, error) {
 
 	var vals *redis.StringSliceCmd
 	_, err = rs.store.rclient.TxPipelined(func(pipe redis.Pipeliner) error {
-		vals = pipe.ZRangeByScore(rs.name, redis.ZRangeBy{Min: "-inf", Max: strf})
-		pipe.ZRemRangeByScore(rs.name, "-inf", strf)
+		vals = pipe.ZRangeByScore(rs.key, redis.ZRangeBy{Min: "-inf", Max: strf})
+		pipe.ZRemRangeByScore(rs.key, "-inf", strf)
 		return nil
 	})
 	if err != nil {
@@ -264,13 +270,43 @@ func (rs *redisSorted) RemoveBefore(timestamp string) ([][]byte, error) {
 	return results, nil
 }
 
+// Reschedule claims the entry first so it can't race the scheduler,
+// the entry's new place and the KV value appear together.
+func (rs *redisSorted) Reschedule(entry SortedEntry, newtime time.Time, key string, value []byte) (bool, error) {
+	job, err := entry.Job()
+	if err != nil {
+		return false, err
+	}
+	job.At = util.Thens(newtime)
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+	tim, err := util.ParseTime(job.At)
+	if err != nil {
+		return false, err
+	}
+	time_f := float64(tim.Unix()) + (float64(tim.Nanosecond()) / 1000000000)
+
+	cnt, err := rs.store.rclient.ZRem(rs.key, string(entry.Value())).Result()
+	if err != nil || cnt == 0 {
+		return false, err
+	}
+	_, err = rs.store.rclient.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(rs.key, redis.Z{Score: time_f, Member: payload})
+		pipe.Set(rs.store.key(key), value, 0)
+		return nil
+	})
+	return err == nil, err
+}
+
 func (rs *redisSorted) MoveTo(sset SortedSet, entry SortedEntry, newtime time.Time) error {
 	job, err := entry.Job()
 	if err != nil {
 		return err
 	}
 
-	cnt, err := rs.store.rclient.ZRem(rs.name, string(entry.Value())).Result()
+	cnt, err := rs.store.rclient.ZRem(rs.key, string(entry.Value())).Result()
 	if err != nil {
 		return err
 	}
@@ -279,5 +315,14 @@ func (rs *redisSorted) MoveTo(sset SortedSet, entry SortedEntry, newtime time.Ti
 		return nil
 	}
 
-	return sset.AddElement(util.Thens(newtime), job.Jid, entry.Value())
+	payload := entry.Value()
+	if sset.Name() == rs.name && job.At != "" {
+		// rescheduling within the set, keep the job's own timestamp in step
+		job.At = util.Thens(newtime)
+		payload, err = json.Marshal(job)
+		if err != nil {
+			return err
+		}
+	}
+	return sset.AddElement(util.Thens(newtime), job.Jid, payload)
 }
@@ -41,7 +41,7 @@ func TestDecomposeKeyParsing(t *testing.T) {
 }
 
 func TestBasicSortedOps(t *testing.T) {
-	withRedis(t, "sorted", func(t *testing.T, store Store) {
+	withStores(t, "sorted", func(t *testing.T, store Store) {
 		t.Run("junk data", func(t *testing.T) {
 			sset := store.Retries()
 			assert.EqualValues(t, 0, sset.Size())
@@ -163,6 +163,36 @@ func TestBasicSortedOps(t *testing.T) {
 			entry, err := sset.Get(jkey)
 			assert.NoError(t, err)
 
+			// moving within the set reschedules the job
+			later := time.Now().Add(time.Hour)
+			err = sset.MoveTo(sset, entry, later)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, sset.Size())
+			entry, err = sset.Get([]byte(util.Thens(later) + "|" + job.Jid))
+			assert.NoError(t, err)
+			assert.NotNil(t, entry)
+			moved, err := entry.Job()
+			assert.NoError(t, err)
+			assert.Equal(t, util.Thens(later), moved.At)
+
+			// Reschedule also sets the key
+			latest := later.Add(time.Hour)
+			ok, err := sset.Reschedule(entry, latest, "where", []byte("there"))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			value, err := store.Raw().Get("where")
+			assert.NoError(t, err)
+			assert.Equal(t, "there", string(value))
+			ok, err = sset.Reschedule(entry, latest, "where", []byte("elsewhere"))
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			entry, err = sset.Get([]byte(util.Thens(latest) + "|" + job.Jid))
+			assert.NoError(t, err)
+			assert.NotNil(t, entry)
+			moved, err = entry.Job()
+			assert.NoError(t, err)
+			assert.Equal(t, util.Thens(latest), moved.At)
+
 			expiry := time.Now().Add(180 * 24 * time.Hour)
 
 			assert.EqualValues(t, 1, sset.Size())
@@ -3,17 +3,20 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/hunter-io/faktory/client"
 )
 
+// BackupInfo describes an archive on disk.  Id is the time the backup
+// was taken in milliseconds, FileCount the number of keys it holds.
 type BackupInfo struct {
-	Id        int64
-	FileCount int32
-	Size      int64
-	Timestamp int64
+	Id        int64 `json:"id"`
+	FileCount int32 `json:"file_count"`
+	Size      int64 `json:"size"`
+	Timestamp int64 `json:"timestamp"`
 }
 
 type Store interface {
@@ -22,26 +25,44 @@ type Store interface {
 	Scheduled() SortedSet
 	Working() SortedSet
 	Dead() SortedSet
+	// Jobs waiting for the jobs they depend on to complete
+	Waiting() SortedSet
 	GetQueue(string) (Queue, error)
 	EachQueue(func(Queue))
 	Stats() map[string]string
 	EnqueueAll(SortedSet) error
 	EnqueueFrom(SortedSet, []byte) error
+	// BPop blocks until a job is pushed to any of the named queues,
+	// returning nil if the context's deadline passes first.
+	BPop(ctx context.Context, queues ...string) ([]byte, error)
 
 	History(days int, fn func(day string, procCnt uint64, failCnt uint64)) error
 	Success() error
 	Failure() error
+	Canceled() error
 	TotalProcessed() uint64
 	TotalFailures() uint64
+	TotalCanceled() uint64
 
 	// Clear the database of all job data.
 	// Equivalent to Redis's FLUSHDB
 	Flush() error
 
+	// Backup writes a copy of all data, Restore loads one into an
+	// empty store.  Both return the number of keys copied.
+	Backup(w io.Writer) (int, error)
+	Restore(r io.Reader) (int, error)
+
+	// Export calls fn with every queued job, sorted set entry, counter
+	// and KV entry, Import writes one back.  See Record.
+	Export(fn func(*Record) error) error
+	Import(rec *Record) error
+
 	Raw() KV
-	Redis
 }
 
+// Redis is implemented by Redis-backed stores, giving plugins direct
+// access to the database.  Check for it with a type assertion.
 type Redis interface {
 	Redis() *redis.Client
 }
@@ -52,6 +73,11 @@ type Queue interface {
 
 	Add(job *client.Job) error
 	Push(priority uint8, data []byte) error
+	// PushAll enqueues many jobs in a single round trip
+	PushAll(data [][]byte) error
+	// PushAndSet enqueues the job and sets the KV key to value, which
+	// is removed after the TTL unless it's 0, in the same step.
+	PushAndSet(priority uint8, data []byte, key string, value []byte, ttl time.Duration) error
 
 	Pop() ([]byte, error)
 	BPop(context.Context) ([]byte, error)
@@ -61,6 +87,9 @@ type Queue interface {
 	Page(start int64, count int64, fn func(index int, data []byte) error) error
 
 	Delete(keys [][]byte) error
+	// Remove deletes one copy of the given job, false if it wasn't
+	// in the queue.
+	Remove(data []byte) (bool, error)
 }
 
 type SortedEntry interface {
@@ -89,15 +118,84 @@ type SortedSet interface {
 	RemoveBefore(timestamp string) ([][]byte, error)
 
 	// Move the given key from this SortedSet to the given
-	// SortedSet atomically.  The given func may mutate the payload and
-	// return a new tstamp.
+	// SortedSet atomically.  Moving within the same SortedSet
+	// reschedules the entry, updating the job's `at`.
 	MoveTo(sset SortedSet, entry SortedEntry, newtime time.Time) error
+	// Reschedule moves the entry to newtime within this SortedSet,
+	// updating the job's `at`, and sets the KV key to value in the
+	// same step.  False if the entry was no longer in the set.
+	Reschedule(entry SortedEntry, newtime time.Time, key string, value []byte) (bool, error)
 }
 
 func Open(dbtype string, path string) (Store, error) {
-	if dbtype == "redis" {
+	switch dbtype {
+	case "redis":
 		return OpenRedis(path)
-	} else {
+	case "embedded":
+		return OpenEmbedded(path)
+	case "memory":
+		return OpenMemory(path)
+	default:
 		return nil, fmt.Errorf("Invalid dbtype: %s", dbtype)
 	}
 }
+
+func enqueueAll(store Store, sset SortedSet) error {
+	return sset.Each(func(_ int, entry SortedEntry) error {
+		j, err := entry.Job()
+		if err != nil {
+			return err
+		}
+
+		k, err := entry.Key()
+		if err != nil {
+			return err
+		}
+
+		q, err := store.GetQueue(j.Queue)
+		if err != nil {
+			return err
+		}
+
+		ok, err := sset.Remove(k)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		return q.Add(j)
+	})
+}
+
+func enqueueFrom(store Store, sset SortedSet, key []byte) error {
+	entry, err := sset.Get(key)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		// race condition, element was removed already
+		return nil
+	}
+
+	job, err := entry.Job()
+	if err != nil {
+		return err
+	}
+
+	q, err := store.GetQueue(job.Queue)
+	if err != nil {
+		return err
+	}
+
+	ok, err := sset.Remove(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	return q.Add(job)
+}
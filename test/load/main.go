@@ -16,12 +16,16 @@ import (
 var (
 	jobs           = int64(30000)
 	threads        = int64(10)
+	batch          = int64(1)
 	opsCount []int = nil
 	queues         = []string{
 		"queue0", "queue1", "queue2", "queue3", "queue4",
 	}
 	pops   = int64(0)
 	pushes = int64(0)
+	// time taken for all pushes, to compare PUSH and PUSHB
+	pushNanos = int64(0)
+	start     time.Time
 )
 
 func main() {
@@ -51,7 +55,19 @@ func main() {
 		seed = aseed
 	}
 
+	// push jobs in batches of this size with PUSHB, 1 uses PUSH
+	if argc > 4 {
+		abatch, err := strconv.ParseInt(os.Args[4], 10, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		batch = abatch
+	}
+
 	fmt.Printf("Running loadtest with %d jobs and %d threads\n", jobs, threads)
+	if batch > 1 {
+		fmt.Printf("Pushing in batches of %d with PUSHB\n", batch)
+	}
 
 	client, err := faktory.Open()
 	if err != nil {
@@ -67,7 +83,7 @@ func main() {
 }
 
 func run() {
-	start := time.Now()
+	start = time.Now()
 	var waiter sync.WaitGroup
 	for i := int64(0); i < threads; i++ {
 		waiter.Add(1)
@@ -80,6 +96,8 @@ func run() {
 	waiter.Wait()
 	stop := time.Since(start)
 	fmt.Printf("Processed %d pushes and %d pops in %2f seconds, rate: %f jobs/s\n", pushes, pops, stop.Seconds(), float64(jobs)/stop.Seconds())
+	pushTime := time.Duration(atomic.LoadInt64(&pushNanos))
+	fmt.Printf("Pushes took %2f seconds, rate: %f jobs/s\n", pushTime.Seconds(), float64(pushes)/pushTime.Seconds())
 	//fmt.Println(opsCount)
 }
 
@@ -97,9 +115,14 @@ func stress(idx int64) {
 
 	for {
 		if idx%2 == 0 {
-			push(client, randomQueue())
-			newp := atomic.AddInt64(&pushes, 1)
+			if batch > 1 {
+				pushBulk(client, batch)
+			} else {
+				push(client, randomQueue())
+			}
+			newp := atomic.AddInt64(&pushes, batch)
 			if newp >= jobs {
+				atomic.StoreInt64(&pushNanos, int64(time.Since(start)))
 				return
 			}
 		} else {
@@ -138,16 +161,35 @@ func pop(client *faktory.Client, queues []string) {
 }
 
 func push(client *faktory.Client, queue string) {
-	j := faktory.NewJob("SomeJob", []interface{}{1, "string", 3})
-	j.Priority = uint8(rand.Intn(9) + 1)
-	j.Queue = queue
-	err := client.Push(j)
+	err := client.Push(newJob(queue))
 	if err != nil {
 		handleError(err)
 		return
 	}
 }
 
+func pushBulk(client *faktory.Client, count int64) {
+	jobs := make([]*faktory.Job, count)
+	for i := range jobs {
+		jobs[i] = newJob(randomQueue())
+	}
+	rejected, err := client.PushBulk(jobs)
+	if err != nil {
+		handleError(err)
+		return
+	}
+	for idx, msg := range rejected {
+		fmt.Printf("%s: %s\n", jobs[idx].Jid, msg)
+	}
+}
+
+func newJob(queue string) *faktory.Job {
+	j := faktory.NewJob("SomeJob", []interface{}{1, "string", 3})
+	j.Priority = uint8(rand.Intn(9) + 1)
+	j.Queue = queue
+	return j
+}
+
 func handleError(err error) {
 	fmt.Println(err.Error())
 }
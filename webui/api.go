@@ -0,0 +1,238 @@
+package webui
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hunter-io/faktory/client"
+	"github.com/hunter-io/faktory/server"
+	"github.com/hunter-io/faktory/util"
+)
+
+/*
+ * The JSON API lets producers which can't speak the command protocol
+ * (shell scripts, serverless functions, etc) push jobs and query
+ * Faktory over plain HTTP:
+ *
+ *   POST /api/jobs        push a job, same payload as PUSH
+ *   GET  /api/jobs/<jid>  find a job by JID
+ *   GET  /api/info        same data as INFO
+ *
+ * Requests authenticate with a Bearer token, the command server's
+ * password.  Additional tokens can be issued so machines don't need to
+ * share the password:
+ *
+ *   [web]
+ *   api_tokens = ["a1b2c3", "d4e5f6"]
+ *
+ * Tenants give "<name>:<password>" as the token, their requests are
+ * scoped to the tenant's namespace.  Basic auth isn't accepted: the
+ * browser would send the Web UI's credentials along with any request
+ * another site makes, and neither is a body which isn't
+ * application/json, which such a site could POST as a form.
+ */
+
+// apiError is the body returned for any failed API request:
+//
+//	{"error":{"code":"MALFORMED","message":"unexpected end of JSON input"}}
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Limit request bodies so a runaway client can't exhaust our memory.
+const maxAPIBodySize = 16 * 1024 * 1024
+
+func API(ui *WebUI, pass http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		tenant, ok := apiTenant(ui, r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Faktory"`)
+			apiFail(w, http.StatusUnauthorized, "UNAUTHORIZED", errors.New("Authorization required"))
+			return
+		}
+		if readOnly(ui, r) {
+			apiFail(w, http.StatusForbidden, "REPLICA", errors.New("Read-only replica"))
+			return
+		}
+
+		dctx := &DefaultContext{
+			Context:  r.Context(),
+			webui:    ui,
+			tenant:   tenant,
+			response: w,
+			request:  r,
+			locale:   "en",
+			strings:  translations("en"),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		pass(w, r.WithContext(dctx))
+		util.Infof("%s %s %v", r.Method, r.RequestURI, time.Since(start))
+	}
+}
+
+// apiTenant finds the tenant making the request, the tokens are for
+// the root namespace.
+func apiTenant(ui *WebUI, r *http.Request) (*server.Tenant, bool) {
+	root := ui.Server.Tenant("")
+	tokens := apiTokens(ui)
+	if len(tokens) == 0 {
+		// no password and no tokens, the API is open just like the command port
+		return root, true
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, false
+	}
+	given := strings.TrimSpace(auth[7:])
+	if given == "" {
+		return nil, false
+	}
+
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return root, true
+		}
+	}
+	user, pwd, ok := strings.Cut(given, ":")
+	if tenant := ui.Server.Tenant(user); ok && user != "" && tenant != nil && tenant.Authenticate(pwd) {
+		return tenant, true
+	}
+	return nil, false
+}
+
+// Read at request time so tokens pick up config reloads.
+func apiTokens(ui *WebUI) []string {
+	tokens := []string{}
+	if pwd := ui.Server.Options.Password; pwd != "" {
+		tokens = append(tokens, pwd)
+	}
+
+	val := ui.Server.Options.Config("web", "api_tokens", nil)
+	if val == nil {
+		return tokens
+	}
+	list, ok := val.([]any)
+	if !ok {
+		util.Warnf("Config error: web/api_tokens is not an Array")
+		return tokens
+	}
+	for _, elm := range list {
+		if str, ok := elm.(string); ok && str != "" {
+			tokens = append(tokens, str)
+		}
+	}
+	return tokens
+}
+
+func apiFail(w http.ResponseWriter, status int, code string, err error) {
+	data, _ := json.Marshal(map[string]apiError{
+		"error": {Code: code, Message: err.Error()},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// apiFailWith fails with the same code the command port would give err,
+// and the status which goes with it or the given one for plain ERRs.
+func apiFailWith(w http.ResponseWriter, err error, status int) {
+	code := server.ErrorCode(err)
+	switch code {
+	case "INVALID":
+		status = http.StatusUnprocessableEntity
+	case "FULL":
+		status = http.StatusTooManyRequests
+	case "OOM", "UNAVAILABLE":
+		status = http.StatusServiceUnavailable
+	}
+	apiFail(w, status, code, err)
+}
+
+func apiRespond(w http.ResponseWriter, status int, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, "ERR", err)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func apiJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apiFail(w, http.StatusMethodNotAllowed, "ERR", errors.New("post only"))
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		apiFail(w, http.StatusUnsupportedMediaType, "MALFORMED", errors.New("Content-Type must be application/json"))
+		return
+	}
+
+	var job client.Job
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize))
+	err := dec.Decode(&job)
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, "MALFORMED", err)
+		return
+	}
+
+	err = ctx(r).Manager().Push(&job)
+	if err != nil {
+		apiFailWith(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	apiRespond(w, http.StatusCreated, map[string]string{"jid": job.Jid})
+}
+
+func apiJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiFail(w, http.StatusMethodNotAllowed, "ERR", errors.New("get only"))
+		return
+	}
+
+	jid := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	if jid == "" || strings.Contains(jid, "/") {
+		apiFail(w, http.StatusBadRequest, "MALFORMED", errors.New("Invalid JID"))
+		return
+	}
+
+	status, err := ctx(r).Manager().Lookup(jid)
+	if err != nil {
+		apiFailWith(w, err, http.StatusInternalServerError)
+		return
+	}
+	if status == nil {
+		apiFail(w, http.StatusNotFound, "NOTFOUND", fmt.Errorf("Job %s not found", jid))
+		return
+	}
+
+	apiRespond(w, http.StatusOK, status)
+}
+
+func apiInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiFail(w, http.StatusMethodNotAllowed, "ERR", errors.New("get only"))
+		return
+	}
+
+	hash, err := ctx(r).Server().TenantState(ctx(r).Tenant())
+	if err != nil {
+		apiFailWith(w, err, http.StatusInternalServerError)
+		return
+	}
+	apiRespond(w, http.StatusOK, hash)
+}
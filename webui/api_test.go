@@ -0,0 +1,157 @@
+package webui
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hunter-io/faktory/manager"
+	"github.com/hunter-io/faktory/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI(t *testing.T) {
+	bootRuntime(t, "api", func(ui *WebUI, s *server.Server, t *testing.T) {
+
+		t.Run("PushAndFind", func(t *testing.T) {
+			s.Store().Flush()
+
+			body := `{"jid":"apijob1234567","jobtype":"ApiJob","args":[1,"two"]}`
+			req := httptest.NewRequest("POST", "http://localhost:7420/api/jobs", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 201, w.Code, w.Body.String())
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), "apijob1234567")
+
+			q, err := s.Store().GetQueue("default")
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, q.Size())
+
+			req = httptest.NewRequest("GET", "http://localhost:7420/api/jobs/apijob1234567", nil)
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code, w.Body.String())
+
+			var result map[string]any
+			err = json.Unmarshal(w.Body.Bytes(), &result)
+			assert.NoError(t, err)
+			assert.Equal(t, "enqueued", result["state"])
+			assert.Equal(t, "ApiJob", result["job"].(map[string]any)["jobtype"])
+
+			req = httptest.NewRequest("GET", "http://localhost:7420/api/jobs/nosuchjob123", nil)
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 404, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"NOTFOUND"`)
+		})
+
+		t.Run("Errors", func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://localhost:7420/api/jobs", strings.NewReader("{bad json"))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 400, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"MALFORMED"`)
+
+			req = httptest.NewRequest("POST", "http://localhost:7420/api/jobs", strings.NewReader(`{"jid":"short","jobtype":"x","args":[]}`))
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 422, w.Code)
+			assert.Contains(t, w.Body.String(), "reasonable jid")
+			assert.Contains(t, w.Body.String(), `"code":"ERR"`)
+
+			req = httptest.NewRequest("GET", "http://localhost:7420/api/jobs", nil)
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 405, w.Code)
+
+			// as a cross-site form would post it
+			body := `{"jid":"formjob12345","jobtype":"x","args":[]}`
+			req = httptest.NewRequest("POST", "http://localhost:7420/api/jobs", strings.NewReader(body))
+			req.Header.Set("Content-Type", "text/plain")
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 415, w.Code)
+			status, err := s.Manager().Lookup("formjob12345")
+			assert.NoError(t, err)
+			assert.Nil(t, status)
+		})
+
+		t.Run("Full", func(t *testing.T) {
+			s.Store().Flush()
+			s.Manager().SetQueueOptions(manager.QueueOptions{Backpressure: 1}, nil)
+			defer s.Reload()
+
+			push := func(jid string) *httptest.ResponseRecorder {
+				body := `{"jid":"` + jid + `","jobtype":"ApiJob","args":[]}`
+				req := httptest.NewRequest("POST", "http://localhost:7420/api/jobs", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				ui.Mux.ServeHTTP(w, req)
+				return w
+			}
+			assert.Equal(t, 201, push("fulljob12345").Code)
+			w := push("fulljob67890")
+			assert.Equal(t, 429, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), `"code":"FULL"`)
+		})
+
+		t.Run("Info", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:7420/api/info", nil)
+			w := httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+
+			var result map[string]any
+			err := json.Unmarshal(w.Body.Bytes(), &result)
+			assert.NoError(t, err)
+			assert.NotNil(t, result["faktory"])
+		})
+
+		t.Run("Authentication", func(t *testing.T) {
+			s.Options.Password = "sekrit"
+			s.Options.GlobalConfig = map[string]any{
+				"web": map[string]any{"api_tokens": []any{"tok123"}},
+			}
+			defer func() {
+				s.Options.Password = ""
+				s.Options.GlobalConfig = nil
+			}()
+
+			req := httptest.NewRequest("GET", "http://localhost:7420/api/info", nil)
+			w := httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 401, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"UNAUTHORIZED"`)
+
+			req = httptest.NewRequest("GET", "http://localhost:7420/api/info", nil)
+			req.Header.Set("Authorization", "Bearer wrong")
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 401, w.Code)
+
+			req = httptest.NewRequest("GET", "http://localhost:7420/api/info", nil)
+			req.Header.Set("Authorization", "Bearer tok123")
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+
+			req = httptest.NewRequest("GET", "http://localhost:7420/api/info", nil)
+			req.Header.Set("Authorization", "Bearer sekrit")
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+
+			// the browser's Web UI credentials aren't enough
+			req = httptest.NewRequest("GET", "http://localhost:7420/api/info", nil)
+			req.SetBasicAuth("", "sekrit")
+			w = httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			assert.Equal(t, 401, w.Code)
+		})
+	})
+}
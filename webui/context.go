@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/hunter-io/faktory/manager"
 	"github.com/hunter-io/faktory/server"
 	"github.com/hunter-io/faktory/storage"
 )
@@ -19,6 +20,7 @@ type DefaultContext struct {
 	context.Context
 
 	webui    *WebUI
+	tenant   *server.Tenant
 	response http.ResponseWriter
 	request  *http.Request
 	locale   string
@@ -38,8 +40,17 @@ func (d *DefaultContext) UseCsrf() bool {
 	return d.csrf
 }
 
+// Store and Manager are scoped to the logged in tenant.
 func (d *DefaultContext) Store() storage.Store {
-	return d.webui.Server.Store()
+	return d.tenant.Store()
+}
+
+func (d *DefaultContext) Manager() manager.Manager {
+	return d.tenant.Manager()
+}
+
+func (d *DefaultContext) Tenant() *server.Tenant {
+	return d.tenant
 }
 
 func (d *DefaultContext) Server() *server.Server {
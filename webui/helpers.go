@@ -53,7 +53,7 @@ func pageparam(req *http.Request, pageValue uint64) string {
 }
 
 func currentStatus(req *http.Request) string {
-	if ctx(req).Server().Manager().WorkingCount() == 0 {
+	if ctx(req).Manager().WorkingCount() == 0 {
 		return "idle"
 	}
 	return "active"
@@ -139,6 +139,26 @@ func relativeTime(moment string) string {
 	return Timeago(tm)
 }
 
+// Browsers submit datetime-local inputs without a zone, the
+// Web UI shows and accepts them in UTC.
+const formTimeLayout = "2006-01-02T15:04"
+
+func formTime(moment string) string {
+	tm, err := util.ParseTime(moment)
+	if err != nil {
+		return ""
+	}
+	return tm.UTC().Format(formTimeLayout)
+}
+
+func parseFormTime(value string) (time.Time, error) {
+	tm, err := util.ParseTime(value)
+	if err == nil {
+		return tm, nil
+	}
+	return time.ParseInLocation(formTimeLayout, value, time.UTC)
+}
+
 func unfiltered() bool {
 	return true
 }
@@ -184,56 +204,72 @@ func busyReservations(req *http.Request, fn func(worker *manager.Reservation)) {
 
 func busyWorkers(req *http.Request, fn func(proc *server.ClientData)) {
 	for _, worker := range ctx(req).Server().Heartbeats() {
-		fn(worker)
+		if worker.Tenant() == ctx(req).Tenant().Name {
+			fn(worker)
+		}
 	}
 }
 
-func actOn(req *http.Request, set storage.SortedSet, action string, keys []string) error {
-	switch action {
-	case "delete":
-		if len(keys) == 1 && keys[0] == "all" {
-			return set.Clear()
-		} else {
-			for _, key := range keys {
-				_, err := set.Remove([]byte(key))
-				// ok doesn't really matter
-				if err != nil {
-					return err
-				}
-			}
-			return nil
+// TenantSummary is a row of the admin's Tenants page.
+type TenantSummary struct {
+	Name      string
+	Processed uint64
+	Failures  uint64
+	Enqueued  uint64
+	Busy      int
+	Processes int
+	Retries   uint64
+	Scheduled uint64
+	Dead      uint64
+}
+
+func tenantSummaries(req *http.Request) []TenantSummary {
+	srv := ctx(req).Server()
+	summaries := []TenantSummary{}
+	for _, tenant := range srv.Tenants() {
+		store := tenant.Store()
+		summary := TenantSummary{
+			Name:      tenant.Name,
+			Processed: store.TotalProcessed(),
+			Failures:  store.TotalFailures(),
+			Busy:      tenant.Manager().WorkingCount(),
+			Retries:   store.Retries().Size(),
+			Scheduled: store.Scheduled().Size(),
+			Dead:      store.Dead().Size(),
 		}
-	case "retry":
-		if len(keys) == 1 && keys[0] == "all" {
-			return ctx(req).Store().EnqueueAll(set)
-		} else {
-			for _, key := range keys {
-				err := ctx(req).Store().EnqueueFrom(set, []byte(key))
-				if err != nil {
-					return err
-				}
+		store.EachQueue(func(q storage.Queue) {
+			summary.Enqueued += q.Size()
+		})
+		for _, worker := range srv.Heartbeats() {
+			if worker.Tenant() == tenant.Name {
+				summary.Processes++
 			}
-			return nil
 		}
-	case "kill":
-		if len(keys) == 1 && keys[0] == "all" {
-			return ctx(req).Store().EnqueueAll(set)
-		} else {
-			expiry := time.Now().Add(180 * 24 * time.Hour)
-			for _, key := range keys {
-				entry, err := set.Get([]byte(key))
-				if err != nil {
-					return err
-				}
-				if entry != nil {
-					err = set.MoveTo(ctx(req).Store().Dead(), entry, expiry)
-					if err != nil {
-						return err
-					}
-				}
-			}
-			return nil
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func actOn(req *http.Request, set storage.SortedSet, action string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	// the manager acts on every entry when given no keys
+	var bkeys [][]byte
+	if !(len(keys) == 1 && keys[0] == "all") {
+		for _, key := range keys {
+			bkeys = append(bkeys, []byte(key))
 		}
+	}
+
+	mgr := ctx(req).Manager()
+	switch action {
+	case "delete":
+		return mgr.RemoveJobs(set, bkeys)
+	case "retry", "add_to_queue":
+		return mgr.EnqueueJobs(set, bkeys)
+	case "kill":
+		return mgr.KillJobs(set, bkeys)
 	default:
 		return fmt.Errorf("invalid action: %v", action)
 	}
@@ -244,13 +280,27 @@ func uptimeInDays(req *http.Request) string {
 }
 
 func redis_info(req *http.Request) string {
-	client := ctx(req).Store().(storage.Redis)
+	client, ok := ctx(req).Store().(storage.Redis)
+	if !ok {
+		return ctx(req).Store().Stats()["stats"]
+	}
 	val, err := client.Redis().Info().Result()
 	if err != nil {
 		return fmt.Sprintf("%v", err)
 	}
 	return val
 }
+func backups(req *http.Request, fn func(info storage.BackupInfo)) error {
+	infos, err := ctx(req).Server().Backups()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		fn(info)
+	}
+	return nil
+}
+
 func rss() string {
 	ex, err := util.FileExists("/proc/self/status")
 	if err != nil || !ex {
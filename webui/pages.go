@@ -8,12 +8,15 @@ import (
 	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hunter-io/faktory/server"
+	"github.com/hunter-io/faktory/util"
 )
 
 func statsHandler(w http.ResponseWriter, r *http.Request) {
-	hash, err := ctx(r).Server().CurrentState()
+	hash, err := ctx(r).Server().TenantState(ctx(r).Tenant())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -73,14 +76,14 @@ func queueHandler(w http.ResponseWriter, r *http.Request) {
 				}
 				bkeys[idx] = bindata
 			}
-			err := q.Delete(bkeys)
+			err := ctx(r).Manager().RemoveQueued(q, bkeys)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		} else {
 			// clear entire queue
-			_, err := q.Clear()
+			err := ctx(r).Manager().RemoveQueued(q, nil)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -215,7 +218,23 @@ func scheduledJobHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := ctx(r).Store().Scheduled().Get([]byte(key))
+	set := ctx(r).Store().Scheduled()
+	if r.Method == "POST" {
+		action := r.FormValue("action")
+		if action == "reschedule" {
+			rescheduleJob(w, r, key)
+			return
+		}
+		err := actOn(r, set, action, []string{key})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			http.Redirect(w, r, "/scheduled", http.StatusFound)
+		}
+		return
+	}
+
+	data, err := set.Get([]byte(key))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -236,6 +255,31 @@ func scheduledJobHandler(w http.ResponseWriter, r *http.Request) {
 	ego_scheduled_job(w, r, key, job)
 }
 
+func rescheduleJob(w http.ResponseWriter, r *http.Request, key string) {
+	_, jid, found := strings.Cut(key, "|")
+	if !found {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	at, err := parseFormTime(r.FormValue("at"))
+	if err != nil {
+		http.Error(w, "Invalid time", http.StatusBadRequest)
+		return
+	}
+
+	err = ctx(r).Manager().Reschedule(jid, at)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !at.After(time.Now()) {
+		// enqueued, nothing left to show
+		http.Redirect(w, r, "/scheduled", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/scheduled/"+url.QueryEscape(util.Thens(at)+"|"+jid), http.StatusFound)
+}
+
 func morgueHandler(w http.ResponseWriter, r *http.Request) {
 	set := ctx(r).Store().Dead()
 
@@ -298,6 +342,63 @@ func deadHandler(w http.ResponseWriter, r *http.Request) {
 	ego_dead(w, r, key, job)
 }
 
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	jid := strings.TrimSpace(r.FormValue("jid"))
+	if jid == "" {
+		http.Redirect(w, r, "/busy", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/jobs/"+url.PathEscape(jid), http.StatusFound)
+}
+
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	name := LAST_ELEMENT.FindStringSubmatch(r.RequestURI)
+	if name == nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	jid, err := url.PathUnescape(name[1])
+	if err != nil {
+		http.Error(w, "Invalid URL input", http.StatusBadRequest)
+		return
+	}
+
+	status, err := ctx(r).Manager().Lookup(jid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status == nil {
+		http.Error(w, fmt.Sprintf("Job %s not found", jid), http.StatusNotFound)
+		return
+	}
+	ego_job(w, r, status)
+}
+
+func chainHandler(w http.ResponseWriter, r *http.Request) {
+	name := LAST_ELEMENT.FindStringSubmatch(r.RequestURI)
+	if name == nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	id, err := url.PathUnescape(name[1])
+	if err != nil {
+		http.Error(w, "Invalid URL input", http.StatusBadRequest)
+		return
+	}
+
+	steps, err := ctx(r).Manager().Chain(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if steps == nil {
+		http.Error(w, fmt.Sprintf("Chain %s not found", id), http.StatusNotFound)
+		return
+	}
+	ego_chain(w, r, id, steps)
+}
+
 func busyHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		wid := r.FormValue("wid")
@@ -314,6 +415,9 @@ func busyHandler(w http.ResponseWriter, r *http.Request) {
 			}
 
 			for _, client := range ctx(r).Server().Heartbeats() {
+				if client.Tenant() != ctx(r).Tenant().Name {
+					continue
+				}
 				if wid == "all" || wid == client.Wid {
 					client.Signal(signal)
 				}
@@ -326,5 +430,19 @@ func busyHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func debugHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		_, err := ctx(r).Server().Backup()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			http.Redirect(w, r, "/debug", http.StatusFound)
+		}
+		return
+	}
+
 	ego_debug(w, r)
 }
+
+func tenantsHandler(w http.ResponseWriter, r *http.Request) {
+	ego_tenants(w, r)
+}
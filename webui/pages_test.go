@@ -1,6 +1,7 @@
 package webui
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hunter-io/faktory/client"
 	"github.com/hunter-io/faktory/server"
 	"github.com/hunter-io/faktory/storage"
 	"github.com/hunter-io/faktory/util"
@@ -268,10 +270,13 @@ func TestPages(t *testing.T) {
 			str := s.Store()
 			q := str.Scheduled()
 			q.Clear()
-			jid, data := fakeJob()
+			// pushed so the manager can find it to reschedule
+			job := client.NewJob("SomeWorker", 1, 2, 3)
 			ts := util.Thens(time.Now().Add(1e6 * time.Second))
+			job.At = ts
+			jid := job.Jid
 
-			err := q.AddElement(ts, jid, data)
+			err := s.Manager().Push(job)
 			assert.NoError(t, err)
 
 			req, err := ui.NewRequest("GET", fmt.Sprintf("http://localhost:7420/scheduled/%s|%s", ts, jid), nil)
@@ -280,6 +285,31 @@ func TestPages(t *testing.T) {
 			scheduledJobHandler(w, req)
 			assert.Equal(t, 200, w.Code)
 			assert.True(t, strings.Contains(w.Body.String(), jid), w.Body.String())
+
+			later := time.Now().Add(2e6 * time.Second).UTC().Truncate(time.Minute)
+			payload := url.Values{
+				"at":     {later.Format("2006-01-02T15:04")},
+				"action": {"reschedule"},
+			}
+			req, err = ui.NewRequest("POST", fmt.Sprintf("http://localhost:7420/scheduled/%s|%s", ts, jid), strings.NewReader(payload.Encode()))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w = httptest.NewRecorder()
+			scheduledJobHandler(w, req)
+			assert.Equal(t, 302, w.Code, w.Body.String())
+			assert.EqualValues(t, 1, q.Size())
+			entry, err := q.Get([]byte(util.Thens(later) + "|" + jid))
+			assert.NoError(t, err)
+			assert.NotNil(t, entry)
+
+			payload = url.Values{"action": {"add_to_queue"}}
+			req, err = ui.NewRequest("POST", fmt.Sprintf("http://localhost:7420/scheduled/%s|%s", util.Thens(later), jid), strings.NewReader(payload.Encode()))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w = httptest.NewRecorder()
+			scheduledJobHandler(w, req)
+			assert.Equal(t, 302, w.Code, w.Body.String())
+			assert.EqualValues(t, 0, q.Size())
 		})
 
 		t.Run("Morgue", func(t *testing.T) {
@@ -369,6 +399,76 @@ func TestPages(t *testing.T) {
 			assert.True(t, wrk.IsQuiet())
 		})
 
+		t.Run("Job", func(t *testing.T) {
+			job := client.NewJob("SomeWorker", 1, 2, 3)
+			job.Queue = "results"
+			err := s.Manager().Push(job)
+			assert.NoError(t, err)
+
+			req, err := ui.NewRequest("GET", "http://localhost:7420/jobs/"+job.Jid, nil)
+			assert.NoError(t, err)
+			w := httptest.NewRecorder()
+			jobHandler(w, req)
+			assert.Equal(t, 200, w.Code)
+			assert.True(t, strings.Contains(w.Body.String(), "enqueued"), w.Body.String())
+			assert.True(t, strings.Contains(w.Body.String(), "SomeWorker"), w.Body.String())
+
+			_, err = s.Manager().Fetch(context.Background(), "workerId", "results")
+			assert.NoError(t, err)
+			_, err = s.Manager().AcknowledgeResult(job.Jid, []byte(`{"answer":42}`), time.Minute)
+			assert.NoError(t, err)
+
+			w = httptest.NewRecorder()
+			jobHandler(w, req)
+			assert.Equal(t, 200, w.Code)
+			assert.True(t, strings.Contains(w.Body.String(), "completed"), w.Body.String())
+			assert.True(t, strings.Contains(w.Body.String(), "answer"), w.Body.String())
+
+			req, err = ui.NewRequest("GET", "http://localhost:7420/jobs/nosuchjob", nil)
+			assert.NoError(t, err)
+			w = httptest.NewRecorder()
+			jobHandler(w, req)
+			assert.Equal(t, 404, w.Code)
+
+			req, err = ui.NewRequest("GET", "http://localhost:7420/jobs?jid="+job.Jid, nil)
+			assert.NoError(t, err)
+			w = httptest.NewRecorder()
+			jobsHandler(w, req)
+			assert.Equal(t, 302, w.Code)
+			assert.Equal(t, "/jobs/"+job.Jid, w.Header().Get("Location"))
+		})
+
+		t.Run("Chain", func(t *testing.T) {
+			job := client.NewJob("FetchWorker", 1)
+			job.Queue = "chains"
+			job.Chain = []*client.Job{client.NewJob("UploadWorker", 2)}
+			err := s.Manager().Push(job)
+			assert.NoError(t, err)
+
+			req, err := ui.NewRequest("GET", "http://localhost:7420/chains/"+job.Jid, nil)
+			assert.NoError(t, err)
+			w := httptest.NewRecorder()
+			chainHandler(w, req)
+			assert.Equal(t, 200, w.Code)
+			assert.True(t, strings.Contains(w.Body.String(), "FetchWorker"), w.Body.String())
+			assert.True(t, strings.Contains(w.Body.String(), "UploadWorker"), w.Body.String())
+			assert.True(t, strings.Contains(w.Body.String(), "pending"), w.Body.String())
+
+			// the chain is linked from each job
+			req, err = ui.NewRequest("GET", "http://localhost:7420/jobs/"+job.Jid, nil)
+			assert.NoError(t, err)
+			w = httptest.NewRecorder()
+			jobHandler(w, req)
+			assert.Equal(t, 200, w.Code)
+			assert.True(t, strings.Contains(w.Body.String(), "/chains/"+job.Jid), w.Body.String())
+
+			req, err = ui.NewRequest("GET", "http://localhost:7420/chains/nosuchchain", nil)
+			assert.NoError(t, err)
+			w = httptest.NewRecorder()
+			chainHandler(w, req)
+			assert.Equal(t, 404, w.Code)
+		})
+
 		t.Run("RequireCSRF", func(t *testing.T) {
 			req, err := ui.NewRequest("GET", "http://localhost:7420/busy", nil)
 			assert.NoError(t, err)
@@ -487,6 +587,7 @@ func (ui *WebUI) NewRequest(method string, url string, body io.Reader) (*http.Re
 	dctx := &DefaultContext{
 		Context: r.Context(),
 		webui:   ui,
+		tenant:  ui.Server.Tenant(""),
 		request: r,
 		locale:  "en",
 		strings: translations("en"),
@@ -225,6 +225,7 @@ var updateStatsSummary = function(data) {
 
   $('ul.summary li.processed span.count').html(data.total_processed.numberWithDelimiter())
   $('ul.summary li.failed span.count').html(data.total_failures.numberWithDelimiter())
+  $('ul.summary li.canceled span.count').html(data.total_canceled.numberWithDelimiter())
   $('ul.summary li.busy span.count').html(data.tasks.Busy.size.numberWithDelimiter())
   $('ul.summary li.scheduled span.count').html(data.tasks.Scheduled.size.numberWithDelimiter())
   $('ul.summary li.retries span.count').html(data.tasks.Retries.size.numberWithDelimiter())
@@ -112,7 +112,15 @@ func newWeb(s *server.Server, opts Options) *WebUI {
 	ui.Mux.HandleFunc("/morgue", Log(ui, morgueHandler))
 	ui.Mux.HandleFunc("/morgue/", Log(ui, deadHandler))
 	ui.Mux.HandleFunc("/busy", Log(ui, busyHandler))
-	ui.Mux.HandleFunc("/debug", Log(ui, debugHandler))
+	ui.Mux.HandleFunc("/jobs", Log(ui, GetOnly(jobsHandler)))
+	ui.Mux.HandleFunc("/jobs/", Log(ui, GetOnly(jobHandler)))
+	ui.Mux.HandleFunc("/chains/", Log(ui, GetOnly(chainHandler)))
+	ui.Mux.HandleFunc("/debug", Log(ui, RootOnly(debugHandler)))
+	ui.Mux.HandleFunc("/tenants", Log(ui, RootOnly(GetOnly(tenantsHandler))))
+
+	ui.Mux.HandleFunc("/api/jobs", API(ui, apiJobsHandler))
+	ui.Mux.HandleFunc("/api/jobs/", API(ui, apiJobHandler))
+	ui.Mux.HandleFunc("/api/info", API(ui, apiInfoHandler))
 
 	return ui
 }
@@ -286,6 +294,15 @@ func setup(ui *WebUI, pass http.HandlerFunc, debug bool) http.HandlerFunc {
 		// static assets bypass all this hubbub
 		start := time.Now()
 
+		tenant, ok := authenticate(ui, w, r)
+		if !ok {
+			return
+		}
+		if readOnly(ui, r) {
+			http.Error(w, "Read-only replica", http.StatusForbidden)
+			return
+		}
+
 		// negotiate the language to be used for rendering
 
 		// set locale via cookie
@@ -306,6 +323,7 @@ func setup(ui *WebUI, pass http.HandlerFunc, debug bool) http.HandlerFunc {
 		dctx := &DefaultContext{
 			Context:  r.Context(),
 			webui:    ui,
+			tenant:   tenant,
 			response: w,
 			request:  r,
 			locale:   locale,
@@ -320,29 +338,50 @@ func setup(ui *WebUI, pass http.HandlerFunc, debug bool) http.HandlerFunc {
 			util.Infof("%s %s %v", r.Method, r.RequestURI, time.Since(start))
 		}
 	}
-	if ui.Options.Password != "" {
-		return basicAuth(ui.Options.Password, genericSetup)
-	}
 	return genericSetup
 }
 
-func basicAuth(pwd string, pass http.HandlerFunc) http.HandlerFunc {
+// authenticate finds the tenant logging in.  The Web UI's password
+// logs in to the root namespace, tenants log in with their name and
+// password.
+func authenticate(ui *WebUI, w http.ResponseWriter, r *http.Request) (*server.Tenant, bool) {
+	if ui.Options.Password == "" {
+		return ui.Server.Tenant(""), true
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Faktory"`)
+		http.Error(w, "Authorization required", http.StatusUnauthorized)
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(password), []byte(ui.Options.Password)) == 1 {
+		return ui.Server.Tenant(""), true
+	}
+	if tenant := ui.Server.Tenant(user); user != "" && tenant != nil && tenant.Authenticate(password) {
+		return tenant, true
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="Faktory"`)
+	http.Error(w, "Authorization failed", http.StatusUnauthorized)
+	return nil, false
+}
+
+// RootOnly hides the pages covering the whole server from tenants.
+func RootOnly(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_, password, ok := r.BasicAuth()
-		if !ok {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Faktory"`)
-			http.Error(w, "Authorization required", http.StatusUnauthorized)
-			return
-		}
-		if subtle.ConstantTimeCompare([]byte(password), []byte(pwd)) != 1 {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Faktory"`)
-			http.Error(w, "Authorization failed", http.StatusUnauthorized)
+		if !ctx(r).Tenant().IsRoot() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
-		pass(w, r)
+		h(w, r)
 	}
 }
 
+// A replica's data can't be changed until it's promoted.
+func readOnly(ui *WebUI, r *http.Request) bool {
+	return ui.Server.IsReplica() && r.Method != "GET" && r.Method != "HEAD"
+}
+
 func GetOnly(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
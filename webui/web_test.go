@@ -35,6 +35,27 @@ func TestLiveServer(t *testing.T) {
 			assert.True(t, strings.Contains(w.Body.String(), "Disk Usage"), w.Body.String())
 		})
 
+		t.Run("Backup", func(t *testing.T) {
+			req, err := ui.NewRequest("POST", "http://localhost:7420/debug", strings.NewReader("action=backup"))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			w := httptest.NewRecorder()
+			debugHandler(w, req)
+			assert.Equal(t, 302, w.Code)
+
+			infos, err := s.Backups()
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(infos))
+
+			req, err = ui.NewRequest("GET", "http://localhost:7420/debug", nil)
+			assert.NoError(t, err)
+			w = httptest.NewRecorder()
+			debugHandler(w, req)
+			assert.Equal(t, 200, w.Code)
+			assert.Contains(t, w.Body.String(), fmt.Sprint(infos[0].Id))
+		})
+
 		t.Run("ComputeLocale", func(t *testing.T) {
 			lang := localeFromHeader("")
 			assert.Equal(t, "en", lang)
@@ -75,22 +96,34 @@ func TestLiveServer(t *testing.T) {
 }
 
 func bootRuntime(t *testing.T, name string, fn func(*WebUI, *server.Server, *testing.T)) {
+	bootRuntimeWith(t, name, nil, fn)
+}
+
+// bootRuntimeWith lets the test change the options before booting.
+func bootRuntimeWith(t *testing.T, name string, setup func(*server.ServerOptions), fn func(*WebUI, *server.Server, *testing.T)) {
 	dir := fmt.Sprintf("/tmp/faktory-test-%s", name)
 	defer os.RemoveAll(dir)
 
 	sock := fmt.Sprintf("%s/redis.sock", dir)
-	stopper, err := storage.BootRedis(dir, sock)
-	if err != nil {
-		panic(err)
+	dbtype := testDBType()
+	if dbtype == "redis" {
+		stopper, err := storage.BootRedis(dir, sock)
+		if err != nil {
+			panic(err)
+		}
+		defer stopper()
 	}
-	defer stopper()
 
-	s, err := server.NewServer(&server.ServerOptions{
+	opts := &server.ServerOptions{
 		Binding:          "localhost:7418",
 		StorageDirectory: dir,
+		DBType:           dbtype,
 		RedisSock:        sock,
-	})
-
+	}
+	if setup != nil {
+		setup(opts)
+	}
+	s, err := server.NewServer(opts)
 	if err != nil {
 		panic(err)
 	}
@@ -108,11 +141,72 @@ func bootRuntime(t *testing.T, name string, fn func(*WebUI, *server.Server, *tes
 		}
 	}()
 
-	web := newWeb(s, defaultOptions())
+	uiopts := defaultOptions()
+	uiopts.Password = opts.Password
+	web := newWeb(s, uiopts)
 
 	fn(web, s, t)
 }
 
+func TestTenants(t *testing.T) {
+	if testDBType() != "redis" {
+		t.Skip("tenants require redis")
+	}
+
+	tenants := func(opts *server.ServerOptions) {
+		opts.Password = "root-pwd"
+		opts.Tenants = map[string]string{"acme": "acme-pwd"}
+	}
+	bootRuntimeWith(t, "tenants", tenants, func(ui *WebUI, s *server.Server, t *testing.T) {
+		q, err := s.Tenant("acme").Store().GetQueue("invoices")
+		assert.NoError(t, err)
+		_, job := fakeJob()
+		assert.NoError(t, q.Push(0, job))
+
+		get := func(path, user, pwd string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "http://localhost:7420"+path, nil)
+			req.SetBasicAuth(user, pwd)
+			w := httptest.NewRecorder()
+			ui.Mux.ServeHTTP(w, req)
+			return w
+		}
+
+		w := get("/queues", "acme", "acme-pwd")
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), "invoices")
+		w = get("/queues", "admin", "root-pwd")
+		assert.Equal(t, 200, w.Code)
+		assert.NotContains(t, w.Body.String(), "invoices")
+		w = get("/queues", "globex", "acme-pwd")
+		assert.Equal(t, 401, w.Code)
+
+		w = get("/tenants", "acme", "acme-pwd")
+		assert.Equal(t, 403, w.Code)
+		w = get("/debug", "acme", "acme-pwd")
+		assert.Equal(t, 403, w.Code)
+		w = get("/tenants", "admin", "root-pwd")
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), "<td>acme</td>")
+
+		req := httptest.NewRequest("GET", "http://localhost:7420/api/info", nil)
+		req.Header.Set("Authorization", "Bearer acme:acme-pwd")
+		w = httptest.NewRecorder()
+		ui.Mux.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), `"tenant":"acme"`)
+		w = get("/api/info", "acme", "acme-pwd")
+		assert.Equal(t, 401, w.Code)
+	})
+}
+
+// FAKTORY_TEST_DB=memory runs the tests without redis-server
+func testDBType() string {
+	if os.Getenv("FAKTORY_TEST_DB") == "memory" {
+		return "memory"
+	}
+	return "redis"
+}
+
 func fakeJob() (string, []byte) {
 	jid := util.RandomJid()
 	nows := util.Nows()